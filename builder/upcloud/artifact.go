@@ -12,11 +12,20 @@ import (
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 )
 
+// DiskTemplates are the templates created from one disk of the build server, one template per zone.
+type DiskTemplates struct {
+	// Disk is the title of the build server disk.
+	Disk string
+	// Templates in the build zone followed by templates in clone zones.
+	Templates []*upcloud.Storage
+}
+
 // packersdk.Artifact implementation.
 type Artifact struct {
-	config    *Config
-	driver    driver.Driver
-	Templates []*upcloud.Storage
+	config *Config
+	driver driver.Driver
+	// Templates grouped per disk, system disk first.
+	Templates []*DiskTemplates
 
 	// StateData should store data such as GeneratedData
 	// to be shared with post-processors
@@ -32,8 +41,9 @@ func (a *Artifact) Files() []string {
 }
 
 func (a *Artifact) Id() string { //nolint:revive // method is required by packer-plugin-sdk
-	result := make([]string, 0, len(a.Templates))
-	for _, t := range a.Templates {
+	templates := a.templates()
+	result := make([]string, 0, len(templates))
+	for _, t := range templates {
 		result = append(result, t.UUID)
 	}
 	return strings.Join(result, ",")
@@ -43,6 +53,28 @@ func (a *Artifact) String() string {
 	return fmt.Sprintf("Storage template created, UUID: %s", a.Id())
 }
 
+// templates returns templates of all disks.
+func (a *Artifact) templates() []*upcloud.Storage {
+	var templates []*upcloud.Storage
+	for _, d := range a.Templates {
+		templates = append(templates, d.Templates...)
+	}
+	return templates
+}
+
+// diskTemplateUUIDs returns template UUIDs by disk title and zone.
+func diskTemplateUUIDs(diskTemplates []*DiskTemplates) map[string]map[string]string {
+	result := make(map[string]map[string]string, len(diskTemplates))
+	for _, d := range diskTemplates {
+		zones := make(map[string]string, len(d.Templates))
+		for _, t := range d.Templates {
+			zones[t.Zone] = t.UUID
+		}
+		result[d.Disk] = zones
+	}
+	return result
+}
+
 func (a *Artifact) State(name string) interface{} {
 	if name == image.ArtifactStateURI {
 		images, err := a.buildHCPPackerRegistryMetadata()
@@ -58,7 +90,7 @@ func (a *Artifact) State(name string) interface{} {
 func (a *Artifact) Destroy() error {
 	ctx, cancel := contextWithDefaultTimeout()
 	defer cancel()
-	for _, t := range a.Templates {
+	for _, t := range a.templates() {
 		err := a.driver.DeleteTemplate(ctx, t.UUID)
 		if err != nil {
 			return fmt.Errorf("failed to delete template %s: %w", t.UUID, err)
@@ -82,7 +114,7 @@ func (a *Artifact) buildHCPPackerRegistryMetadata() ([]*image.Image, error) {
 	}
 
 	images := make([]*image.Image, 0)
-	for _, template := range a.Templates {
+	for _, template := range a.templates() {
 		img, err := image.FromArtifact(a,
 			image.WithID(template.UUID),
 			image.WithRegion(template.Zone),
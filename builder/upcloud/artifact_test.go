@@ -24,8 +24,10 @@ func TestArtifact_Id(t *testing.T) {
 	uuid2 := "some-uuid-2"
 	expected := fmt.Sprintf("%s,%s", uuid1, uuid2)
 
-	templates := make([]*upcloud.Storage, 0, 2)
-	templates = append(templates, &upcloud.Storage{UUID: uuid1}, &upcloud.Storage{UUID: uuid2})
+	templates := []*DiskTemplates{
+		{Disk: "custom-disk1", Templates: []*upcloud.Storage{{UUID: uuid1}}},
+		{Disk: "data", Templates: []*upcloud.Storage{{UUID: uuid2}}},
+	}
 
 	a := &Artifact{Templates: templates}
 	result := a.Id()
@@ -39,8 +41,9 @@ func TestArtifact_String(t *testing.T) {
 	t.Parallel()
 	expected := `Storage template created, UUID: some-uuid`
 
-	templates := make([]*upcloud.Storage, 0, 1)
-	templates = append(templates, &upcloud.Storage{UUID: "some-uuid"})
+	templates := []*DiskTemplates{
+		{Disk: "custom-disk1", Templates: []*upcloud.Storage{{UUID: "some-uuid"}}},
+	}
 
 	a := &Artifact{Templates: templates}
 	result := a.String()
@@ -69,7 +72,7 @@ func TestArtifact_Metadata(t *testing.T) {
 	)
 
 	a := &Artifact{
-		Templates: templates,
+		Templates: []*DiskTemplates{{Disk: "custom-disk1", Templates: templates}},
 		config: &Config{
 			Zone:           "fi-hel1",
 			CloneZones:     []string{"fi-hel2"},
@@ -100,3 +103,22 @@ func TestArtifact_Metadata(t *testing.T) {
 	}
 	assert.Equal(t, want, got[0])
 }
+
+func TestDiskTemplateUUIDs(t *testing.T) {
+	t.Parallel()
+	templates := []*DiskTemplates{
+		{Disk: "custom-disk1", Templates: []*upcloud.Storage{
+			{UUID: "system-hel1", Zone: "fi-hel1"},
+			{UUID: "system-hel2", Zone: "fi-hel2"},
+		}},
+		{Disk: "data", Templates: []*upcloud.Storage{
+			{UUID: "data-hel1", Zone: "fi-hel1"},
+		}},
+	}
+	want := map[string]map[string]string{
+		"custom-disk1": {"fi-hel1": "system-hel1", "fi-hel2": "system-hel2"},
+		"data":         {"fi-hel1": "data-hel1"},
+	}
+	assert.Equal(t, want, diskTemplateUUIDs(templates))
+	assert.Equal(t, "system-hel1,system-hel2,data-hel1", (&Artifact{Templates: templates}).Id())
+}
@@ -14,7 +14,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
-	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
 )
 
 const (
@@ -26,6 +26,9 @@ type Builder struct {
 	config Config
 	runner multistep.Runner
 	driver driver.Driver
+
+	// newDriverFn creates the driver, defaults to driver.NewDriver. Overridden in tests.
+	newDriverFn func(c *driver.DriverConfig) driver.Driver
 }
 
 func (b *Builder) ConfigSpec() hcldec.ObjectSpec { return b.config.FlatMapstructure().HCL2Spec() }
@@ -40,6 +43,7 @@ func (b *Builder) Prepare(raws ...interface{}) (generatedVars, warnings []string
 		"ServerUUID",
 		"ServerTitle",
 		"ServerSize",
+		"ServerZone",
 		"TemplateUUID",
 		"TemplateTitle",
 		"TemplateSize",
@@ -50,13 +54,29 @@ func (b *Builder) Prepare(raws ...interface{}) (generatedVars, warnings []string
 func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
 	// NOTE: context deadline is not set by default.
 
+	// Created resources are recorded so that they can be removed even if the build is killed before cleanup
+	jrnl, err := journal.OpenDir(b.config.JournalDir, b.config.PackerBuildName)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // journal errors are descriptive
+	}
+	defer reportJournal(ui, jrnl)
+
 	// Setup the state bag and initial state for the steps
-	b.driver = driver.NewDriver(&driver.DriverConfig{
-		Username:    b.config.Username,
-		Password:    b.config.Password,
-		Token:       b.config.Token,
-		Timeout:     b.config.Timeout,
-		SSHUsername: b.config.Comm.SSHUsername,
+	newDriver := driver.NewDriver
+	if b.newDriverFn != nil {
+		newDriver = b.newDriverFn
+	}
+	b.driver = newDriver(&driver.DriverConfig{
+		Username:     b.config.Username,
+		Password:     b.config.Password,
+		Token:        b.config.Token,
+		Timeout:      b.config.Timeout,
+		SSHUsername:  b.config.Comm.SSHUsername,
+		Journal:      jrnl,
+		APIURL:       b.config.APIURL,
+		HTTPProxy:    b.config.HTTPProxy,
+		CABundleFile: b.config.CABundleFile,
+		APIDebugLog:  b.config.APIDebugLog,
 	})
 
 	state := new(multistep.BasicStateBag)
@@ -87,9 +107,18 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return nil, errors.New("no template found in state, the build was probably cancelled")
 	}
 
-	templatesVal, ok := templates.([]*upcloud.Storage)
+	templatesVal, ok := templates.([]*DiskTemplates)
 	if !ok {
-		return nil, fmt.Errorf("templates is not of expected type []*upcloud.Storage, got %T", templates)
+		return nil, fmt.Errorf("templates is not of expected type []*DiskTemplates, got %T", templates)
+	}
+
+	// templates are handed over as an artifact
+	for _, d := range templatesVal {
+		for _, t := range d.Templates {
+			if err := jrnl.Remove(t.UUID); err != nil {
+				ui.Error(err.Error())
+			}
+		}
 	}
 
 	artifact := &Artifact{
@@ -102,6 +131,11 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 			"template_name":         b.config.TemplateName,
 			"source_template_uuid":  state.Get("source_template_uuid"),
 			"source_template_title": state.Get("source_template_title"),
+			"disk_templates":        diskTemplateUUIDs(templatesVal),
+			"server_plan":           state.Get("server_plan"),
+			"server_zone":           state.Get("server_zone"),
+			"cost_estimate":         state.Get("cost_estimate"),
+			"floating_ip_address":   state.Get("floating_ip_address"),
 		},
 	}
 
@@ -110,30 +144,78 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 
 // buildSteps creates and returns the sequence of steps for the build process.
 func (b *Builder) buildSteps(generatedData *packerbuilderdata.GeneratedData) []multistep.Step {
-	return []multistep.Step{
-		&StepCreateSSHKey{
+	steps := []multistep.Step{
+		&StepPreflight{
+			Config: &b.config,
+		},
+	}
+
+	if b.config.PlanSelection() {
+		steps = append(steps, &StepSelectPlan{
+			Config: &b.config,
+		})
+	}
+
+	steps = append(steps, &StepEstimateCost{
+		Config: &b.config,
+	})
+
+	// WinRM communicator uses administrator password generated during server creation
+	if b.config.Comm.Type != "winrm" {
+		steps = append(steps, &StepCreateSSHKey{
 			Debug:        b.config.PackerDebug,
 			DebugKeyPath: fmt.Sprintf("ssh_key-%s.pem", b.config.PackerBuildName),
-		},
+		})
+	}
+
+	if b.config.TemporaryPrivateNetwork != nil {
+		steps = append(steps, &StepCreatePrivateNetwork{
+			Config: &b.config,
+		})
+	}
+
+	steps = append(steps,
+		commonsteps.HTTPServerFromHTTPConfig(&b.config.HTTPConfig),
 		&StepCreateServer{
 			Config:        &b.config,
 			GeneratedData: generatedData,
 		},
-		b.communicatorStep(),
-		&commonsteps.StepProvision{},
-		&commonsteps.StepCleanupTempKeys{
-			Comm: &b.config.Comm,
+		// Firewall is enabled first to keep the server unfiltered for as short time as possible
+		&StepCreateFirewall{
+			Config: &b.config,
 		},
-		&StepTeardownServer{},
-		&StepCreateTemplate{
-			Config:        &b.config,
-			GeneratedData: generatedData,
+		&StepAttachFloatingIP{
+			Config: &b.config,
+		},
+		&StepTypeBootCommand{
+			Config: &b.config,
 		},
+	)
+
+	// Server is provisioned by cloud-init, which powers off the server when done
+	if b.config.CloudInitMode() {
+		steps = append(steps, &StepWaitServerStopped{
+			Config: &b.config,
+		})
+	} else {
+		steps = append(steps,
+			b.communicatorStep(),
+			&commonsteps.StepProvision{},
+			&commonsteps.StepCleanupTempKeys{
+				Comm: &b.config.Comm,
+			},
+			&StepTeardownServer{},
+		)
 	}
+
+	return append(steps, &StepCreateTemplate{
+		Config:        &b.config,
+		GeneratedData: generatedData,
+	})
 }
 
 // CommunicatorStep returns step based on communicator type
-// We currently support only SSH communicator but 'none' type
+// We currently support SSH and WinRM communicators but 'none' type
 // can also be used for e.g. testing purposes.
 func (b *Builder) communicatorStep() multistep.Step {
 	switch b.config.Comm.Type {
@@ -141,6 +223,12 @@ func (b *Builder) communicatorStep() multistep.Step {
 		return &communicator.StepConnect{
 			Config: &b.config.Comm,
 		}
+	case "winrm":
+		return &communicator.StepConnect{
+			Config:      &b.config.Comm,
+			Host:        winrmHostCallback,
+			WinRMConfig: winrmConfigCallback(b.config.Comm.WinRMUser),
+		}
 	default:
 		return &communicator.StepConnect{
 			Config:    &b.config.Comm,
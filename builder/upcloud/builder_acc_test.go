@@ -16,6 +16,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/acctest"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/upcloudtest"
 
 	_ "embed"
 )
@@ -24,6 +25,10 @@ import (
 
 const defaultTestTimeout = 15 * time.Minute
 
+func TestMain(m *testing.M) {
+	os.Exit(upcloudtest.RunAcceptanceTests(m))
+}
+
 // json
 
 //go:embed test-fixtures/json/basic.json
@@ -47,7 +52,7 @@ func TestBuilderAcc_default(t *testing.T) {
 
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuildBasic,
+		Template: upcloudtest.Template(testBuildBasic),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -59,7 +64,7 @@ func TestBuilderAcc_storageUuid(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderStorageUUID,
+		Template: upcloudtest.Template(testBuilderStorageUUID),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -71,7 +76,7 @@ func TestBuilderAcc_storageName(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderStorageName,
+		Template: upcloudtest.Template(testBuilderStorageName),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -83,7 +88,7 @@ func TestBuilderAcc_planAndTier(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderPlanAndTier,
+		Template: upcloudtest.Template(testBuilderPlanAndTier),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -95,7 +100,7 @@ func TestBuilderAcc_networking(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderNetworking,
+		Template: upcloudtest.Template(testBuilderNetworking),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -119,12 +124,18 @@ var testBuilderStorageNameHcl string
 //go:embed test-fixtures/hcl2/network_interfaces.pkr.hcl
 var testBuilderNetworkInterfacesHcl string
 
+//go:embed test-fixtures/hcl2/winrm.pkr.hcl
+var testBuilderWinRMHcl string
+
+//go:embed test-fixtures/hcl2/temporary_private_network.pkr.hcl
+var testBuilderTemporaryPrivateNetworkHcl string
+
 func TestBuilderAcc_default_hcl(t *testing.T) {
 	t.Parallel()
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuildBasicHcl,
+		Template: upcloudtest.Template(testBuildBasicHcl),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -136,7 +147,7 @@ func TestBuilderAcc_planAndTier_hcl(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderPlanAndTierHcl,
+		Template: upcloudtest.Template(testBuilderPlanAndTierHcl),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -148,7 +159,7 @@ func TestBuilderAcc_storageUuid_hcl(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderStorageUUIDHcl,
+		Template: upcloudtest.Template(testBuilderStorageUUIDHcl),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -160,7 +171,7 @@ func TestBuilderAcc_storageName_hcl(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderStorageNameHcl,
+		Template: upcloudtest.Template(testBuilderStorageNameHcl),
 		Check:    checkTestResult(t),
 		Teardown: teardown(t, t.Name()),
 	}
@@ -172,7 +183,7 @@ func TestBuilderAcc_network_interfaces_hcl(t *testing.T) {
 	testAccPreCheck(t)
 	testCase := &acctest.PluginTestCase{
 		Name:     t.Name(),
-		Template: testBuilderNetworkInterfacesHcl,
+		Template: upcloudtest.Template(testBuilderNetworkInterfacesHcl),
 		Check: func(buildCommand *exec.Cmd, logfile string) error {
 			re := regexp.MustCompile(`upcloud.network_interfaces: Selecting default ip '10.[0-9]{1,3}.[0-9]{1,3}.[0-9]{1,3}' as Server IP`)
 			log, err := readLog(t, logfile)
@@ -190,6 +201,40 @@ func TestBuilderAcc_network_interfaces_hcl(t *testing.T) {
 	acctest.TestPlugin(t, testCase)
 }
 
+func TestBuilderAcc_winrm_hcl(t *testing.T) {
+	t.Parallel()
+	testAccPreCheck(t)
+	testCase := &acctest.PluginTestCase{
+		Name:     t.Name(),
+		Template: upcloudtest.Template(testBuilderWinRMHcl),
+		Check:    checkTestResult(t),
+		Teardown: teardown(t, t.Name()),
+	}
+	acctest.TestPlugin(t, testCase)
+}
+
+func TestBuilderAcc_temporary_private_network_hcl(t *testing.T) {
+	t.Parallel()
+	testAccPreCheck(t)
+	testCase := &acctest.PluginTestCase{
+		Name:     t.Name(),
+		Template: upcloudtest.Template(testBuilderTemporaryPrivateNetworkHcl),
+		Check: func(buildCommand *exec.Cmd, logfile string) error {
+			log, err := readLog(t, logfile)
+			if err != nil {
+				return err
+			}
+			re := regexp.MustCompile(`upcloud.temporary_private_network: Deleting private network "packer-network-[0-9-]+"`)
+			if !re.MatchString(log) {
+				return fmt.Errorf("Unable find private network deletion from the log %s", logfile)
+			}
+			return checkTestResult(t)(buildCommand, logfile)
+		},
+		Teardown: teardown(t, t.Name()),
+	}
+	acctest.TestPlugin(t, testCase)
+}
+
 func testAccPreCheck(t *testing.T) {
 	t.Helper()
 
@@ -281,6 +326,7 @@ func teardown(t *testing.T, testName string) func() error {
 			Password: creds.Password,
 			Token:    creds.Token,
 			Timeout:  defaultTestTimeout,
+			APIURL:   driver.APIURLFromEnv(""),
 		})
 
 		for _, u := range uuids {
@@ -0,0 +1,170 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // driver is injected to unexported field
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver/fake"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// runFakeBuild runs the full builder step sequence against the fake driver.
+func runFakeBuild(t *testing.T, drv *fake.Driver, fields map[string]interface{}) (packersdk.Artifact, error) {
+	t.Helper()
+	raw := map[string]interface{}{
+		"username":      "testuser",
+		"password":      "testpass",
+		"zone":          "fi-hel1",
+		"storage_uuid":  fake.PublicTemplateUUID,
+		"template_name": "packer-test",
+		"communicator":  "none",
+		"journal_dir":   t.TempDir(),
+	}
+	for k, v := range fields {
+		raw[k] = v
+	}
+
+	b := &Builder{newDriverFn: func(_ *driver.DriverConfig) driver.Driver { return drv }}
+	_, _, err := b.Prepare(raw)
+	require.NoError(t, err)
+	return b.Run(t.Context(), packersdk.TestUi(t), &packersdk.MockHook{})
+}
+
+// assertCleanedUp checks that only templates are left behind.
+func assertCleanedUp(t *testing.T, drv *fake.Driver) {
+	t.Helper()
+	assert.Empty(t, drv.Servers())
+	assert.Empty(t, drv.Storages(upcloud.StorageTypeNormal))
+	assert.Empty(t, drv.Networks())
+	assert.Empty(t, drv.IPAddresses())
+}
+
+func TestBuilder_Run(t *testing.T) {
+	t.Parallel()
+	drv := fake.New()
+	artifact, err := runFakeBuild(t, drv, map[string]interface{}{
+		"clone_zones": []string{"fi-hel2"},
+		"storage_device": []map[string]interface{}{
+			{"size": 20, "template": true},
+			{"size": 10},
+		},
+		"temporary_private_network": map[string]interface{}{"cidr": "10.0.0.0/24"},
+		"floating_ip":               map[string]interface{}{},
+	})
+	require.NoError(t, err)
+	assertCleanedUp(t, drv)
+	assert.Equal(t, 1, drv.Calls("GetStorage"), "source storage is looked up only once")
+
+	templates := drv.Storages(upcloud.StorageTypeTemplate)
+	require.Len(t, templates, 4)
+	a, ok := artifact.(*Artifact)
+	require.True(t, ok)
+	assert.Equal(t, "fi-hel1", a.StateData["server_zone"])
+	assert.Equal(t, driver.DefaultPlan, a.StateData["server_plan"])
+	assert.Equal(t, map[string]map[string]string{
+		driver.SystemDiskTitle: {"fi-hel1": templates[0].UUID, "fi-hel2": templates[1].UUID},
+		"custom-disk2":         {"fi-hel1": templates[2].UUID, "fi-hel2": templates[3].UUID},
+	}, a.StateData["disk_templates"])
+	for _, template := range templates {
+		assert.Contains(t, []string{"packer-test", "packer-test-custom-disk2"}, template.Title)
+	}
+}
+
+func TestBuilder_Run_fallbackZone(t *testing.T) {
+	t.Parallel()
+	drv := fake.New()
+	drv.Fail("CreateServer", 1, driver.ErrCapacity)
+	artifact, err := runFakeBuild(t, drv, map[string]interface{}{
+		"fallback_zones": []string{"fi-hel2"},
+	})
+	require.NoError(t, err)
+	assertCleanedUp(t, drv)
+
+	a, ok := artifact.(*Artifact)
+	require.True(t, ok)
+	assert.Equal(t, "fi-hel2", a.StateData["server_zone"])
+	// template is cloned to the configured zone
+	zones := []string{}
+	for _, template := range drv.Storages(upcloud.StorageTypeTemplate) {
+		zones = append(zones, template.Zone)
+	}
+	assert.ElementsMatch(t, []string{"fi-hel1", "fi-hel2"}, zones)
+}
+
+func TestBuilder_Run_cleanup(t *testing.T) {
+	t.Parallel()
+	errFake := errors.New("fake error")
+	tests := []struct {
+		name   string
+		method string
+		call   int
+		err    error
+		fields map[string]interface{}
+	}{
+		{
+			name:   "server creation",
+			method: "CreateServer",
+			call:   1,
+			err:    driver.ErrCapacity,
+			fields: map[string]interface{}{"temporary_private_network": map[string]interface{}{"cidr": "10.0.0.0/24"}},
+		},
+		{
+			name:   "floating IP",
+			method: "CreateFloatingIPAddress",
+			call:   1,
+			err:    errFake,
+			fields: map[string]interface{}{"floating_ip": map[string]interface{}{}},
+		},
+		{
+			name:   "cloning",
+			method: "CloneStorage",
+			call:   2,
+			err:    errFake,
+			fields: map[string]interface{}{"clone_zones": []string{"fi-hel2", "de-fra1"}},
+		},
+		{
+			name:   "template of extra disk",
+			method: "CreateTemplate",
+			call:   3,
+			err:    errFake,
+			fields: map[string]interface{}{
+				"clone_zones":        []string{"fi-hel2"},
+				"storage_device":     []map[string]interface{}{{"size": 20}},
+				"template_all_disks": true,
+				"floating_ip":        map[string]interface{}{},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			drv := fake.New()
+			drv.Fail(test.method, test.call, test.err)
+			_, err := runFakeBuild(t, drv, test.fields)
+			require.ErrorIs(t, err, test.err)
+			assertCleanedUp(t, drv)
+			assert.Empty(t, drv.Storages(upcloud.StorageTypeTemplate))
+		})
+	}
+}
+
+func TestBuilder_Run_cloudInitTimeout(t *testing.T) {
+	t.Parallel()
+	drv := fake.New()
+	drv.Delay = 50 * time.Millisecond
+	_, err := runFakeBuild(t, drv, map[string]interface{}{
+		"user_data":          "#cloud-config\npower_state:\n  mode: poweroff\n",
+		"cloud_init_timeout": "10ms",
+	})
+	require.ErrorIs(t, err, driver.ErrTimeout)
+	assertCleanedUp(t, drv)
+	assert.Equal(t, 1, drv.Calls("StopServer"))
+}
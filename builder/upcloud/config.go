@@ -1,4 +1,4 @@
-//go:generate packer-sdc mapstructure-to-hcl2 -type Config,NetworkInterface,IPAddress
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,NetworkInterface,IPAddress,StorageFilter,TemporaryPrivateNetwork,TemporaryFirewall,FirewallRule,FloatingIP,StorageDevice
 //go:generate packer-sdc struct-markdown
 package upcloud
 
@@ -6,17 +6,24 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
 	"github.com/hashicorp/packer-plugin-sdk/common"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 	"github.com/hashicorp/packer-plugin-sdk/template/config"
 	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
 )
 
 type InterfaceType string
@@ -24,15 +31,29 @@ type InterfaceType string
 const (
 	DefaultTemplatePrefix                 = "custom-image"
 	DefaultSSHUsername                    = "root"
+	DefaultWinRMUsername                  = "Administrator"
 	DefaultCommunicator                   = "ssh"
 	DefaultStorageSize                    = 25
 	DefaultTimeout                        = 20 * time.Minute
+	DefaultCloudInitTimeout               = 30 * time.Minute
 	DefaultStorageTier                    = upcloud.StorageTierMaxIOPS
 	InterfaceTypePublic     InterfaceType = upcloud.IPAddressAccessPublic
 	InterfaceTypeUtility    InterfaceType = upcloud.IPAddressAccessUtility
 	InterfaceTypePrivate    InterfaceType = upcloud.IPAddressAccessPrivate
 	MaxTemplateNameLength                 = 40
 	MaxTemplatePrefixLength               = 40
+	maxPort                               = 65535
+
+	// Storage titles are limited to 64 characters by the API.
+	maxTemplateTitleLength = 64
+
+	// Limits of custom server plans.
+	minCoreNumber         = 1
+	maxCoreNumber         = 20
+	minMemoryAmount       = 1024
+	maxMemoryAmount       = 131072
+	memoryAmountStep      = 1024
+	maxMemoryAmountByCore = 8192
 )
 
 // for config type conversion.
@@ -54,13 +75,135 @@ type IPAddress struct {
 	// IP address family (IPv4 or IPv6)
 	Family string `mapstructure:"family"`
 
-	// IP address. Note that at the moment using floating IPs is not supported.
+	// IP address. Use `floating_ip` with `use_for_communicator` to connect to the server using a floating IP address.
 	Address string `mapstructure:"address,omitempty"`
 }
 
+// StorageFilter selects source storage using exact name, regular expression, access, type, zone and labels.
+// All criteria must match. Build fails if several storages match and `most_recent` is not set.
+type StorageFilter struct {
+	// Storage title, case-insensitive exact match.
+	Name string `mapstructure:"name"`
+
+	// Regular expression matched against storage title (e.g. `^Ubuntu Server 24\\.04`).
+	NameRegex string `mapstructure:"name_regex"`
+
+	// Storage access type, `public` or `private`.
+	Access string `mapstructure:"access"`
+
+	// Storage type, `template`, `normal` or `backup`. Defaults to `template`.
+	Type string `mapstructure:"type"`
+
+	// Zone where the storage is located.
+	Zone string `mapstructure:"zone"`
+
+	// Labels which storage must have. All given key-value pairs must match.
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Select the most recently created storage when several storages match. Defaults to `false`.
+	MostRecent bool `mapstructure:"most_recent"`
+}
+
+// TemporaryPrivateNetwork is a private network created for the build in the build zone.
+// The build server is attached to the network in addition to its other network interfaces
+// and the network is deleted after the server.
+type TemporaryPrivateNetwork struct {
+	// IP network address in CIDR notation, e.g. `10.0.0.0/24`.
+	CIDR string `mapstructure:"cidr" required:"true"`
+
+	// Enable DHCP for the network so that the build server gets its private IP address automatically. Defaults to `true`.
+	DHCP *bool `mapstructure:"dhcp"`
+
+	// The UUID of an existing router to attach to the network, e.g. to route traffic to other private networks.
+	Router string `mapstructure:"router"`
+}
+
+// TemporaryFirewall enables firewall on the build server for the duration of the build.
+// Incoming traffic is allowed only to the communicator port from `allowed_cidrs` and by the extra `rules`;
+// all other incoming traffic is dropped. Rules are removed together with the build server.
+//
+// The API cannot create a server without starting it, so the rules are applied right after the server has started.
+// Incoming traffic is not filtered while the server boots, so services of the source storage listening on public
+// interfaces, e.g. SSH with password authentication, are reachable from the internet for a few seconds.
+type TemporaryFirewall struct {
+	// IP addresses or networks in CIDR notation allowed to connect to the communicator port, e.g. `["192.0.2.0/24"]`.
+	// Defaults to the public IPv4 and IPv6 addresses of the machine running Packer, detected using an external service.
+	// Set this when connecting through a bastion host or over a private or utility network.
+	AllowedCIDRs []string `mapstructure:"allowed_cidrs"`
+
+	// Extra firewall rules added after the communicator rule and before the rules dropping other incoming traffic.
+	Rules []FirewallRule `mapstructure:"rules"`
+}
+
+// FirewallRule is an extra rule of the temporary firewall.
+type FirewallRule struct {
+	// Action, `accept`, `reject` or `drop`. Defaults to `accept`.
+	Action string `mapstructure:"action"`
+
+	// Traffic direction, `in` or `out`. Defaults to `in`.
+	Direction string `mapstructure:"direction"`
+
+	// IP address family, `IPv4` or `IPv6`. Defaults to the family of `source_cidr`.
+	Family string `mapstructure:"family"`
+
+	// Protocol, `tcp`, `udp` or `icmp`. Empty value matches all protocols.
+	Protocol string `mapstructure:"protocol"`
+
+	// Source IP address or network in CIDR notation. Empty value matches all addresses.
+	SourceCIDR string `mapstructure:"source_cidr"`
+
+	// First destination port of the port range. Requires `tcp` or `udp` protocol.
+	DestinationPortStart int `mapstructure:"destination_port_start"`
+
+	// Last destination port of the port range. Defaults to `destination_port_start`.
+	DestinationPortEnd int `mapstructure:"destination_port_end"`
+
+	// Comment shown in the firewall rule list.
+	Comment string `mapstructure:"comment"`
+}
+
+// FloatingIP is attached to the public network interface of the build server and saved in `floating_ip_address`
+// artifact state. Stock images do not configure floating IP addresses, so the communicator connects to the server IP
+// address unless `use_for_communicator` is set.
+type FloatingIP struct {
+	// Floating IPv4 address reserved beforehand, e.g. an address allow-listed in the firewalls of internal
+	// package mirrors. The address is detached from the build server after the build.
+	// When empty, a temporary floating IP address is reserved for the build and released after the build.
+	Address string `mapstructure:"address"`
+
+	// Connect the communicator to the floating IP address instead of the server IP address. Set only when the
+	// operating system of the build server is configured to use the floating IP address, e.g. with `user_data`,
+	// otherwise the communicator cannot connect to the server. Defaults to `false`.
+	UseForCommunicator bool `mapstructure:"use_for_communicator"`
+}
+
+// StorageDevice is an extra disk attached to the build server in addition to the system disk, e.g. a data volume or
+// scratch space. Disks are deleted together with the build server unless a template is created from them.
+type StorageDevice struct {
+	// The storage size in gigabytes. Required when `action` is `create`. Defaults to the size of the source storage when `action` is `clone`.
+	Size int `mapstructure:"size"`
+
+	// The storage tier to use. Defaults to `storage_tier`.
+	Tier string `mapstructure:"tier"`
+
+	// The storage title. Defaults to `custom-disk<n>`, where `<n>` is the number of the disk starting from `2`.
+	Title string `mapstructure:"title"`
+
+	// Either `create` an empty disk or `clone` the storage given in `source_uuid`. Defaults to `create`.
+	Action string `mapstructure:"action"`
+
+	// The UUID of the storage to clone. Required when `action` is `clone`.
+	SourceUUID string `mapstructure:"source_uuid"`
+
+	// Create a template from this disk in addition to the system disk and clone it to `clone_zones`.
+	// Defaults to `false`, meaning that the disk is used as scratch space and deleted together with the build server.
+	Template bool `mapstructure:"template"`
+}
+
 type Config struct {
-	common.PackerConfig `mapstructure:",squash"`
-	Comm                communicator.Config `mapstructure:",squash"`
+	common.PackerConfig    `mapstructure:",squash"`
+	commonsteps.HTTPConfig `mapstructure:",squash"`
+	Comm                   communicator.Config `mapstructure:",squash"`
 
 	// The username to use when interfacing with the UpCloud API.
 	Username string `mapstructure:"username"`
@@ -71,15 +214,59 @@ type Config struct {
 	// The API token to use when interfacing with the UpCloud API. This is mutually exclusive with username and password.
 	Token string `mapstructure:"token"`
 
+	// The base URL of the UpCloud API. Defaults to `UPCLOUD_API_URL` environment variable or `https://api.upcloud.com`.
+	// Can be used to send requests through an API gateway or to a local API emulator.
+	APIURL string `mapstructure:"api_url"`
+
+	// The URL of the HTTP proxy used for API requests, e.g. `http://proxy.example.com:3128`.
+	// Defaults to the proxy set in `HTTPS_PROXY` and `NO_PROXY` environment variables.
+	HTTPProxy string `mapstructure:"http_proxy"`
+
+	// Path to a file of PEM encoded CA certificates trusted in addition to the system certificates when connecting to
+	// the API, e.g. when `http_proxy` intercepts TLS connections.
+	CABundleFile string `mapstructure:"ca_bundle_file"`
+
+	// Path to a file where API requests are traced in addition to Packer's log, e.g. for attaching to support tickets.
+	// Method, path, status, latency, request ID and bodies of the requests are logged with credentials and SSH keys redacted.
+	// Tracing to Packer's log can be enabled also by setting `UPCLOUD_API_DEBUG` environment variable to `1`.
+	APIDebugLog string `mapstructure:"api_debug_log"`
+
 	// The zone in which the server and template should be created (e.g. nl-ams1).
 	Zone string `mapstructure:"zone" required:"true"`
 
 	// Server plan to use for the builder server. Defaults to `1xCPU-2GB`.
 	ServerPlan string `mapstructure:"server_plan"`
 
+	// The number of CPU cores of the build server, 1-20. Creates the server using custom plan instead of `server_plan`
+	// and requires `memory_amount`.
+	CoreNumber int `mapstructure:"core_number"`
+
+	// The amount of memory of the build server in megabytes, 1024-131072 in multiples of 1024 and at most 8192 per CPU core.
+	// Requires `core_number`.
+	MemoryAmount int `mapstructure:"memory_amount"`
+
+	// The minimum number of CPU cores of the build server. When `min_cpu`, `min_memory_gb` or `max_hourly_price` is set,
+	// the cheapest plan available in `zone` meeting the requirements is used instead of `server_plan`. Plans including
+	// storage of other tier than `storage_tier` or less storage than `storage_size` are not used.
+	MinCPU int `mapstructure:"min_cpu"`
+
+	// The minimum amount of memory of the build server in gigabytes.
+	MinMemoryGB int `mapstructure:"min_memory_gb"`
+
+	// The maximum hourly price of the build server plan in euros, e.g. `0.05`. Build fails if no plan meeting
+	// `min_cpu` and `min_memory_gb` is cheap enough.
+	MaxHourlyPrice float64 `mapstructure:"max_hourly_price"`
+
+	// Include developer (`DEV-*`) plans in plan selection. Defaults to `false`.
+	AllowDevPlans bool `mapstructure:"allow_dev_plans"`
+
+	// Include GPU (`GPU-*`) plans in plan selection. Defaults to `false`.
+	AllowGPUPlans bool `mapstructure:"allow_gpu_plans"`
+
 	// The UUID of the storage you want to use as a template when creating the server.
 	//
-	// Optionally use `storage_name` parameter to find matching storage
+	// Optionally use `storage_name` parameter to find matching storage.
+	// Not required when installing from ISO image using `iso_storage_uuid` or `iso_storage_name`.
 	StorageUUID string `mapstructure:"storage_uuid" required:"true"`
 
 	// The name of the storage that will be used to find the first matching storage in the list of existing templates.
@@ -87,6 +274,10 @@ type Config struct {
 	// Note that `storage_uuid` parameter has higher priority. You should use either `storage_uuid` or `storage_name` for not strict matching (e.g "ubuntu server 24.04").
 	StorageName string `mapstructure:"storage_name"`
 
+	// Filter used to select source storage deterministically, e.g. when several templates have similar titles.
+	// This is mutually exclusive with `storage_uuid` and `storage_name`.
+	SourceStorageFilter *StorageFilter `mapstructure:"source_storage_filter"`
+
 	// The prefix to use for the generated template title. Defaults to `custom-image`.
 	// You can use this option to easily differentiate between different templates.
 	TemplatePrefix string `mapstructure:"template_prefix"`
@@ -97,6 +288,14 @@ type Config struct {
 	// It cannot be used in conjunction with the prefix setting.
 	TemplateName string `mapstructure:"template_name"`
 
+	// Labels to set to the build server and its storage devices, e.g. `{ team = "platform" }`.
+	// Labels `packer-build-name`, `packer-build-uuid`, `packer-source-uuid` and `packer-plugin-version` are set automatically
+	// and keys starting with `packer-` are reserved.
+	ServerLabels map[string]string `mapstructure:"server_labels"`
+
+	// Labels to set to the created templates. Automatic `packer-` labels are set as with `server_labels`.
+	TemplateLabels map[string]string `mapstructure:"template_labels"`
+
 	// The storage size in gigabytes. Defaults to `25`.
 	// Changing this value is useful if you aim to build a template for larger server configurations where the preconfigured server disk is larger than 25 GB.
 	// The operating system disk can also be later extended if needed. Note that Windows templates require large storage size, than default 25 Gb.
@@ -106,19 +305,102 @@ type Config struct {
 	// For most production workloads, MaxIOPS is recommended for best performance.
 	StorageTier string `mapstructure:"storage_tier"`
 
+	// Extra disks to attach to the build server. Can be repeated.
+	StorageDevices []StorageDevice `mapstructure:"storage_device"`
+
+	// Create templates from all disks of the build server, including `storage_device` disks without `template` set.
+	// Defaults to `false`, meaning that templates are created from the system disk and disks with `template` set.
+	TemplateAllDisks bool `mapstructure:"template_all_disks"`
+
+	// Encrypt the build server disk, copies cloned to `clone_zones` and the created templates at rest. Defaults to `false`.
+	// Encryption is not supported by `archive` storage tier.
+	StorageEncryption bool `mapstructure:"storage_encryption"`
+
 	// The amount of time to wait for resource state changes. Defaults to `20m`.
 	Timeout time.Duration `mapstructure:"state_timeout_duration"`
 
-	// The amount of time to wait after booting the server. Defaults to '0s'
+	// The amount of time to wait after booting the server before typing `boot_command` and connecting to the server.
+	// Defaults to '0s'
 	BootWait time.Duration `mapstructure:"boot_wait"`
 
+	// The UUID of the ISO image (CD-ROM storage) to install the operating system from.
+	// When set, the server is created with an empty disk of `storage_size` and the ISO image attached as CD-ROM
+	// instead of cloning `storage_uuid`. Both public and uploaded private ISO images can be used.
+	ISOStorageUUID string `mapstructure:"iso_storage_uuid"`
+
+	// The name of the ISO image (CD-ROM storage) that will be used to find the first matching ISO image.
+	// Note that `iso_storage_uuid` parameter has higher priority.
+	ISOStorageName string `mapstructure:"iso_storage_name"`
+
+	// The keys to type over the server's VNC console after `boot_wait` when the server is started, e.g. to start the
+	// operating system installer. See the [Boot Command](/packer/docs/community-tools#boot-command) documentation for
+	// the syntax. The VNC console is enabled only when `boot_command` is set.
+	//
+	// The following template variables are available: `{{ .HTTPIP }}` (see `http_ip`) and `{{ .HTTPPort }}` of the HTTP
+	// server serving `http_directory` and `{{ .Name }}` of the server.
+	BootCommand []string `mapstructure:"boot_command"`
+
+	// Time to wait between each key press when typing `boot_command`. Defaults to `100ms`.
+	BootKeyInterval time.Duration `mapstructure:"boot_key_interval"`
+
+	// The IP address the server uses to reach the HTTP server serving `http_directory` or `http_content`, available as
+	// `{{ .HTTPIP }}` in `boot_command`, e.g. the public address of the machine running Packer. Defaults to
+	// `http_bind_address`. Either is required when the HTTP server is used with `boot_command`.
+	HTTPIP string `mapstructure:"http_ip"`
+
 	// The array of extra zones (locations) where created templates should be cloned.
 	// Note that default `state_timeout_duration` is not enough for cloning, better to increase a value depending on storage size.
 	CloneZones []string `mapstructure:"clone_zones"`
 
+	// Maximum estimated cost of the build in euros, including one billing hour of the build server, its disks and clones,
+	// and one month of template storage in all zones. Build fails before creating any resources if the estimate is higher.
+	// The estimate is printed and saved to `cost_estimate` artifact state regardless of this option.
+	// The estimate assumes that the server is created in `zone`, fallback zones are not included.
+	MaxEstimatedCost float64 `mapstructure:"max_estimated_cost"`
+
+	// Zones to try in order when the server cannot be created in `zone` due to lack of capacity.
+	// When the server is created in a fallback zone, templates are cloned to `zone` and `clone_zones`.
+	// Note that private source storages and networks must exist in all of the zones.
+	FallbackZones []string `mapstructure:"fallback_zones"`
+
+	// Server plans to try in order when the server cannot be created using `server_plan` due to lack of capacity.
+	// Fallback plans are tried in each zone before moving on to the next fallback zone.
+	FallbackPlans []string `mapstructure:"fallback_plans"`
+
 	// The array of network interfaces to request during the creation of the server for building the packer image.
 	NetworkInterfaces []NetworkInterface `mapstructure:"network_interfaces"`
 
+	// Private network to create for the build and delete after the build, so that the build server can be
+	// isolated in its own network without creating networks beforehand.
+	TemporaryPrivateNetwork *TemporaryPrivateNetwork `mapstructure:"temporary_private_network"`
+
+	// Firewall to enable on the build server, so that the communicator port is not open to the whole internet during provisioning.
+	TemporaryFirewall *TemporaryFirewall `mapstructure:"temporary_firewall"`
+
+	// Floating IP address to attach to the build server, e.g. for outgoing connections to services allow-listing the address.
+	FloatingIP *FloatingIP `mapstructure:"floating_ip"`
+
+	// User data passed to cloud-init of the build server, e.g. `#cloud-config` to install packages and configure
+	// package mirrors before the communicator connects. Requires a cloud-init template and enables the metadata service.
+	// This is mutually exclusive with `user_data_file`.
+	UserData string `mapstructure:"user_data"`
+
+	// Path to a file containing user data passed to cloud-init of the build server. This is mutually exclusive with `user_data`.
+	UserDataFile string `mapstructure:"user_data_file"`
+
+	// The amount of time to wait for the build server to power itself off when `communicator` is `none` and user data is set.
+	// In this mode, the server is provisioned only by cloud-init and the template is created once the server has stopped,
+	// so user data should power off the server when done, e.g. using `power_state` module. Defaults to `30m`.
+	CloudInitTimeout time.Duration `mapstructure:"cloud_init_timeout"`
+
+	// Enable or disable the metadata service of the build server. By default, the metadata service is enabled
+	// when `user_data` or `user_data_file` is set or when required by the storage template.
+	Metadata *bool `mapstructure:"metadata"`
+
+	// Directory where resources created during the build are recorded, so that they can be removed using
+	// `upcloud-packer-reaper -recover` if Packer is killed before cleaning up. Defaults to `upcloud/journal` in the Packer cache directory.
+	JournalDir string `mapstructure:"journal_dir"`
+
 	// Path to SSH Private Key that will be used for provisioning and stored in the template.
 	SSHPrivateKeyPath string `mapstructure:"ssh_private_key_path"`
 
@@ -144,6 +426,11 @@ func (c *Config) Prepare(raws ...interface{}) ([]string, error) {
 	err := config.Decode(c, &config.DecodeOpts{
 		Interpolate:        true,
 		InterpolateContext: &c.ctx,
+		InterpolateFilter: &interpolate.RenderFilter{
+			Exclude: []string{
+				"boot_command",
+			},
+		},
 	}, raws...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode configuration: %w", err)
@@ -177,6 +464,25 @@ func (c *Config) SetDefaults() {
 		c.StorageTier = DefaultStorageTier
 	}
 
+	for i := range c.StorageDevices {
+		c.StorageDevices[i].setDefaults(i, c.StorageTier)
+	}
+
+	if c.SourceStorageFilter != nil && c.SourceStorageFilter.Type == "" {
+		c.SourceStorageFilter.Type = upcloud.StorageTypeTemplate
+	}
+
+	if c.TemporaryPrivateNetwork != nil && c.TemporaryPrivateNetwork.DHCP == nil {
+		dhcp := true
+		c.TemporaryPrivateNetwork.DHCP = &dhcp
+	}
+
+	if c.TemporaryFirewall != nil {
+		for i := range c.TemporaryFirewall.Rules {
+			c.TemporaryFirewall.Rules[i].setDefaults()
+		}
+	}
+
 	if c.Timeout == 0 {
 		c.Timeout = DefaultTimeout
 	}
@@ -185,9 +491,17 @@ func (c *Config) SetDefaults() {
 		c.Comm.Type = DefaultCommunicator
 	}
 
+	if c.CloudInitTimeout == 0 {
+		c.CloudInitTimeout = DefaultCloudInitTimeout
+	}
+
 	if c.Comm.Type == "ssh" && c.Comm.SSHUsername == "" {
 		c.Comm.SSHUsername = DefaultSSHUsername
 	}
+
+	if c.Comm.Type == "winrm" && c.Comm.WinRMUser == "" {
+		c.Comm.WinRMUser = DefaultWinRMUsername
+	}
 }
 
 // validate validates the configuration and returns any errors.
@@ -204,25 +518,557 @@ func (c *Config) validate() *packer.MultiError {
 		)
 	}
 
-	if c.StorageUUID == "" && c.StorageName == "" {
+	if c.Comm.Type == "winrm" && c.Comm.WinRMPassword != "" {
 		errs = packer.MultiErrorAppend(
-			errs, errors.New("'storage_uuid' or 'storage_name' must be specified"),
+			errs, errors.New("'winrm_password' cannot be set, administrator password is generated during server creation"),
+		)
+	}
+
+	// login user and SSH key are created only when cloning a template, so credentials set up by the installer are needed
+	if c.ISOMode() && c.Comm.Type == "ssh" && c.Comm.SSHPassword == "" && c.Comm.SSHPrivateKeyFile == "" &&
+		!c.Comm.SSHAgentAuth && c.SSHPrivateKeyPath == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("'ssh_password' or 'ssh_private_key_file' must be set when installing from ISO image, SSH key is not installed to the server"),
 		)
 	}
 
+	if es := c.HTTPConfig.Prepare(&c.ctx); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if es := driver.ValidateEndpoint(c.APIURL, c.HTTPProxy, c.CABundleFile); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	// Validate source storage
+	if storageErrs := c.validateStorage(); storageErrs != nil {
+		errs = packer.MultiErrorAppend(errs, storageErrs.Errors...)
+	}
+
+	if c.StorageEncryption && !driver.StorageTierSupportsEncryption(c.StorageTier) {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("'storage_encryption' is not supported by 'storage_tier' %q", c.StorageTier),
+		)
+	}
+
+	// Validate custom plan
+	if planErrs := c.validateCustomPlan(); planErrs != nil {
+		errs = packer.MultiErrorAppend(errs, planErrs.Errors...)
+	}
+
+	if len(c.FallbackZones) > 0 && c.TemporaryPrivateNetwork != nil {
+		errs = packer.MultiErrorAppend(errs, errors.New("'fallback_zones' cannot be used with 'temporary_private_network', which is created in 'zone'"))
+	}
+
+	if slices.Contains(c.FallbackZones, c.Zone) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("'fallback_zones' must not contain 'zone' %q", c.Zone))
+	}
+
+	if c.MaxEstimatedCost < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'max_estimated_cost' %g", c.MaxEstimatedCost))
+	}
+
+	// Validate plan selection
+	if planSelectionErrs := c.validatePlanSelection(); planSelectionErrs != nil {
+		errs = packer.MultiErrorAppend(errs, planSelectionErrs.Errors...)
+	}
+
+	// Validate extra disks
+	if storageDeviceErrs := c.validateStorageDevices(); storageDeviceErrs != nil {
+		errs = packer.MultiErrorAppend(errs, storageDeviceErrs.Errors...)
+	}
+
+	// Validate user data
+	if userDataErrs := c.validateUserData(); userDataErrs != nil {
+		errs = packer.MultiErrorAppend(errs, userDataErrs.Errors...)
+	}
+
+	// Validate boot command
+	if bootErrs := c.validateBootCommand(); bootErrs != nil {
+		errs = packer.MultiErrorAppend(errs, bootErrs.Errors...)
+	}
+
 	// Validate template configuration
 	if templateErrs := c.validateTemplate(); templateErrs != nil {
 		errs = packer.MultiErrorAppend(errs, templateErrs.Errors...)
 	}
 
+	for _, err := range driver.ValidateLabels(c.ServerLabels) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'server_labels': %w", err))
+	}
+
+	for _, err := range driver.ValidateLabels(c.TemplateLabels) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'template_labels': %w", err))
+	}
+
 	// Validate network interfaces
 	if networkErrs := c.validateNetworkInterfaces(); networkErrs != nil {
 		errs = packer.MultiErrorAppend(errs, networkErrs.Errors...)
 	}
 
+	if c.TemporaryPrivateNetwork != nil {
+		if networkErrs := c.TemporaryPrivateNetwork.validate(); networkErrs != nil {
+			errs = packer.MultiErrorAppend(errs, networkErrs.Errors...)
+		}
+	}
+
+	if c.FloatingIP != nil && c.FloatingIP.Address != "" {
+		if ip := net.ParseIP(c.FloatingIP.Address); ip == nil || ip.To4() == nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'floating_ip' 'address' %q, must be IPv4 address", c.FloatingIP.Address))
+		}
+	}
+
+	if c.TemporaryFirewall != nil {
+		if firewallErrs := c.TemporaryFirewall.validate(); firewallErrs != nil {
+			errs = packer.MultiErrorAppend(errs, firewallErrs.Errors...)
+		}
+	}
+
+	return errs
+}
+
+// validateStorage checks that either template, storage filter or ISO image is used as source.
+func (c *Config) validateStorage() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if c.ISOMode() {
+		if c.StorageUUID != "" || c.StorageName != "" || c.SourceStorageFilter != nil {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("you can either use 'storage_uuid'/'storage_name'/'source_storage_filter' or 'iso_storage_uuid'/'iso_storage_name' in your configuration"),
+			)
+		}
+		return errs
+	}
+
+	if c.SourceStorageFilter != nil {
+		if c.StorageUUID != "" || c.StorageName != "" {
+			errs = packer.MultiErrorAppend(
+				errs, errors.New("you can either use 'storage_uuid'/'storage_name' or 'source_storage_filter' in your configuration"),
+			)
+		}
+		if filterErrs := c.SourceStorageFilter.validate(); filterErrs != nil {
+			errs = packer.MultiErrorAppend(errs, filterErrs.Errors...)
+		}
+		return errs
+	}
+
+	if c.StorageUUID == "" && c.StorageName == "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("'storage_uuid' or 'storage_name' must be specified"),
+		)
+	}
+
 	return errs
 }
 
+// PlanSelection returns true when the server plan is selected using minimum resource requirements.
+func (c *Config) PlanSelection() bool {
+	return c.MinCPU != 0 || c.MinMemoryGB != 0 || c.MaxHourlyPrice != 0
+}
+
+// planRequirements returns the requirements of the plan selected using minimum resource requirements.
+func (c *Config) planRequirements() planRequirements {
+	return planRequirements{
+		MinCPU:      c.MinCPU,
+		MinMemoryGB: c.MinMemoryGB,
+		MaxPrice:    c.MaxHourlyPrice,
+		StorageTier: c.StorageTier,
+		StorageSize: c.StorageSize,
+		AllowDev:    c.AllowDevPlans,
+		AllowGPU:    c.AllowGPUPlans,
+	}
+}
+
+// validatePlanSelection checks minimum resource requirements.
+func (c *Config) validatePlanSelection() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if !c.PlanSelection() {
+		return nil
+	}
+
+	if c.ServerPlan != "" || c.CoreNumber != 0 || c.MemoryAmount != 0 {
+		errs = packer.MultiErrorAppend(errs, errors.New(
+			"you can either use 'server_plan', 'core_number' and 'memory_amount' or 'min_cpu', 'min_memory_gb' and 'max_hourly_price' in your configuration",
+		))
+	}
+
+	if c.MinCPU < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'min_cpu' %d", c.MinCPU))
+	}
+
+	if c.MinMemoryGB < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'min_memory_gb' %d", c.MinMemoryGB))
+	}
+
+	if c.MaxHourlyPrice < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'max_hourly_price' %g", c.MaxHourlyPrice))
+	}
+
+	return errs
+}
+
+// validateCustomPlan checks custom plan CPU and memory against the limits of custom plans.
+func (c *Config) validateCustomPlan() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if c.CoreNumber == 0 && c.MemoryAmount == 0 {
+		return nil
+	}
+
+	if c.ServerPlan != "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("you can either use 'server_plan' or 'core_number' and 'memory_amount' in your configuration"))
+	}
+
+	if c.CoreNumber == 0 || c.MemoryAmount == 0 {
+		return packer.MultiErrorAppend(errs, errors.New("'core_number' and 'memory_amount' must be used together"))
+	}
+
+	if c.CoreNumber < minCoreNumber || c.CoreNumber > maxCoreNumber {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'core_number' %d, must be between %d and %d", c.CoreNumber, minCoreNumber, maxCoreNumber))
+	}
+
+	if c.MemoryAmount < minMemoryAmount || c.MemoryAmount > maxMemoryAmount {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'memory_amount' %d, must be between %d and %d", c.MemoryAmount, minMemoryAmount, maxMemoryAmount))
+	} else if c.MemoryAmount%memoryAmountStep != 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'memory_amount' %d, must be a multiple of %d", c.MemoryAmount, memoryAmountStep))
+	}
+
+	if c.CoreNumber > 0 && c.MemoryAmount > c.CoreNumber*maxMemoryAmountByCore {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'memory_amount' %d, at most %d is allowed per core (%d for %d cores)",
+			c.MemoryAmount, maxMemoryAmountByCore, c.CoreNumber*maxMemoryAmountByCore, c.CoreNumber))
+	}
+
+	return errs
+}
+
+// validateStorageDevices checks extra disks.
+func (c *Config) validateStorageDevices() *packer.MultiError {
+	var errs *packer.MultiError
+
+	titles := map[string]bool{driver.SystemDiskTitle: true}
+	for i := range c.StorageDevices {
+		device := &c.StorageDevices[i]
+		for _, err := range device.validate() {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'storage_device' %d: %w", i+1, err))
+		}
+		if c.StorageEncryption && !driver.StorageTierSupportsEncryption(device.Tier) {
+			errs = packer.MultiErrorAppend(
+				errs, fmt.Errorf("invalid 'storage_device' %d: 'storage_encryption' is not supported by 'tier' %q", i+1, device.Tier),
+			)
+		}
+		if titles[device.Title] {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'storage_device' %d: 'title' %q is not unique", i+1, device.Title))
+		}
+		titles[device.Title] = true
+
+		// templates of extra disks are named after the system disk template and the disk
+		if length := c.templateTitleLength() + 1 + len(device.Title); (device.Template || c.TemplateAllDisks) && length > maxTemplateTitleLength {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf(
+				"invalid 'storage_device' %d: template title would be %d characters, at most %d allowed, shorten 'title' or 'template_prefix'/'template_name'",
+				i+1, length, maxTemplateTitleLength,
+			))
+		}
+	}
+
+	return errs
+}
+
+// validate checks storage filter criteria.
+func (f *StorageFilter) validate() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if f.Name == "" && f.NameRegex == "" && len(f.Labels) == 0 {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("'source_storage_filter' requires at least one of 'name', 'name_regex' or 'labels'"),
+		)
+	}
+
+	if f.NameRegex != "" {
+		if _, err := regexp.Compile(f.NameRegex); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'source_storage_filter' 'name_regex': %w", err))
+		}
+	}
+
+	switch f.Access {
+	case "", upcloud.StorageAccessPublic, upcloud.StorageAccessPrivate:
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("invalid 'source_storage_filter' 'access' %q, must be one of: %s, %s", f.Access, upcloud.StorageAccessPublic, upcloud.StorageAccessPrivate),
+		)
+	}
+
+	switch f.Type {
+	case upcloud.StorageTypeTemplate, upcloud.StorageTypeNormal, upcloud.StorageTypeBackup:
+	default:
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("invalid 'source_storage_filter' 'type' %q, must be one of: %s, %s, %s",
+				f.Type, upcloud.StorageTypeTemplate, upcloud.StorageTypeNormal, upcloud.StorageTypeBackup),
+		)
+	}
+
+	return errs
+}
+
+// validate checks temporary private network configuration.
+func (n *TemporaryPrivateNetwork) validate() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if n.CIDR == "" {
+		errs = packer.MultiErrorAppend(errs, errors.New("'temporary_private_network' requires 'cidr'"))
+	} else if _, _, err := net.ParseCIDR(n.CIDR); err != nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'temporary_private_network' 'cidr' %q", n.CIDR))
+	}
+
+	if n.Router != "" {
+		if _, err := uuid.Parse(n.Router); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'temporary_private_network' 'router' UUID %q", n.Router))
+		}
+	}
+
+	return errs
+}
+
+// validate checks temporary firewall configuration.
+func (f *TemporaryFirewall) validate() *packer.MultiError {
+	var errs *packer.MultiError
+
+	for _, cidr := range f.AllowedCIDRs {
+		if _, _, _, err := addressRange(cidr); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'temporary_firewall' 'allowed_cidrs': %w", err))
+		}
+	}
+
+	for i, rule := range f.Rules {
+		for _, err := range rule.validate() {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("'temporary_firewall' rule %d: %w", i, err))
+		}
+	}
+
+	return errs
+}
+
+// setDefaults sets default action, tier and title of the i:th extra disk.
+func (d *StorageDevice) setDefaults(i int, tier string) {
+	if d.Action == "" {
+		d.Action = request.CreateServerStorageDeviceActionCreate
+	}
+	if d.Tier == "" {
+		d.Tier = tier
+	}
+	if d.Title == "" {
+		// system disk is the first disk
+		d.Title = fmt.Sprintf("%s-disk%d", driver.DefaultHostname, i+2)
+	}
+}
+
+// validate checks extra disk configuration.
+func (d *StorageDevice) validate() []error {
+	var errs []error
+
+	switch d.Action {
+	case request.CreateServerStorageDeviceActionCreate:
+		if d.Size <= 0 {
+			errs = append(errs, errors.New("'size' is required when 'action' is create"))
+		}
+		if d.SourceUUID != "" {
+			errs = append(errs, errors.New("'source_uuid' can be used only when 'action' is clone"))
+		}
+	case request.CreateServerStorageDeviceActionClone:
+		if d.Size < 0 {
+			errs = append(errs, fmt.Errorf("invalid 'size' %d", d.Size))
+		}
+		if _, err := uuid.Parse(d.SourceUUID); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'source_uuid' %q, UUID of the storage to clone is required when 'action' is clone", d.SourceUUID))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("invalid 'action' %q, must be one of: %s, %s",
+			d.Action, request.CreateServerStorageDeviceActionCreate, request.CreateServerStorageDeviceActionClone))
+	}
+
+	return errs
+}
+
+func (r *FirewallRule) setDefaults() {
+	if r.Action == "" {
+		r.Action = upcloud.FirewallRuleActionAccept
+	}
+	if r.Direction == "" {
+		r.Direction = upcloud.FirewallRuleDirectionIn
+	}
+	if r.Family == "" && r.SourceCIDR != "" {
+		if family, _, _, err := addressRange(r.SourceCIDR); err == nil {
+			r.Family = family
+		}
+	}
+	if r.DestinationPortEnd == 0 {
+		r.DestinationPortEnd = r.DestinationPortStart
+	}
+}
+
+// validate checks firewall rule. Defaults are expected to be set.
+func (r *FirewallRule) validate() []error {
+	var errs []error
+
+	switch r.Action {
+	case upcloud.FirewallRuleActionAccept, upcloud.FirewallRuleActionReject, upcloud.FirewallRuleActionDrop:
+	default:
+		errs = append(errs, fmt.Errorf("invalid 'action' %q", r.Action))
+	}
+
+	switch r.Direction {
+	case upcloud.FirewallRuleDirectionIn, upcloud.FirewallRuleDirectionOut:
+	default:
+		errs = append(errs, fmt.Errorf("invalid 'direction' %q", r.Direction))
+	}
+
+	switch r.Family {
+	case upcloud.IPAddressFamilyIPv4, upcloud.IPAddressFamilyIPv6:
+	case "":
+		errs = append(errs, errors.New("'family' or 'source_cidr' must be specified"))
+	default:
+		errs = append(errs, fmt.Errorf("invalid 'family' %q", r.Family))
+	}
+
+	switch r.Protocol {
+	case "", upcloud.FirewallRuleProtocolTCP, upcloud.FirewallRuleProtocolUDP, upcloud.FirewallRuleProtocolICMP:
+	default:
+		errs = append(errs, fmt.Errorf("invalid 'protocol' %q", r.Protocol))
+	}
+
+	if r.SourceCIDR != "" {
+		if family, _, _, err := addressRange(r.SourceCIDR); err != nil {
+			errs = append(errs, err)
+		} else if r.Family != "" && family != r.Family {
+			errs = append(errs, fmt.Errorf("'source_cidr' %q is not %s address", r.SourceCIDR, r.Family))
+		}
+	}
+
+	if r.DestinationPortStart != 0 || r.DestinationPortEnd != 0 {
+		if r.Protocol != upcloud.FirewallRuleProtocolTCP && r.Protocol != upcloud.FirewallRuleProtocolUDP {
+			errs = append(errs, errors.New("destination ports require 'tcp' or 'udp' protocol"))
+		}
+		if r.DestinationPortStart < 1 || r.DestinationPortEnd > maxPort || r.DestinationPortEnd < r.DestinationPortStart {
+			errs = append(errs, fmt.Errorf("invalid destination port range %d-%d", r.DestinationPortStart, r.DestinationPortEnd))
+		}
+	}
+
+	return errs
+}
+
+// validateUserData checks that user data is set only once and can be delivered using the metadata service.
+func (c *Config) validateUserData() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if c.UserData != "" && c.UserDataFile != "" {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("you can either use 'user_data' or 'user_data_file' in your configuration"),
+		)
+	}
+
+	if c.UserDataFile != "" {
+		if _, err := os.Stat(c.UserDataFile); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'user_data_file': %w", err))
+		}
+	}
+
+	if (c.UserData != "" || c.UserDataFile != "") && c.Metadata != nil && !*c.Metadata {
+		errs = packer.MultiErrorAppend(
+			errs, errors.New("'user_data' and 'user_data_file' require 'metadata' to be enabled"),
+		)
+	}
+
+	return errs
+}
+
+// userData returns user data from configuration or user data file.
+func (c *Config) userData() (string, error) {
+	if c.UserDataFile == "" {
+		return c.UserData, nil
+	}
+	b, err := os.ReadFile(c.UserDataFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read user data file: %w", err)
+	}
+	return string(b), nil
+}
+
+// validateBootCommand checks boot command syntax.
+func (c *Config) validateBootCommand() *packer.MultiError {
+	var errs *packer.MultiError
+
+	if len(c.BootCommand) == 0 {
+		return errs
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(c.FlatBootCommand())
+	if err != nil {
+		return packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'boot_command': %w", err))
+	}
+
+	if es := seq.Validate(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if c.HTTPIP != "" && net.ParseIP(c.HTTPIP) == nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'http_ip' %q", c.HTTPIP))
+	}
+
+	// the address of the VNC connection is not reachable by the server when Packer is behind NAT
+	if (c.HTTPDir != "" || len(c.HTTPContent) > 0) && c.httpIP() == "" {
+		errs = packer.MultiErrorAppend(errs, errors.New(
+			"'http_ip' or 'http_bind_address' must be set to an address the server can reach when 'http_directory' or 'http_content' is used with 'boot_command'",
+		))
+	}
+
+	return errs
+}
+
+// httpIP returns the address which the server should use to reach the HTTP server, or empty string if not known.
+func (c *Config) httpIP() string {
+	if c.HTTPIP != "" {
+		return c.HTTPIP
+	}
+	if ip := net.ParseIP(c.HTTPAddress); ip != nil && !ip.IsUnspecified() {
+		return ip.String()
+	}
+	return ""
+}
+
+// serverLabels returns labels for the build server and its storage devices.
+func (c *Config) serverLabels(sourceUUID string) map[string]string {
+	return driver.MergeLabels(c.ServerLabels, driver.AutomaticLabels(c.PackerBuildName, sourceUUID))
+}
+
+// templateLabels returns labels for the created templates.
+func (c *Config) templateLabels(sourceUUID string) map[string]string {
+	return driver.MergeLabels(c.TemplateLabels, driver.AutomaticLabels(c.PackerBuildName, sourceUUID))
+}
+
+// CloudInitMode returns true when the server is provisioned only by cloud-init, without connecting to the server.
+func (c *Config) CloudInitMode() bool {
+	return c.Comm.Type == "none" && (c.UserData != "" || c.UserDataFile != "")
+}
+
+// ISOMode returns true when the server is installed from ISO image instead of cloning a template.
+func (c *Config) ISOMode() bool {
+	return c.ISOStorageUUID != "" || c.ISOStorageName != ""
+}
+
+// FlatBootCommand returns boot command as a single string.
+func (c *Config) FlatBootCommand() string {
+	return strings.Join(c.BootCommand, "")
+}
+
+// templateTitleLength returns the length of the system disk template title.
+func (c *Config) templateTitleLength() int {
+	if c.TemplatePrefix != "" {
+		// prefix is followed by a timestamp, e.g. custom-image-20260101-120000
+		return len(c.TemplatePrefix) + len("-20060102-150405")
+	}
+	return len(c.TemplateName)
+}
+
 // validateTemplate checks template configuration.
 func (c *Config) validateTemplate() *packer.MultiError {
 	var errs *packer.MultiError
@@ -258,6 +1104,7 @@ func (c *Config) SetEnv() error {
 	c.Username = creds.Username
 	c.Password = creds.Password
 	c.Token = creds.Token
+	c.APIURL = driver.APIURLFromEnv(c.APIURL)
 	return nil
 }
 
@@ -10,80 +10,120 @@ import (
 // FlatConfig is an auto-generated flat version of Config.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatConfig struct {
-	PackerBuildName           *string                `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
-	PackerBuilderType         *string                `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
-	PackerCoreVersion         *string                `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
-	PackerDebug               *bool                  `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
-	PackerForce               *bool                  `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
-	PackerOnError             *string                `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
-	PackerUserVars            map[string]string      `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
-	PackerSensitiveVars       []string               `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
-	Type                      *string                `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
-	PauseBeforeConnect        *string                `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
-	SSHHost                   *string                `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
-	SSHPort                   *int                   `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
-	SSHUsername               *string                `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
-	SSHPassword               *string                `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
-	SSHKeyPairName            *string                `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
-	SSHTemporaryKeyPairName   *string                `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
-	SSHTemporaryKeyPairType   *string                `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
-	SSHTemporaryKeyPairBits   *int                   `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
-	SSHCiphers                []string               `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
-	SSHClearAuthorizedKeys    *bool                  `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
-	SSHKEXAlgos               []string               `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
-	SSHPrivateKeyFile         *string                `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
-	SSHCertificateFile        *string                `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
-	SSHPty                    *bool                  `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
-	SSHTimeout                *string                `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
-	SSHWaitTimeout            *string                `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
-	SSHAgentAuth              *bool                  `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
-	SSHDisableAgentForwarding *bool                  `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
-	SSHHandshakeAttempts      *int                   `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
-	SSHBastionHost            *string                `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
-	SSHBastionPort            *int                   `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
-	SSHBastionAgentAuth       *bool                  `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
-	SSHBastionUsername        *string                `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
-	SSHBastionPassword        *string                `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
-	SSHBastionInteractive     *bool                  `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
-	SSHBastionPrivateKeyFile  *string                `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
-	SSHBastionCertificateFile *string                `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
-	SSHFileTransferMethod     *string                `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
-	SSHProxyHost              *string                `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
-	SSHProxyPort              *int                   `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
-	SSHProxyUsername          *string                `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
-	SSHProxyPassword          *string                `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
-	SSHKeepAliveInterval      *string                `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
-	SSHReadWriteTimeout       *string                `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
-	SSHRemoteTunnels          []string               `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
-	SSHLocalTunnels           []string               `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
-	SSHPublicKey              []byte                 `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
-	SSHPrivateKey             []byte                 `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
-	WinRMUser                 *string                `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
-	WinRMPassword             *string                `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
-	WinRMHost                 *string                `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
-	WinRMNoProxy              *bool                  `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
-	WinRMPort                 *int                   `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
-	WinRMTimeout              *string                `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
-	WinRMUseSSL               *bool                  `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
-	WinRMInsecure             *bool                  `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
-	WinRMUseNTLM              *bool                  `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
-	Username                  *string                `mapstructure:"username" cty:"username" hcl:"username"`
-	Password                  *string                `mapstructure:"password" cty:"password" hcl:"password"`
-	Token                     *string                `mapstructure:"token" cty:"token" hcl:"token"`
-	Zone                      *string                `mapstructure:"zone" required:"true" cty:"zone" hcl:"zone"`
-	ServerPlan                *string                `mapstructure:"server_plan" cty:"server_plan" hcl:"server_plan"`
-	StorageUUID               *string                `mapstructure:"storage_uuid" required:"true" cty:"storage_uuid" hcl:"storage_uuid"`
-	StorageName               *string                `mapstructure:"storage_name" cty:"storage_name" hcl:"storage_name"`
-	TemplatePrefix            *string                `mapstructure:"template_prefix" cty:"template_prefix" hcl:"template_prefix"`
-	TemplateName              *string                `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
-	StorageSize               *int                   `mapstructure:"storage_size" cty:"storage_size" hcl:"storage_size"`
-	StorageTier               *string                `mapstructure:"storage_tier" cty:"storage_tier" hcl:"storage_tier"`
-	Timeout                   *string                `mapstructure:"state_timeout_duration" cty:"state_timeout_duration" hcl:"state_timeout_duration"`
-	BootWait                  *string                `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
-	CloneZones                []string               `mapstructure:"clone_zones" cty:"clone_zones" hcl:"clone_zones"`
-	NetworkInterfaces         []FlatNetworkInterface `mapstructure:"network_interfaces" cty:"network_interfaces" hcl:"network_interfaces"`
-	SSHPrivateKeyPath         *string                `mapstructure:"ssh_private_key_path" cty:"ssh_private_key_path" hcl:"ssh_private_key_path"`
-	SSHPublicKeyPath          *string                `mapstructure:"ssh_public_key_path" cty:"ssh_public_key_path" hcl:"ssh_public_key_path"`
+	PackerBuildName           *string                      `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
+	PackerBuilderType         *string                      `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
+	PackerCoreVersion         *string                      `mapstructure:"packer_core_version" cty:"packer_core_version" hcl:"packer_core_version"`
+	PackerDebug               *bool                        `mapstructure:"packer_debug" cty:"packer_debug" hcl:"packer_debug"`
+	PackerForce               *bool                        `mapstructure:"packer_force" cty:"packer_force" hcl:"packer_force"`
+	PackerOnError             *string                      `mapstructure:"packer_on_error" cty:"packer_on_error" hcl:"packer_on_error"`
+	PackerUserVars            map[string]string            `mapstructure:"packer_user_variables" cty:"packer_user_variables" hcl:"packer_user_variables"`
+	PackerSensitiveVars       []string                     `mapstructure:"packer_sensitive_variables" cty:"packer_sensitive_variables" hcl:"packer_sensitive_variables"`
+	HTTPDir                   *string                      `mapstructure:"http_directory" cty:"http_directory" hcl:"http_directory"`
+	HTTPContent               map[string]string            `mapstructure:"http_content" cty:"http_content" hcl:"http_content"`
+	HTTPPortMin               *int                         `mapstructure:"http_port_min" cty:"http_port_min" hcl:"http_port_min"`
+	HTTPPortMax               *int                         `mapstructure:"http_port_max" cty:"http_port_max" hcl:"http_port_max"`
+	HTTPAddress               *string                      `mapstructure:"http_bind_address" cty:"http_bind_address" hcl:"http_bind_address"`
+	HTTPInterface             *string                      `mapstructure:"http_interface" undocumented:"true" cty:"http_interface" hcl:"http_interface"`
+	HTTPNetworkProtocol       *string                      `mapstructure:"http_network_protocol" cty:"http_network_protocol" hcl:"http_network_protocol"`
+	Type                      *string                      `mapstructure:"communicator" cty:"communicator" hcl:"communicator"`
+	PauseBeforeConnect        *string                      `mapstructure:"pause_before_connecting" cty:"pause_before_connecting" hcl:"pause_before_connecting"`
+	SSHHost                   *string                      `mapstructure:"ssh_host" cty:"ssh_host" hcl:"ssh_host"`
+	SSHPort                   *int                         `mapstructure:"ssh_port" cty:"ssh_port" hcl:"ssh_port"`
+	SSHUsername               *string                      `mapstructure:"ssh_username" cty:"ssh_username" hcl:"ssh_username"`
+	SSHPassword               *string                      `mapstructure:"ssh_password" cty:"ssh_password" hcl:"ssh_password"`
+	SSHKeyPairName            *string                      `mapstructure:"ssh_keypair_name" undocumented:"true" cty:"ssh_keypair_name" hcl:"ssh_keypair_name"`
+	SSHTemporaryKeyPairName   *string                      `mapstructure:"temporary_key_pair_name" undocumented:"true" cty:"temporary_key_pair_name" hcl:"temporary_key_pair_name"`
+	SSHTemporaryKeyPairType   *string                      `mapstructure:"temporary_key_pair_type" cty:"temporary_key_pair_type" hcl:"temporary_key_pair_type"`
+	SSHTemporaryKeyPairBits   *int                         `mapstructure:"temporary_key_pair_bits" cty:"temporary_key_pair_bits" hcl:"temporary_key_pair_bits"`
+	SSHCiphers                []string                     `mapstructure:"ssh_ciphers" cty:"ssh_ciphers" hcl:"ssh_ciphers"`
+	SSHClearAuthorizedKeys    *bool                        `mapstructure:"ssh_clear_authorized_keys" cty:"ssh_clear_authorized_keys" hcl:"ssh_clear_authorized_keys"`
+	SSHKEXAlgos               []string                     `mapstructure:"ssh_key_exchange_algorithms" cty:"ssh_key_exchange_algorithms" hcl:"ssh_key_exchange_algorithms"`
+	SSHPrivateKeyFile         *string                      `mapstructure:"ssh_private_key_file" undocumented:"true" cty:"ssh_private_key_file" hcl:"ssh_private_key_file"`
+	SSHCertificateFile        *string                      `mapstructure:"ssh_certificate_file" cty:"ssh_certificate_file" hcl:"ssh_certificate_file"`
+	SSHPty                    *bool                        `mapstructure:"ssh_pty" cty:"ssh_pty" hcl:"ssh_pty"`
+	SSHTimeout                *string                      `mapstructure:"ssh_timeout" cty:"ssh_timeout" hcl:"ssh_timeout"`
+	SSHWaitTimeout            *string                      `mapstructure:"ssh_wait_timeout" undocumented:"true" cty:"ssh_wait_timeout" hcl:"ssh_wait_timeout"`
+	SSHAgentAuth              *bool                        `mapstructure:"ssh_agent_auth" undocumented:"true" cty:"ssh_agent_auth" hcl:"ssh_agent_auth"`
+	SSHDisableAgentForwarding *bool                        `mapstructure:"ssh_disable_agent_forwarding" cty:"ssh_disable_agent_forwarding" hcl:"ssh_disable_agent_forwarding"`
+	SSHHandshakeAttempts      *int                         `mapstructure:"ssh_handshake_attempts" cty:"ssh_handshake_attempts" hcl:"ssh_handshake_attempts"`
+	SSHBastionHost            *string                      `mapstructure:"ssh_bastion_host" cty:"ssh_bastion_host" hcl:"ssh_bastion_host"`
+	SSHBastionPort            *int                         `mapstructure:"ssh_bastion_port" cty:"ssh_bastion_port" hcl:"ssh_bastion_port"`
+	SSHBastionAgentAuth       *bool                        `mapstructure:"ssh_bastion_agent_auth" cty:"ssh_bastion_agent_auth" hcl:"ssh_bastion_agent_auth"`
+	SSHBastionUsername        *string                      `mapstructure:"ssh_bastion_username" cty:"ssh_bastion_username" hcl:"ssh_bastion_username"`
+	SSHBastionPassword        *string                      `mapstructure:"ssh_bastion_password" cty:"ssh_bastion_password" hcl:"ssh_bastion_password"`
+	SSHBastionInteractive     *bool                        `mapstructure:"ssh_bastion_interactive" cty:"ssh_bastion_interactive" hcl:"ssh_bastion_interactive"`
+	SSHBastionPrivateKeyFile  *string                      `mapstructure:"ssh_bastion_private_key_file" cty:"ssh_bastion_private_key_file" hcl:"ssh_bastion_private_key_file"`
+	SSHBastionCertificateFile *string                      `mapstructure:"ssh_bastion_certificate_file" cty:"ssh_bastion_certificate_file" hcl:"ssh_bastion_certificate_file"`
+	SSHFileTransferMethod     *string                      `mapstructure:"ssh_file_transfer_method" cty:"ssh_file_transfer_method" hcl:"ssh_file_transfer_method"`
+	SSHProxyHost              *string                      `mapstructure:"ssh_proxy_host" cty:"ssh_proxy_host" hcl:"ssh_proxy_host"`
+	SSHProxyPort              *int                         `mapstructure:"ssh_proxy_port" cty:"ssh_proxy_port" hcl:"ssh_proxy_port"`
+	SSHProxyUsername          *string                      `mapstructure:"ssh_proxy_username" cty:"ssh_proxy_username" hcl:"ssh_proxy_username"`
+	SSHProxyPassword          *string                      `mapstructure:"ssh_proxy_password" cty:"ssh_proxy_password" hcl:"ssh_proxy_password"`
+	SSHKeepAliveInterval      *string                      `mapstructure:"ssh_keep_alive_interval" cty:"ssh_keep_alive_interval" hcl:"ssh_keep_alive_interval"`
+	SSHReadWriteTimeout       *string                      `mapstructure:"ssh_read_write_timeout" cty:"ssh_read_write_timeout" hcl:"ssh_read_write_timeout"`
+	SSHRemoteTunnels          []string                     `mapstructure:"ssh_remote_tunnels" cty:"ssh_remote_tunnels" hcl:"ssh_remote_tunnels"`
+	SSHLocalTunnels           []string                     `mapstructure:"ssh_local_tunnels" cty:"ssh_local_tunnels" hcl:"ssh_local_tunnels"`
+	SSHPublicKey              []byte                       `mapstructure:"ssh_public_key" undocumented:"true" cty:"ssh_public_key" hcl:"ssh_public_key"`
+	SSHPrivateKey             []byte                       `mapstructure:"ssh_private_key" undocumented:"true" cty:"ssh_private_key" hcl:"ssh_private_key"`
+	WinRMUser                 *string                      `mapstructure:"winrm_username" cty:"winrm_username" hcl:"winrm_username"`
+	WinRMPassword             *string                      `mapstructure:"winrm_password" cty:"winrm_password" hcl:"winrm_password"`
+	WinRMHost                 *string                      `mapstructure:"winrm_host" cty:"winrm_host" hcl:"winrm_host"`
+	WinRMNoProxy              *bool                        `mapstructure:"winrm_no_proxy" cty:"winrm_no_proxy" hcl:"winrm_no_proxy"`
+	WinRMPort                 *int                         `mapstructure:"winrm_port" cty:"winrm_port" hcl:"winrm_port"`
+	WinRMTimeout              *string                      `mapstructure:"winrm_timeout" cty:"winrm_timeout" hcl:"winrm_timeout"`
+	WinRMUseSSL               *bool                        `mapstructure:"winrm_use_ssl" cty:"winrm_use_ssl" hcl:"winrm_use_ssl"`
+	WinRMInsecure             *bool                        `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
+	WinRMUseNTLM              *bool                        `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
+	Username                  *string                      `mapstructure:"username" cty:"username" hcl:"username"`
+	Password                  *string                      `mapstructure:"password" cty:"password" hcl:"password"`
+	Token                     *string                      `mapstructure:"token" cty:"token" hcl:"token"`
+	APIURL                    *string                      `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	HTTPProxy                 *string                      `mapstructure:"http_proxy" cty:"http_proxy" hcl:"http_proxy"`
+	CABundleFile              *string                      `mapstructure:"ca_bundle_file" cty:"ca_bundle_file" hcl:"ca_bundle_file"`
+	APIDebugLog               *string                      `mapstructure:"api_debug_log" cty:"api_debug_log" hcl:"api_debug_log"`
+	Zone                      *string                      `mapstructure:"zone" required:"true" cty:"zone" hcl:"zone"`
+	ServerPlan                *string                      `mapstructure:"server_plan" cty:"server_plan" hcl:"server_plan"`
+	CoreNumber                *int                         `mapstructure:"core_number" cty:"core_number" hcl:"core_number"`
+	MemoryAmount              *int                         `mapstructure:"memory_amount" cty:"memory_amount" hcl:"memory_amount"`
+	MinCPU                    *int                         `mapstructure:"min_cpu" cty:"min_cpu" hcl:"min_cpu"`
+	MinMemoryGB               *int                         `mapstructure:"min_memory_gb" cty:"min_memory_gb" hcl:"min_memory_gb"`
+	MaxHourlyPrice            *float64                     `mapstructure:"max_hourly_price" cty:"max_hourly_price" hcl:"max_hourly_price"`
+	AllowDevPlans             *bool                        `mapstructure:"allow_dev_plans" cty:"allow_dev_plans" hcl:"allow_dev_plans"`
+	AllowGPUPlans             *bool                        `mapstructure:"allow_gpu_plans" cty:"allow_gpu_plans" hcl:"allow_gpu_plans"`
+	StorageUUID               *string                      `mapstructure:"storage_uuid" required:"true" cty:"storage_uuid" hcl:"storage_uuid"`
+	StorageName               *string                      `mapstructure:"storage_name" cty:"storage_name" hcl:"storage_name"`
+	SourceStorageFilter       *FlatStorageFilter           `mapstructure:"source_storage_filter" cty:"source_storage_filter" hcl:"source_storage_filter"`
+	TemplatePrefix            *string                      `mapstructure:"template_prefix" cty:"template_prefix" hcl:"template_prefix"`
+	TemplateName              *string                      `mapstructure:"template_name" cty:"template_name" hcl:"template_name"`
+	ServerLabels              map[string]string            `mapstructure:"server_labels" cty:"server_labels" hcl:"server_labels"`
+	TemplateLabels            map[string]string            `mapstructure:"template_labels" cty:"template_labels" hcl:"template_labels"`
+	StorageSize               *int                         `mapstructure:"storage_size" cty:"storage_size" hcl:"storage_size"`
+	StorageTier               *string                      `mapstructure:"storage_tier" cty:"storage_tier" hcl:"storage_tier"`
+	StorageDevices            []FlatStorageDevice          `mapstructure:"storage_device" cty:"storage_device" hcl:"storage_device"`
+	TemplateAllDisks          *bool                        `mapstructure:"template_all_disks" cty:"template_all_disks" hcl:"template_all_disks"`
+	StorageEncryption         *bool                        `mapstructure:"storage_encryption" cty:"storage_encryption" hcl:"storage_encryption"`
+	Timeout                   *string                      `mapstructure:"state_timeout_duration" cty:"state_timeout_duration" hcl:"state_timeout_duration"`
+	BootWait                  *string                      `mapstructure:"boot_wait" cty:"boot_wait" hcl:"boot_wait"`
+	ISOStorageUUID            *string                      `mapstructure:"iso_storage_uuid" cty:"iso_storage_uuid" hcl:"iso_storage_uuid"`
+	ISOStorageName            *string                      `mapstructure:"iso_storage_name" cty:"iso_storage_name" hcl:"iso_storage_name"`
+	BootCommand               []string                     `mapstructure:"boot_command" cty:"boot_command" hcl:"boot_command"`
+	BootKeyInterval           *string                      `mapstructure:"boot_key_interval" cty:"boot_key_interval" hcl:"boot_key_interval"`
+	HTTPIP                    *string                      `mapstructure:"http_ip" cty:"http_ip" hcl:"http_ip"`
+	CloneZones                []string                     `mapstructure:"clone_zones" cty:"clone_zones" hcl:"clone_zones"`
+	MaxEstimatedCost          *float64                     `mapstructure:"max_estimated_cost" cty:"max_estimated_cost" hcl:"max_estimated_cost"`
+	FallbackZones             []string                     `mapstructure:"fallback_zones" cty:"fallback_zones" hcl:"fallback_zones"`
+	FallbackPlans             []string                     `mapstructure:"fallback_plans" cty:"fallback_plans" hcl:"fallback_plans"`
+	NetworkInterfaces         []FlatNetworkInterface       `mapstructure:"network_interfaces" cty:"network_interfaces" hcl:"network_interfaces"`
+	TemporaryPrivateNetwork   *FlatTemporaryPrivateNetwork `mapstructure:"temporary_private_network" cty:"temporary_private_network" hcl:"temporary_private_network"`
+	TemporaryFirewall         *FlatTemporaryFirewall       `mapstructure:"temporary_firewall" cty:"temporary_firewall" hcl:"temporary_firewall"`
+	FloatingIP                *FlatFloatingIP              `mapstructure:"floating_ip" cty:"floating_ip" hcl:"floating_ip"`
+	UserData                  *string                      `mapstructure:"user_data" cty:"user_data" hcl:"user_data"`
+	UserDataFile              *string                      `mapstructure:"user_data_file" cty:"user_data_file" hcl:"user_data_file"`
+	CloudInitTimeout          *string                      `mapstructure:"cloud_init_timeout" cty:"cloud_init_timeout" hcl:"cloud_init_timeout"`
+	Metadata                  *bool                        `mapstructure:"metadata" cty:"metadata" hcl:"metadata"`
+	JournalDir                *string                      `mapstructure:"journal_dir" cty:"journal_dir" hcl:"journal_dir"`
+	SSHPrivateKeyPath         *string                      `mapstructure:"ssh_private_key_path" cty:"ssh_private_key_path" hcl:"ssh_private_key_path"`
+	SSHPublicKeyPath          *string                      `mapstructure:"ssh_public_key_path" cty:"ssh_public_key_path" hcl:"ssh_public_key_path"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -106,6 +146,13 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"packer_on_error":              &hcldec.AttrSpec{Name: "packer_on_error", Type: cty.String, Required: false},
 		"packer_user_variables":        &hcldec.AttrSpec{Name: "packer_user_variables", Type: cty.Map(cty.String), Required: false},
 		"packer_sensitive_variables":   &hcldec.AttrSpec{Name: "packer_sensitive_variables", Type: cty.List(cty.String), Required: false},
+		"http_directory":               &hcldec.AttrSpec{Name: "http_directory", Type: cty.String, Required: false},
+		"http_content":                 &hcldec.AttrSpec{Name: "http_content", Type: cty.Map(cty.String), Required: false},
+		"http_port_min":                &hcldec.AttrSpec{Name: "http_port_min", Type: cty.Number, Required: false},
+		"http_port_max":                &hcldec.AttrSpec{Name: "http_port_max", Type: cty.Number, Required: false},
+		"http_bind_address":            &hcldec.AttrSpec{Name: "http_bind_address", Type: cty.String, Required: false},
+		"http_interface":               &hcldec.AttrSpec{Name: "http_interface", Type: cty.String, Required: false},
+		"http_network_protocol":        &hcldec.AttrSpec{Name: "http_network_protocol", Type: cty.String, Required: false},
 		"communicator":                 &hcldec.AttrSpec{Name: "communicator", Type: cty.String, Required: false},
 		"pause_before_connecting":      &hcldec.AttrSpec{Name: "pause_before_connecting", Type: cty.String, Required: false},
 		"ssh_host":                     &hcldec.AttrSpec{Name: "ssh_host", Type: cty.String, Required: false},
@@ -158,24 +205,119 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"username":                     &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
 		"password":                     &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
 		"token":                        &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"api_url":                      &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"http_proxy":                   &hcldec.AttrSpec{Name: "http_proxy", Type: cty.String, Required: false},
+		"ca_bundle_file":               &hcldec.AttrSpec{Name: "ca_bundle_file", Type: cty.String, Required: false},
+		"api_debug_log":                &hcldec.AttrSpec{Name: "api_debug_log", Type: cty.String, Required: false},
 		"zone":                         &hcldec.AttrSpec{Name: "zone", Type: cty.String, Required: false},
 		"server_plan":                  &hcldec.AttrSpec{Name: "server_plan", Type: cty.String, Required: false},
+		"core_number":                  &hcldec.AttrSpec{Name: "core_number", Type: cty.Number, Required: false},
+		"memory_amount":                &hcldec.AttrSpec{Name: "memory_amount", Type: cty.Number, Required: false},
+		"min_cpu":                      &hcldec.AttrSpec{Name: "min_cpu", Type: cty.Number, Required: false},
+		"min_memory_gb":                &hcldec.AttrSpec{Name: "min_memory_gb", Type: cty.Number, Required: false},
+		"max_hourly_price":             &hcldec.AttrSpec{Name: "max_hourly_price", Type: cty.Number, Required: false},
+		"allow_dev_plans":              &hcldec.AttrSpec{Name: "allow_dev_plans", Type: cty.Bool, Required: false},
+		"allow_gpu_plans":              &hcldec.AttrSpec{Name: "allow_gpu_plans", Type: cty.Bool, Required: false},
 		"storage_uuid":                 &hcldec.AttrSpec{Name: "storage_uuid", Type: cty.String, Required: false},
 		"storage_name":                 &hcldec.AttrSpec{Name: "storage_name", Type: cty.String, Required: false},
+		"source_storage_filter":        &hcldec.BlockSpec{TypeName: "source_storage_filter", Nested: hcldec.ObjectSpec((*FlatStorageFilter)(nil).HCL2Spec())},
 		"template_prefix":              &hcldec.AttrSpec{Name: "template_prefix", Type: cty.String, Required: false},
 		"template_name":                &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
+		"server_labels":                &hcldec.AttrSpec{Name: "server_labels", Type: cty.Map(cty.String), Required: false},
+		"template_labels":              &hcldec.AttrSpec{Name: "template_labels", Type: cty.Map(cty.String), Required: false},
 		"storage_size":                 &hcldec.AttrSpec{Name: "storage_size", Type: cty.Number, Required: false},
 		"storage_tier":                 &hcldec.AttrSpec{Name: "storage_tier", Type: cty.String, Required: false},
+		"storage_device":               &hcldec.BlockListSpec{TypeName: "storage_device", Nested: hcldec.ObjectSpec((*FlatStorageDevice)(nil).HCL2Spec())},
+		"template_all_disks":           &hcldec.AttrSpec{Name: "template_all_disks", Type: cty.Bool, Required: false},
+		"storage_encryption":           &hcldec.AttrSpec{Name: "storage_encryption", Type: cty.Bool, Required: false},
 		"state_timeout_duration":       &hcldec.AttrSpec{Name: "state_timeout_duration", Type: cty.String, Required: false},
 		"boot_wait":                    &hcldec.AttrSpec{Name: "boot_wait", Type: cty.String, Required: false},
+		"iso_storage_uuid":             &hcldec.AttrSpec{Name: "iso_storage_uuid", Type: cty.String, Required: false},
+		"iso_storage_name":             &hcldec.AttrSpec{Name: "iso_storage_name", Type: cty.String, Required: false},
+		"boot_command":                 &hcldec.AttrSpec{Name: "boot_command", Type: cty.List(cty.String), Required: false},
+		"boot_key_interval":            &hcldec.AttrSpec{Name: "boot_key_interval", Type: cty.String, Required: false},
+		"http_ip":                      &hcldec.AttrSpec{Name: "http_ip", Type: cty.String, Required: false},
 		"clone_zones":                  &hcldec.AttrSpec{Name: "clone_zones", Type: cty.List(cty.String), Required: false},
+		"max_estimated_cost":           &hcldec.AttrSpec{Name: "max_estimated_cost", Type: cty.Number, Required: false},
+		"fallback_zones":               &hcldec.AttrSpec{Name: "fallback_zones", Type: cty.List(cty.String), Required: false},
+		"fallback_plans":               &hcldec.AttrSpec{Name: "fallback_plans", Type: cty.List(cty.String), Required: false},
 		"network_interfaces":           &hcldec.BlockListSpec{TypeName: "network_interfaces", Nested: hcldec.ObjectSpec((*FlatNetworkInterface)(nil).HCL2Spec())},
+		"temporary_private_network":    &hcldec.BlockSpec{TypeName: "temporary_private_network", Nested: hcldec.ObjectSpec((*FlatTemporaryPrivateNetwork)(nil).HCL2Spec())},
+		"temporary_firewall":           &hcldec.BlockSpec{TypeName: "temporary_firewall", Nested: hcldec.ObjectSpec((*FlatTemporaryFirewall)(nil).HCL2Spec())},
+		"floating_ip":                  &hcldec.BlockSpec{TypeName: "floating_ip", Nested: hcldec.ObjectSpec((*FlatFloatingIP)(nil).HCL2Spec())},
+		"user_data":                    &hcldec.AttrSpec{Name: "user_data", Type: cty.String, Required: false},
+		"user_data_file":               &hcldec.AttrSpec{Name: "user_data_file", Type: cty.String, Required: false},
+		"cloud_init_timeout":           &hcldec.AttrSpec{Name: "cloud_init_timeout", Type: cty.String, Required: false},
+		"metadata":                     &hcldec.AttrSpec{Name: "metadata", Type: cty.Bool, Required: false},
+		"journal_dir":                  &hcldec.AttrSpec{Name: "journal_dir", Type: cty.String, Required: false},
 		"ssh_private_key_path":         &hcldec.AttrSpec{Name: "ssh_private_key_path", Type: cty.String, Required: false},
 		"ssh_public_key_path":          &hcldec.AttrSpec{Name: "ssh_public_key_path", Type: cty.String, Required: false},
 	}
 	return s
 }
 
+// FlatFirewallRule is an auto-generated flat version of FirewallRule.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatFirewallRule struct {
+	Action               *string `mapstructure:"action" cty:"action" hcl:"action"`
+	Direction            *string `mapstructure:"direction" cty:"direction" hcl:"direction"`
+	Family               *string `mapstructure:"family" cty:"family" hcl:"family"`
+	Protocol             *string `mapstructure:"protocol" cty:"protocol" hcl:"protocol"`
+	SourceCIDR           *string `mapstructure:"source_cidr" cty:"source_cidr" hcl:"source_cidr"`
+	DestinationPortStart *int    `mapstructure:"destination_port_start" cty:"destination_port_start" hcl:"destination_port_start"`
+	DestinationPortEnd   *int    `mapstructure:"destination_port_end" cty:"destination_port_end" hcl:"destination_port_end"`
+	Comment              *string `mapstructure:"comment" cty:"comment" hcl:"comment"`
+}
+
+// FlatMapstructure returns a new FlatFirewallRule.
+// FlatFirewallRule is an auto-generated flat version of FirewallRule.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*FirewallRule) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatFirewallRule)
+}
+
+// HCL2Spec returns the hcl spec of a FirewallRule.
+// This spec is used by HCL to read the fields of FirewallRule.
+// The decoded values from this spec will then be applied to a FlatFirewallRule.
+func (*FlatFirewallRule) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"action":                 &hcldec.AttrSpec{Name: "action", Type: cty.String, Required: false},
+		"direction":              &hcldec.AttrSpec{Name: "direction", Type: cty.String, Required: false},
+		"family":                 &hcldec.AttrSpec{Name: "family", Type: cty.String, Required: false},
+		"protocol":               &hcldec.AttrSpec{Name: "protocol", Type: cty.String, Required: false},
+		"source_cidr":            &hcldec.AttrSpec{Name: "source_cidr", Type: cty.String, Required: false},
+		"destination_port_start": &hcldec.AttrSpec{Name: "destination_port_start", Type: cty.Number, Required: false},
+		"destination_port_end":   &hcldec.AttrSpec{Name: "destination_port_end", Type: cty.Number, Required: false},
+		"comment":                &hcldec.AttrSpec{Name: "comment", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatFloatingIP is an auto-generated flat version of FloatingIP.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatFloatingIP struct {
+	Address            *string `mapstructure:"address" cty:"address" hcl:"address"`
+	UseForCommunicator *bool   `mapstructure:"use_for_communicator" cty:"use_for_communicator" hcl:"use_for_communicator"`
+}
+
+// FlatMapstructure returns a new FlatFloatingIP.
+// FlatFloatingIP is an auto-generated flat version of FloatingIP.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*FloatingIP) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatFloatingIP)
+}
+
+// HCL2Spec returns the hcl spec of a FloatingIP.
+// This spec is used by HCL to read the fields of FloatingIP.
+// The decoded values from this spec will then be applied to a FlatFloatingIP.
+func (*FlatFloatingIP) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"address":              &hcldec.AttrSpec{Name: "address", Type: cty.String, Required: false},
+		"use_for_communicator": &hcldec.AttrSpec{Name: "use_for_communicator", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
 // FlatIPAddress is an auto-generated flat version of IPAddress.
 // Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
 type FlatIPAddress struct {
@@ -229,3 +371,123 @@ func (*FlatNetworkInterface) HCL2Spec() map[string]hcldec.Spec {
 	}
 	return s
 }
+
+// FlatStorageDevice is an auto-generated flat version of StorageDevice.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatStorageDevice struct {
+	Size       *int    `mapstructure:"size" cty:"size" hcl:"size"`
+	Tier       *string `mapstructure:"tier" cty:"tier" hcl:"tier"`
+	Title      *string `mapstructure:"title" cty:"title" hcl:"title"`
+	Action     *string `mapstructure:"action" cty:"action" hcl:"action"`
+	SourceUUID *string `mapstructure:"source_uuid" cty:"source_uuid" hcl:"source_uuid"`
+	Template   *bool   `mapstructure:"template" cty:"template" hcl:"template"`
+}
+
+// FlatMapstructure returns a new FlatStorageDevice.
+// FlatStorageDevice is an auto-generated flat version of StorageDevice.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*StorageDevice) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatStorageDevice)
+}
+
+// HCL2Spec returns the hcl spec of a StorageDevice.
+// This spec is used by HCL to read the fields of StorageDevice.
+// The decoded values from this spec will then be applied to a FlatStorageDevice.
+func (*FlatStorageDevice) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"size":        &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: false},
+		"tier":        &hcldec.AttrSpec{Name: "tier", Type: cty.String, Required: false},
+		"title":       &hcldec.AttrSpec{Name: "title", Type: cty.String, Required: false},
+		"action":      &hcldec.AttrSpec{Name: "action", Type: cty.String, Required: false},
+		"source_uuid": &hcldec.AttrSpec{Name: "source_uuid", Type: cty.String, Required: false},
+		"template":    &hcldec.AttrSpec{Name: "template", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatStorageFilter is an auto-generated flat version of StorageFilter.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatStorageFilter struct {
+	Name       *string           `mapstructure:"name" cty:"name" hcl:"name"`
+	NameRegex  *string           `mapstructure:"name_regex" cty:"name_regex" hcl:"name_regex"`
+	Access     *string           `mapstructure:"access" cty:"access" hcl:"access"`
+	Type       *string           `mapstructure:"type" cty:"type" hcl:"type"`
+	Zone       *string           `mapstructure:"zone" cty:"zone" hcl:"zone"`
+	Labels     map[string]string `mapstructure:"labels" cty:"labels" hcl:"labels"`
+	MostRecent *bool             `mapstructure:"most_recent" cty:"most_recent" hcl:"most_recent"`
+}
+
+// FlatMapstructure returns a new FlatStorageFilter.
+// FlatStorageFilter is an auto-generated flat version of StorageFilter.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*StorageFilter) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatStorageFilter)
+}
+
+// HCL2Spec returns the hcl spec of a StorageFilter.
+// This spec is used by HCL to read the fields of StorageFilter.
+// The decoded values from this spec will then be applied to a FlatStorageFilter.
+func (*FlatStorageFilter) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"name":        &hcldec.AttrSpec{Name: "name", Type: cty.String, Required: false},
+		"name_regex":  &hcldec.AttrSpec{Name: "name_regex", Type: cty.String, Required: false},
+		"access":      &hcldec.AttrSpec{Name: "access", Type: cty.String, Required: false},
+		"type":        &hcldec.AttrSpec{Name: "type", Type: cty.String, Required: false},
+		"zone":        &hcldec.AttrSpec{Name: "zone", Type: cty.String, Required: false},
+		"labels":      &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
+		"most_recent": &hcldec.AttrSpec{Name: "most_recent", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatTemporaryFirewall is an auto-generated flat version of TemporaryFirewall.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatTemporaryFirewall struct {
+	AllowedCIDRs []string           `mapstructure:"allowed_cidrs" cty:"allowed_cidrs" hcl:"allowed_cidrs"`
+	Rules        []FlatFirewallRule `mapstructure:"rules" cty:"rules" hcl:"rules"`
+}
+
+// FlatMapstructure returns a new FlatTemporaryFirewall.
+// FlatTemporaryFirewall is an auto-generated flat version of TemporaryFirewall.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*TemporaryFirewall) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatTemporaryFirewall)
+}
+
+// HCL2Spec returns the hcl spec of a TemporaryFirewall.
+// This spec is used by HCL to read the fields of TemporaryFirewall.
+// The decoded values from this spec will then be applied to a FlatTemporaryFirewall.
+func (*FlatTemporaryFirewall) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"allowed_cidrs": &hcldec.AttrSpec{Name: "allowed_cidrs", Type: cty.List(cty.String), Required: false},
+		"rules":         &hcldec.BlockListSpec{TypeName: "rules", Nested: hcldec.ObjectSpec((*FlatFirewallRule)(nil).HCL2Spec())},
+	}
+	return s
+}
+
+// FlatTemporaryPrivateNetwork is an auto-generated flat version of TemporaryPrivateNetwork.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatTemporaryPrivateNetwork struct {
+	CIDR   *string `mapstructure:"cidr" required:"true" cty:"cidr" hcl:"cidr"`
+	DHCP   *bool   `mapstructure:"dhcp" cty:"dhcp" hcl:"dhcp"`
+	Router *string `mapstructure:"router" cty:"router" hcl:"router"`
+}
+
+// FlatMapstructure returns a new FlatTemporaryPrivateNetwork.
+// FlatTemporaryPrivateNetwork is an auto-generated flat version of TemporaryPrivateNetwork.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*TemporaryPrivateNetwork) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatTemporaryPrivateNetwork)
+}
+
+// HCL2Spec returns the hcl spec of a TemporaryPrivateNetwork.
+// This spec is used by HCL to read the fields of TemporaryPrivateNetwork.
+// The decoded values from this spec will then be applied to a FlatTemporaryPrivateNetwork.
+func (*FlatTemporaryPrivateNetwork) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"cidr":   &hcldec.AttrSpec{Name: "cidr", Type: cty.String, Required: false},
+		"dhcp":   &hcldec.AttrSpec{Name: "dhcp", Type: cty.Bool, Required: false},
+		"router": &hcldec.AttrSpec{Name: "router", Type: cty.String, Required: false},
+	}
+	return s
+}
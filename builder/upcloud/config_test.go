@@ -3,6 +3,7 @@
 package upcloud_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -282,6 +283,724 @@ func TestConfig_Prepare_Defaults(t *testing.T) {
 	assert.Equal(t, "root", c.Comm.SSHUsername)
 }
 
+func TestConfig_Prepare_WinRM(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "Windows Server 2022 Standard",
+			"communicator": "winrm",
+		},
+	}
+
+	warns, err := c.Prepare(raws...)
+	assert.NoError(t, err)
+	assert.Empty(t, warns)
+	assert.Equal(t, "winrm", c.Comm.Type)
+	assert.Equal(t, "Administrator", c.Comm.WinRMUser)
+	assert.Empty(t, c.Comm.SSHUsername)
+}
+
+func TestConfig_Prepare_WinRMPassword(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":       "testuser",
+			"password":       "testpass",
+			"zone":           "fi-hel1",
+			"storage_name":   "Windows Server 2022 Standard",
+			"communicator":   "winrm",
+			"winrm_password": "secret",
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'winrm_password' cannot be set")
+}
+
+func TestConfig_Prepare_ISO(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":          "testuser",
+			"password":          "testpass",
+			"zone":              "fi-hel1",
+			"iso_storage_name":  "Debian GNU/Linux 12 (Bookworm) Installation CD",
+			"http_directory":    "http",
+			"http_ip":           "203.0.113.10",
+			"boot_command":      []string{"<esc><wait>", "auto url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg<enter>"},
+			"boot_key_interval": "10ms",
+			"ssh_password":      "packer",
+		},
+	}
+
+	warns, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.Empty(t, warns)
+	assert.True(t, c.ISOMode())
+	assert.Equal(t, 10*time.Millisecond, c.BootKeyInterval)
+	assert.Equal(t, "<esc><wait>auto url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg<enter>", c.FlatBootCommand())
+}
+
+func TestConfig_Prepare_ISOHTTPIP(t *testing.T) {
+	t.Parallel()
+	raw := map[string]interface{}{
+		"username":         "testuser",
+		"password":         "testpass",
+		"zone":             "fi-hel1",
+		"iso_storage_name": "Debian GNU/Linux 12 (Bookworm) Installation CD",
+		"http_directory":   "http",
+		"boot_command":     []string{"auto url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg<enter>"},
+		"ssh_password":     "packer",
+	}
+
+	c := &upcloud.Config{}
+	_, err := c.Prepare(raw)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'http_ip' or 'http_bind_address' must be set")
+
+	raw["http_bind_address"] = "0.0.0.0"
+	c = &upcloud.Config{}
+	_, err = c.Prepare(raw)
+	require.Error(t, err, "unspecified bind address is not reachable")
+
+	raw["http_ip"] = "not an address"
+	c = &upcloud.Config{}
+	_, err = c.Prepare(raw)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid 'http_ip'")
+
+	delete(raw, "http_ip")
+	raw["http_bind_address"] = "203.0.113.10"
+	c = &upcloud.Config{}
+	_, err = c.Prepare(raw)
+	require.NoError(t, err)
+}
+
+func TestConfig_Prepare_ISOSSHCredentials(t *testing.T) {
+	t.Parallel()
+	raw := map[string]interface{}{
+		"username":         "testuser",
+		"password":         "testpass",
+		"zone":             "fi-hel1",
+		"iso_storage_uuid": "01000000-0000-4000-8000-000000000000",
+	}
+
+	c := &upcloud.Config{}
+	_, err := c.Prepare(raw)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'ssh_password' or 'ssh_private_key_file' must be set when installing from ISO image")
+
+	raw["communicator"] = "none"
+	c = &upcloud.Config{}
+	_, err = c.Prepare(raw)
+	require.NoError(t, err)
+}
+
+func TestConfig_Prepare_ISOAndTemplate(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":         "testuser",
+			"password":         "testpass",
+			"zone":             "fi-hel1",
+			"storage_name":     "ubuntu-server-24.04",
+			"iso_storage_uuid": "01000000-0000-4000-8000-000000000000",
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "you can either use 'storage_uuid'/'storage_name'/'source_storage_filter' or 'iso_storage_uuid'/'iso_storage_name'")
+}
+
+func TestConfig_Prepare_InvalidBootCommand(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":         "testuser",
+			"password":         "testpass",
+			"zone":             "fi-hel1",
+			"iso_storage_uuid": "01000000-0000-4000-8000-000000000000",
+			"boot_command":     []string{"<wait-1m>"},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+}
+
+func TestConfig_Prepare_SourceStorageFilter(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username": "testuser",
+			"password": "testpass",
+			"zone":     "fi-hel1",
+			"source_storage_filter": map[string]interface{}{
+				"name_regex":  "^ubuntu-server-24\\.04",
+				"access":      "private",
+				"labels":      map[string]string{"os": "ubuntu"},
+				"most_recent": true,
+			},
+		},
+	}
+
+	warns, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.Empty(t, warns)
+	require.NotNil(t, c.SourceStorageFilter)
+	assert.Equal(t, "template", c.SourceStorageFilter.Type)
+	assert.True(t, c.SourceStorageFilter.MostRecent)
+	assert.Equal(t, map[string]string{"os": "ubuntu"}, c.SourceStorageFilter.Labels)
+}
+
+func TestConfig_Prepare_SourceStorageFilterInvalid(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"source_storage_filter": map[string]interface{}{
+				"name_regex": "(",
+				"access":     "shared",
+				"type":       "cdrom",
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "you can either use 'storage_uuid'/'storage_name' or 'source_storage_filter'")
+	assert.Contains(t, err.Error(), "invalid 'source_storage_filter' 'name_regex'")
+	assert.Contains(t, err.Error(), "invalid 'source_storage_filter' 'access'")
+	assert.Contains(t, err.Error(), "invalid 'source_storage_filter' 'type'")
+}
+
+func TestConfig_Prepare_SourceStorageFilterEmpty(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":              "testuser",
+			"password":              "testpass",
+			"zone":                  "fi-hel1",
+			"source_storage_filter": map[string]interface{}{"zone": "fi-hel1"},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "requires at least one of 'name', 'name_regex' or 'labels'")
+}
+
+func TestConfig_Prepare_Labels(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":        "testuser",
+			"password":        "testpass",
+			"zone":            "fi-hel1",
+			"storage_name":    "ubuntu",
+			"server_labels":   map[string]string{"team": "platform"},
+			"template_labels": map[string]string{"os": "ubuntu", "packer-source-uuid": "custom"},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid 'template_labels': label key "packer-source-uuid" is reserved`)
+	assert.NotContains(t, err.Error(), "server_labels")
+}
+
+func TestConfig_Prepare_TemporaryPrivateNetwork(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":                  "testuser",
+			"password":                  "testpass",
+			"zone":                      "fi-hel1",
+			"storage_name":              "ubuntu",
+			"temporary_private_network": map[string]interface{}{"cidr": "10.0.0.0/24"},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	require.NotNil(t, c.TemporaryPrivateNetwork)
+	require.NotNil(t, c.TemporaryPrivateNetwork.DHCP)
+	assert.True(t, *c.TemporaryPrivateNetwork.DHCP)
+}
+
+func TestConfig_Prepare_TemporaryPrivateNetworkInvalid(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"temporary_private_network": map[string]interface{}{
+				"cidr":   "10.0.0.0",
+				"dhcp":   false,
+				"router": "router",
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid 'temporary_private_network' 'cidr' "10.0.0.0"`)
+	assert.Contains(t, err.Error(), `invalid 'temporary_private_network' 'router' UUID "router"`)
+}
+
+func TestConfig_Prepare_TemporaryFirewall(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"temporary_firewall": map[string]interface{}{
+				"allowed_cidrs": []string{"192.0.2.0/24", "2001:db8::1"},
+				"rules": []map[string]interface{}{
+					{"source_cidr": "198.51.100.0/24", "protocol": "tcp", "destination_port_start": 443},
+				},
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	require.Len(t, c.TemporaryFirewall.Rules, 1)
+	rule := c.TemporaryFirewall.Rules[0]
+	assert.Equal(t, "accept", rule.Action)
+	assert.Equal(t, "in", rule.Direction)
+	assert.Equal(t, "IPv4", rule.Family)
+	assert.Equal(t, 443, rule.DestinationPortEnd)
+}
+
+func TestConfig_Prepare_TemporaryFirewallInvalid(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"temporary_firewall": map[string]interface{}{
+				"allowed_cidrs": []string{"192.0.2.0/33"},
+				"rules": []map[string]interface{}{
+					{"action": "allow", "destination_port_start": 22},
+					{"family": "IPv6", "source_cidr": "192.0.2.1"},
+				},
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid 'temporary_firewall' 'allowed_cidrs': invalid IP address or network "192.0.2.0/33"`)
+	assert.Contains(t, err.Error(), `'temporary_firewall' rule 0: invalid 'action' "allow"`)
+	assert.Contains(t, err.Error(), `'temporary_firewall' rule 0: 'family' or 'source_cidr' must be specified`)
+	assert.Contains(t, err.Error(), `'temporary_firewall' rule 0: destination ports require 'tcp' or 'udp' protocol`)
+	assert.Contains(t, err.Error(), `'temporary_firewall' rule 1: 'source_cidr' "192.0.2.1" is not IPv6 address`)
+}
+
+func TestConfig_Prepare_FloatingIP(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"floating_ip":  map[string]interface{}{"address": "2001:db8::1"},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid 'floating_ip' 'address' "2001:db8::1", must be IPv4 address`)
+
+	c = &upcloud.Config{}
+	raws = []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"floating_ip":  map[string]interface{}{},
+		},
+	}
+	_, err = c.Prepare(raws...)
+	require.NoError(t, err)
+	require.NotNil(t, c.FloatingIP)
+	assert.Empty(t, c.FloatingIP.Address)
+}
+
+func TestConfig_Prepare_StorageEncryption(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":           "testuser",
+			"password":           "testpass",
+			"zone":               "fi-hel1",
+			"storage_name":       "ubuntu",
+			"storage_tier":       "archive",
+			"storage_encryption": true,
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `'storage_encryption' is not supported by 'storage_tier' "archive"`)
+}
+
+func TestConfig_Prepare_UserData(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":       "testuser",
+			"password":       "testpass",
+			"zone":           "fi-hel1",
+			"storage_name":   "ubuntu",
+			"user_data":      "#cloud-config",
+			"user_data_file": "does-not-exist.yaml",
+			"metadata":       false,
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "you can either use 'user_data' or 'user_data_file'")
+	assert.Contains(t, err.Error(), "invalid 'user_data_file'")
+	assert.Contains(t, err.Error(), "'user_data' and 'user_data_file' require 'metadata' to be enabled")
+}
+
+func TestConfig_Prepare_CloudInitMode(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"communicator": "none",
+			"user_data":    "#cloud-config",
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.True(t, c.CloudInitMode())
+	assert.Equal(t, upcloud.DefaultCloudInitTimeout, c.CloudInitTimeout)
+
+	c = &upcloud.Config{}
+	raws = []interface{}{
+		map[string]interface{}{
+			"username":           "testuser",
+			"password":           "testpass",
+			"zone":               "fi-hel1",
+			"storage_name":       "ubuntu",
+			"user_data":          "#cloud-config",
+			"cloud_init_timeout": "45m",
+		},
+	}
+
+	_, err = c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.False(t, c.CloudInitMode())
+	assert.Equal(t, 45*time.Minute, c.CloudInitTimeout)
+}
+
+func TestConfig_Prepare_StorageDevices(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"storage_tier": "standard",
+			"storage_device": []map[string]interface{}{
+				{"size": 100, "template": true},
+				{"action": "clone", "source_uuid": "01000000-0000-4000-8000-000030060200", "title": "scratch", "tier": "maxiops"},
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	require.Len(t, c.StorageDevices, 2)
+	assert.Equal(t, upcloud.StorageDevice{Size: 100, Tier: "standard", Title: "custom-disk2", Action: "create", Template: true}, c.StorageDevices[0])
+	assert.Equal(t, "maxiops", c.StorageDevices[1].Tier)
+}
+
+func TestConfig_Prepare_StorageDevicesInvalid(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":           "testuser",
+			"password":           "testpass",
+			"zone":               "fi-hel1",
+			"storage_name":       "ubuntu",
+			"storage_encryption": true,
+			"storage_device": []map[string]interface{}{
+				{"title": "custom-disk1"},
+				{"action": "clone", "tier": "hdd"},
+				{"action": "attach"},
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid 'storage_device' 1: 'size' is required when 'action' is create")
+	assert.Contains(t, err.Error(), `invalid 'storage_device' 1: 'title' "custom-disk1" is not unique`)
+	assert.Contains(t, err.Error(), "invalid 'storage_device' 2: invalid 'source_uuid'")
+	assert.Contains(t, err.Error(), `invalid 'storage_device' 2: 'storage_encryption' is not supported by 'tier' "hdd"`)
+	assert.Contains(t, err.Error(), `invalid 'storage_device' 3: invalid 'action' "attach"`)
+}
+
+func TestConfig_Prepare_StorageDevicesTemplateTitle(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":        "testuser",
+			"password":        "testpass",
+			"zone":            "fi-hel1",
+			"storage_name":    "ubuntu",
+			"template_prefix": strings.Repeat("p", 40),
+			"storage_device": []map[string]interface{}{
+				{"size": 10, "title": "data", "template": true},
+				{"size": 10, "title": "scratch-disk-not-templated"},
+				{"size": 10, "title": "data-disk-template", "template": true},
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "invalid 'storage_device' 1")
+	assert.NotContains(t, err.Error(), "invalid 'storage_device' 2")
+	assert.Contains(t, err.Error(), "invalid 'storage_device' 3: template title would be 75 characters, at most 64 allowed")
+}
+
+func TestConfig_Prepare_CustomPlan(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":      "testuser",
+			"password":      "testpass",
+			"zone":          "fi-hel1",
+			"storage_name":  "ubuntu",
+			"core_number":   8,
+			"memory_amount": 8192,
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.Equal(t, 8, c.CoreNumber)
+	assert.Equal(t, 8192, c.MemoryAmount)
+}
+
+func TestConfig_Prepare_CustomPlanInvalid(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name   string
+		fields map[string]interface{}
+		err    string
+	}{
+		{
+			name:   "server plan",
+			fields: map[string]interface{}{"server_plan": "2xCPU-4GB", "core_number": 2, "memory_amount": 4096},
+			err:    "you can either use 'server_plan' or 'core_number' and 'memory_amount'",
+		},
+		{
+			name:   "missing memory",
+			fields: map[string]interface{}{"core_number": 2},
+			err:    "'core_number' and 'memory_amount' must be used together",
+		},
+		{
+			name:   "too many cores",
+			fields: map[string]interface{}{"core_number": 32, "memory_amount": 65536},
+			err:    "invalid 'core_number' 32, must be between 1 and 20",
+		},
+		{
+			name:   "memory step",
+			fields: map[string]interface{}{"core_number": 2, "memory_amount": 1500},
+			err:    "invalid 'memory_amount' 1500, must be a multiple of 1024",
+		},
+		{
+			name:   "memory ratio",
+			fields: map[string]interface{}{"core_number": 1, "memory_amount": 16384},
+			err:    "invalid 'memory_amount' 16384, at most 8192 is allowed per core",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			raw := map[string]interface{}{
+				"username":     "testuser",
+				"password":     "testpass",
+				"zone":         "fi-hel1",
+				"storage_name": "ubuntu",
+			}
+			for k, v := range tt.fields {
+				raw[k] = v
+			}
+
+			c := &upcloud.Config{}
+			_, err := c.Prepare(raw)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestConfig_Prepare_PlanSelection(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":         "testuser",
+			"password":         "testpass",
+			"zone":             "fi-hel1",
+			"storage_name":     "ubuntu",
+			"min_cpu":          4,
+			"min_memory_gb":    8,
+			"max_hourly_price": 0.1,
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.True(t, c.PlanSelection())
+
+	c = &upcloud.Config{}
+	raws = []interface{}{
+		map[string]interface{}{
+			"username":      "testuser",
+			"password":      "testpass",
+			"zone":          "fi-hel1",
+			"storage_name":  "ubuntu",
+			"server_plan":   "2xCPU-4GB",
+			"min_memory_gb": -1,
+		},
+	}
+
+	_, err = c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "you can either use 'server_plan', 'core_number' and 'memory_amount' or 'min_cpu'")
+	assert.Contains(t, err.Error(), "invalid 'min_memory_gb' -1")
+}
+
+func TestConfig_Prepare_FallbackZones(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":       "testuser",
+			"password":       "testpass",
+			"zone":           "fi-hel1",
+			"storage_name":   "ubuntu",
+			"fallback_zones": []string{"fi-hel1", "fi-hel2"},
+			"fallback_plans": []string{"2xCPU-4GB"},
+			"temporary_private_network": map[string]interface{}{
+				"cidr": "10.0.0.0/24",
+			},
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'fallback_zones' cannot be used with 'temporary_private_network'")
+	assert.Contains(t, err.Error(), `'fallback_zones' must not contain 'zone' "fi-hel1"`)
+}
+
+func TestConfig_Prepare_MaxEstimatedCost(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":           "testuser",
+			"password":           "testpass",
+			"zone":               "fi-hel1",
+			"storage_name":       "ubuntu",
+			"max_estimated_cost": -1,
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid 'max_estimated_cost' -1")
+}
+
+func TestConfig_Prepare_Endpoint(t *testing.T) {
+	t.Parallel()
+	c := &upcloud.Config{}
+	raws := []interface{}{
+		map[string]interface{}{
+			"username":     "testuser",
+			"password":     "testpass",
+			"zone":         "fi-hel1",
+			"storage_name": "ubuntu",
+			"api_url":      "http://127.0.0.1:8080",
+			"http_proxy":   "http://proxy.example.com:3128",
+		},
+	}
+
+	_, err := c.Prepare(raws...)
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", c.APIURL)
+	assert.Equal(t, "http://proxy.example.com:3128", c.HTTPProxy)
+
+	c = &upcloud.Config{}
+	raws = []interface{}{
+		map[string]interface{}{
+			"username":       "testuser",
+			"password":       "testpass",
+			"zone":           "fi-hel1",
+			"storage_name":   "ubuntu",
+			"http_proxy":     "proxy.example.com:3128",
+			"ca_bundle_file": "test-fixtures/missing.pem",
+		},
+	}
+
+	_, err = c.Prepare(raws...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid 'http_proxy'")
+	assert.Contains(t, err.Error(), "invalid 'ca_bundle_file'")
+}
+
 func TestConfig_setEnv_APIToken(t *testing.T) {
 	t.Setenv(driver.EnvConfigAPIToken, "test-token")
 
@@ -291,6 +1010,19 @@ func TestConfig_setEnv_APIToken(t *testing.T) {
 	assert.Equal(t, "test-token", c.Token)
 }
 
+func TestConfig_setEnv_APIURL(t *testing.T) {
+	t.Setenv(driver.EnvConfigAPIToken, "test-token")
+	t.Setenv(driver.EnvConfigAPIURL, "http://127.0.0.1:8080")
+
+	c := &upcloud.Config{}
+	require.NoError(t, c.SetEnv())
+	assert.Equal(t, "http://127.0.0.1:8080", c.APIURL)
+
+	c = &upcloud.Config{APIURL: "https://gateway.example.com"}
+	require.NoError(t, c.SetEnv())
+	assert.Equal(t, "https://gateway.example.com", c.APIURL)
+}
+
 func TestConfig_setEnv_DoesNotOverrideExisting_basic(t *testing.T) {
 	t.Setenv(driver.EnvConfigUsername, "env-user")
 	t.Setenv(driver.EnvConfigPassword, "env-pass")
@@ -0,0 +1,102 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// StepAttachFloatingIP represents the step that attaches floating IP address to the build server.
+type StepAttachFloatingIP struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepAttachFloatingIP) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config.FloatingIP == nil {
+		return multistep.ActionContinue
+	}
+
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+	serverUUID, ok := state.Get("server_uuid").(string)
+	if !ok {
+		return stepHaltWithError(state, errors.New("server_uuid is not of expected type"))
+	}
+
+	details, err := drv.GetServerDetails(ctx, serverUUID)
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+	mac, err := publicInterfaceMAC(details)
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	var ip *upcloud.IPAddress
+	if s.Config.FloatingIP.Address != "" {
+		ui.Say(fmt.Sprintf("Attaching floating IP address %s...", s.Config.FloatingIP.Address))
+		ip, err = drv.AttachFloatingIPAddress(ctx, s.Config.FloatingIP.Address, mac)
+	} else {
+		ui.Say("Creating temporary floating IP address...")
+		ip, err = drv.CreateFloatingIPAddress(ctx, details.Zone, mac)
+		state.Put("floating_ip_temporary", true)
+	}
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	state.Put("floating_ip_address", ip.Address)
+	if !s.Config.FloatingIP.UseForCommunicator {
+		ui.Say(fmt.Sprintf("Floating IP address %s attached", ip.Address))
+		return multistep.ActionContinue
+	}
+	ui.Say(fmt.Sprintf("Floating IP address %s attached, selecting it as Server IP", ip.Address))
+	state.Put("server_ip_address", &IPAddress{Address: ip.Address, Family: ip.Family})
+
+	return multistep.ActionContinue
+}
+
+// Cleanup releases temporary floating IP address or detaches the pre-reserved one.
+func (s *StepAttachFloatingIP) Cleanup(state multistep.StateBag) {
+	ctx, cancel := contextWithDefaultTimeout()
+	defer cancel()
+
+	address, ok := state.Get("floating_ip_address").(string)
+	if !ok || address == "" {
+		return
+	}
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return
+	}
+
+	if temporary, _ := state.Get("floating_ip_temporary").(bool); temporary {
+		ui.Say(fmt.Sprintf("Releasing floating IP address %s...", address))
+		if err := drv.ReleaseIPAddress(ctx, address); err != nil {
+			ui.Error(err.Error())
+		}
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Detaching floating IP address %s...", address))
+	if err := drv.DetachFloatingIPAddress(ctx, address); err != nil {
+		ui.Error(err.Error())
+	}
+}
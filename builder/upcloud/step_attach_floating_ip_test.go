@@ -0,0 +1,99 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// floatingIPDriver records floating IP calls. Calling other methods panics.
+type floatingIPDriver struct {
+	driver.Driver
+
+	calls []string
+}
+
+func (d *floatingIPDriver) GetServerDetails(_ context.Context, _ string) (*upcloud.ServerDetails, error) {
+	return &upcloud.ServerDetails{
+		Server: upcloud.Server{Zone: "fi-hel1"},
+		Networking: upcloud.ServerNetworking{
+			Interfaces: upcloud.ServerInterfaceSlice{
+				{Type: upcloud.NetworkTypeUtility, MAC: "utility", IPAddresses: upcloud.IPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+				{Type: upcloud.NetworkTypePublic, MAC: "public-v6", IPAddresses: upcloud.IPAddressSlice{{Family: upcloud.IPAddressFamilyIPv6}}},
+				{Type: upcloud.NetworkTypePublic, MAC: "public-v4", IPAddresses: upcloud.IPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+			},
+		},
+	}, nil
+}
+
+func (d *floatingIPDriver) CreateFloatingIPAddress(_ context.Context, zone, mac string) (*upcloud.IPAddress, error) {
+	d.calls = append(d.calls, "create "+zone+" "+mac)
+	return &upcloud.IPAddress{Address: "192.0.2.20", Family: upcloud.IPAddressFamilyIPv4}, nil
+}
+
+func (d *floatingIPDriver) AttachFloatingIPAddress(_ context.Context, address, mac string) (*upcloud.IPAddress, error) {
+	d.calls = append(d.calls, "attach "+address+" "+mac)
+	return &upcloud.IPAddress{Address: address, Family: upcloud.IPAddressFamilyIPv4}, nil
+}
+
+func (d *floatingIPDriver) DetachFloatingIPAddress(_ context.Context, address string) error {
+	d.calls = append(d.calls, "detach "+address)
+	return nil
+}
+
+func (d *floatingIPDriver) ReleaseIPAddress(_ context.Context, address string) error {
+	d.calls = append(d.calls, "release "+address)
+	return nil
+}
+
+func newFloatingIPState(t *testing.T, drv driver.Driver) multistep.StateBag {
+	t.Helper()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", drv)
+	state.Put("server_uuid", "00000000-0000-4000-8000-000000000000")
+	state.Put("server_ip_address", &IPAddress{Address: "198.51.100.10", Family: upcloud.IPAddressFamilyIPv4})
+	return state
+}
+
+func TestStepAttachFloatingIP_reserved(t *testing.T) {
+	t.Parallel()
+	drv := &floatingIPDriver{}
+	state := newFloatingIPState(t, drv)
+
+	step := &StepAttachFloatingIP{Config: &Config{FloatingIP: &FloatingIP{Address: "192.0.2.10", UseForCommunicator: true}}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+
+	host, err := sshHostCallback(state)
+	require.NoError(t, err)
+	assert.Equal(t, "192.0.2.10", host)
+
+	step.Cleanup(state)
+	assert.Equal(t, []string{"attach 192.0.2.10 public-v4", "detach 192.0.2.10"}, drv.calls)
+}
+
+func TestStepAttachFloatingIP_temporary(t *testing.T) {
+	t.Parallel()
+	drv := &floatingIPDriver{}
+	state := newFloatingIPState(t, drv)
+
+	step := &StepAttachFloatingIP{Config: &Config{Zone: "fi-hel1", FloatingIP: &FloatingIP{}}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+
+	assert.Equal(t, "192.0.2.20", state.Get("floating_ip_address"))
+	host, err := sshHostCallback(state)
+	require.NoError(t, err)
+	assert.Equal(t, "198.51.100.10", host, "communicator connects to the server IP address by default")
+
+	step.Cleanup(state)
+	assert.Equal(t, []string{"create fi-hel1 public-v4", "release 192.0.2.20"}, drv.calls)
+}
@@ -0,0 +1,132 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+const egressIPTimeout = 10 * time.Second
+
+// egressIPServices return public IPv4 and IPv6 address of the caller as plain text.
+var egressIPServices = []string{ //nolint:gochecknoglobals // overridden in tests
+	"https://api.ipify.org",
+	"https://api6.ipify.org",
+}
+
+// StepCreateFirewall represents the step that enables firewall on the build server.
+type StepCreateFirewall struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepCreateFirewall) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config.TemporaryFirewall == nil {
+		return multistep.ActionContinue
+	}
+
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+	serverUUID, ok := state.Get("server_uuid").(string)
+	if !ok {
+		return stepHaltWithError(state, errors.New("server_uuid is not of expected type"))
+	}
+
+	port := 0
+	if s.Config.Comm.Type != "none" {
+		port = s.Config.Comm.Port()
+	}
+
+	allowed := s.Config.TemporaryFirewall.AllowedCIDRs
+	if len(allowed) == 0 && port != 0 {
+		ui.Say("Detecting public IP address of the Packer host...")
+		var err error
+		if allowed, err = detectEgressIPs(ctx, egressIPServices); err != nil {
+			return stepHaltWithError(state, fmt.Errorf("failed to detect public IP address, set 'allowed_cidrs' instead: %w", err))
+		}
+	}
+
+	rules, err := firewallRules(s.Config.TemporaryFirewall, allowed, port)
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	if port != 0 {
+		ui.Say(fmt.Sprintf("Allowing connections to port %d from %s", port, strings.Join(allowed, ", ")))
+	}
+	ui.Say(fmt.Sprintf("Creating %d firewall rules...", len(rules)))
+	if err := drv.CreateFirewallRules(ctx, serverUUID, rules); err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	ui.Say("Enabling firewall...")
+	if err := drv.EnableFirewall(ctx, serverUUID); err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing, firewall rules are deleted together with the server.
+func (s *StepCreateFirewall) Cleanup(_ multistep.StateBag) {}
+
+// detectEgressIPs returns addresses found using the services. Services failing e.g. due to missing IPv6 connectivity are
+// ignored as long as at least one address is found.
+func detectEgressIPs(ctx context.Context, services []string) ([]string, error) {
+	client := &http.Client{Timeout: egressIPTimeout}
+	addrs := make([]string, 0, len(services))
+	var errs []error
+	for _, url := range services {
+		addr, err := detectEgressIP(ctx, client, url)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		addrs = append(addrs, addr)
+	}
+	if len(addrs) == 0 {
+		return nil, errors.Join(errs...)
+	}
+	return addrs, nil
+}
+
+func detectEgressIP(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query %s: %w", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to query %s: unexpected status %s", url, res.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response from %s: %w", url, err)
+	}
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("%s returned invalid IP address", url)
+	}
+	return ip.String(), nil
+}
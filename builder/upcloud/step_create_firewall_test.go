@@ -0,0 +1,90 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// firewallDriver records firewall calls. Calling other methods panics.
+type firewallDriver struct {
+	driver.Driver
+
+	rules   []upcloud.FirewallRule
+	enabled bool
+}
+
+func (d *firewallDriver) CreateFirewallRules(_ context.Context, _ string, rules []upcloud.FirewallRule) error {
+	d.rules = rules
+	return nil
+}
+
+func (d *firewallDriver) EnableFirewall(_ context.Context, _ string) error {
+	d.enabled = true
+	return nil
+}
+
+func TestDetectEgressIPs(t *testing.T) {
+	t.Parallel()
+	ipv4 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte("192.0.2.10\n"))
+	}))
+	defer ipv4.Close()
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer broken.Close()
+
+	addrs, err := detectEgressIPs(t.Context(), []string{ipv4.URL, broken.URL})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.0.2.10"}, addrs)
+
+	_, err = detectEgressIPs(t.Context(), []string{broken.URL})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected status")
+}
+
+func TestStepCreateFirewall(t *testing.T) {
+	t.Parallel()
+	drv := &firewallDriver{}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", drv)
+	state.Put("server_uuid", "00000000-0000-4000-8000-000000000000")
+
+	step := &StepCreateFirewall{Config: &Config{
+		Comm: communicator.Config{Type: "ssh", SSH: communicator.SSH{SSHPort: 2222}},
+		TemporaryFirewall: &TemporaryFirewall{
+			AllowedCIDRs: []string{"192.0.2.0/24"},
+		},
+	}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+	assert.True(t, drv.enabled)
+	require.Len(t, drv.rules, 3)
+	assert.Equal(t, "2222", drv.rules[0].DestinationPortStart)
+	assert.Equal(t, "192.0.2.255", drv.rules[0].SourceAddressEnd)
+	assert.Equal(t, upcloud.FirewallRuleActionDrop, drv.rules[2].Action)
+}
+
+func TestStepCreateFirewall_disabled(t *testing.T) {
+	t.Parallel()
+	drv := &firewallDriver{}
+	state := new(multistep.BasicStateBag)
+	state.Put("driver", drv)
+
+	step := &StepCreateFirewall{Config: &Config{}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+	assert.False(t, drv.enabled)
+}
@@ -0,0 +1,76 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+// StepCreatePrivateNetwork represents the step that creates temporary private network for the build server.
+type StepCreatePrivateNetwork struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepCreatePrivateNetwork) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+
+	network := s.Config.TemporaryPrivateNetwork
+	ui.Say(fmt.Sprintf("Creating temporary private network %s...", network.CIDR))
+
+	response, err := drv.CreateNetwork(ctx, &driver.NetworkOpts{
+		Zone:    s.Config.Zone,
+		Address: network.CIDR,
+		DHCP:    network.DHCP == nil || *network.DHCP,
+		Router:  network.Router,
+		Labels:  s.Config.serverLabels(""),
+	})
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	state.Put("private_network_uuid", response.UUID)
+	state.Put("private_network_name", response.Name)
+	ui.Say(fmt.Sprintf("Private network %q created", response.Name))
+
+	return multistep.ActionContinue
+}
+
+// Cleanup deletes the network. Build server is deleted before the network as cleanups are run in reverse order.
+func (s *StepCreatePrivateNetwork) Cleanup(state multistep.StateBag) {
+	ctx, cancel := contextWithDefaultTimeout()
+	defer cancel()
+
+	networkUUID, ok := state.Get("private_network_uuid").(string)
+	if !ok || networkUUID == "" {
+		return
+	}
+	networkName, _ := state.Get("private_network_name").(string)
+
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return
+	}
+
+	ui.Say(fmt.Sprintf("Deleting private network %q...", networkName))
+
+	if err := drv.DeleteNetwork(ctx, networkUUID); err != nil {
+		ui.Error(err.Error())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
@@ -27,23 +26,54 @@ func (s *StepCreateServer) Run(ctx context.Context, state multistep.StateBag) mu
 		return stepHaltWithError(state, err)
 	}
 
-	storage, err := s.getStorage(ctx, ui, drv)
+	// Source storage is looked up by the preflight step
+	storage, ok := state.Get("source_storage").(*upcloud.Storage)
+	if !ok {
+		return stepHaltWithError(state, errors.New("source storage is not of expected type"))
+	}
+
+	// VNC console is needed only for typing the boot command
+	var vncPassword string
+	if len(s.Config.BootCommand) > 0 {
+		if vncPassword, err = generatePassword(vncPasswordLength); err != nil {
+			return stepHaltWithError(state, err)
+		}
+	}
+
+	// Temporary private network is created by the previous step
+	networkUUID, _ := state.Get("private_network_uuid").(string)
+
+	// Plan is selected by the previous step when using minimum resource requirements
+	plan, _ := state.Get("selected_server_plan").(string)
+	if plan == "" {
+		plan = s.Config.ServerPlan
+	}
+
+	response, err := s.createServer(ctx, ui, drv, storage, sshKeyPublic, vncPassword, networkUUID, plan)
 	if err != nil {
 		return stepHaltWithError(state, err)
 	}
 
-	response, err := s.createServer(ctx, ui, drv, storage, sshKeyPublic)
-	if err != nil {
+	// Store server details right away so that the server is removed during cleanup if following steps fail
+	state.Put("server_uuid", response.UUID)
+	state.Put("server_title", response.Title)
+
+	state.Put("source_storage_uuid", storage.UUID)
+
+	if err := s.labelStorageDevices(ctx, ui, drv, storage, &response.ServerDetails); err != nil {
 		return stepHaltWithError(state, err)
 	}
 
-	addr, err := s.selectIPAddress(ui, response)
+	if s.Config.Comm.Type == "winrm" && response.Password == "" {
+		return stepHaltWithError(state, fmt.Errorf("server %q was created without administrator password", response.Title))
+	}
+
+	addr, err := s.selectIPAddress(ui, &response.ServerDetails)
 	if err != nil {
 		return stepHaltWithError(state, err)
 	}
 
 	s.populateState(state, storage, response, addr)
-	s.handleBootWait(ui)
 
 	return multistep.ActionContinue
 }
@@ -60,6 +90,11 @@ func (s *StepCreateServer) validateState(state multistep.StateBag) (packer.Ui, d
 		return nil, nil, "", errors.New("driver is not of expected type")
 	}
 
+	// SSH key is not created when WinRM communicator is used
+	if s.Config.Comm.Type == "winrm" {
+		return ui, drv, "", nil
+	}
+
 	rawSSHKeyPublic, ok := state.GetOk("ssh_key_public")
 	if !ok {
 		return nil, nil, "", errors.New("SSH public key is missing")
@@ -73,42 +108,161 @@ func (s *StepCreateServer) validateState(state multistep.StateBag) (packer.Ui, d
 	return ui, drv, sshKeyPublic, nil
 }
 
-// getStorage retrieves the storage template to use for server creation.
-func (s *StepCreateServer) getStorage(ctx context.Context, ui packer.Ui, drv driver.Driver) (*upcloud.Storage, error) {
+// getSourceStorage retrieves the storage template or ISO image to use for server creation.
+func getSourceStorage(ctx context.Context, ui packer.Ui, drv driver.Driver, config *Config) (*upcloud.Storage, error) {
+	if config.ISOMode() {
+		ui.Say("Getting ISO image...")
+		storage, err := drv.GetISOStorage(ctx, config.ISOStorageUUID, config.ISOStorageName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ISO image (UUID: %s, Name: %s): %w", config.ISOStorageUUID, config.ISOStorageName, err)
+		}
+		return storage, nil
+	}
+
+	if config.SourceStorageFilter != nil {
+		ui.Say("Finding storage using source storage filter...")
+		storage, err := drv.FindStorage(ctx, convertStorageFilter(config.SourceStorageFilter))
+		if err != nil {
+			return nil, fmt.Errorf("failed to find storage: %w", err)
+		}
+		ui.Say(fmt.Sprintf("Using storage %q (%s)", storage.Title, storage.UUID))
+		return storage, nil
+	}
+
 	ui.Say("Getting storage...")
-	storage, err := drv.GetStorage(ctx, s.Config.StorageUUID, s.Config.StorageName)
+	storage, err := drv.GetStorage(ctx, config.StorageUUID, config.StorageName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get storage (UUID: %s, Name: %s): %w", s.Config.StorageUUID, s.Config.StorageName, err)
+		return nil, fmt.Errorf("failed to get storage (UUID: %s, Name: %s): %w", config.StorageUUID, config.StorageName, err)
 	}
 	return storage, nil
 }
 
 // createServer creates the server with the specified configuration.
-func (s *StepCreateServer) createServer(ctx context.Context, ui packer.Ui, drv driver.Driver, storage *upcloud.Storage, sshKeyPublic string) (*upcloud.ServerDetails, error) {
-	ui.Say(fmt.Sprintf("Creating server based on storage %q...", storage.Title))
-
+func (s *StepCreateServer) createServer(ctx context.Context, ui packer.Ui, drv driver.Driver, storage *upcloud.Storage, sshKeyPublic, vncPassword, networkUUID, plan string) (*driver.ServerDetails, error) {
 	networking := defaultNetworking()
 	if len(s.Config.NetworkInterfaces) > 0 {
 		networking = convertNetworkTypes(s.Config.NetworkInterfaces)
 	}
+	if networkUUID != "" {
+		networking = append(networking, privateNetworking(networkUUID, s.Config.TemporaryPrivateNetwork.CIDR))
+	}
 
-	response, err := drv.CreateServer(ctx, &driver.ServerOpts{
-		ServerPlan:   s.Config.ServerPlan,
+	userData, err := s.Config.userData()
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &driver.ServerOpts{
+		ServerPlan:   plan,
 		StorageUUID:  storage.UUID,
 		StorageSize:  s.Config.StorageSize,
 		Zone:         s.Config.Zone,
 		SSHPublicKey: sshKeyPublic,
 		Networking:   networking,
 		StorageTier:  s.Config.StorageTier,
-	})
+
+		CreatePassword:       s.Config.Comm.Type == "winrm",
+		RemoteAccessPassword: vncPassword,
+		Labels:               s.Config.serverLabels(storage.UUID),
+		StorageEncryption:    s.Config.StorageEncryption,
+		UserData:             userData,
+		Metadata:             s.Config.Metadata,
+		StorageDevices:       convertStorageDevices(s.Config.StorageDevices),
+		CoreNumber:           s.Config.CoreNumber,
+		MemoryAmount:         s.Config.MemoryAmount,
+	}
+
+	if s.Config.ISOMode() {
+		ui.Say(fmt.Sprintf("Creating server with ISO image %q...", storage.Title))
+		opts.StorageUUID = ""
+		opts.ISOStorageUUID = storage.UUID
+	} else {
+		ui.Say(fmt.Sprintf("Creating server based on storage %q...", storage.Title))
+	}
+
+	response, err := s.createServerWithFallbacks(ctx, ui, drv, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create server in zone %s: %w", s.Config.Zone, err)
+		return nil, err
 	}
 
 	ui.Say(fmt.Sprintf("Server %q created and in 'started' state", response.Title))
 	return response, nil
 }
 
+// createServerWithFallbacks tries fallback zones and plans in order when the server cannot be created due to lack of
+// capacity. Each fallback plan is tried in a zone before moving on to the next zone.
+func (s *StepCreateServer) createServerWithFallbacks(ctx context.Context, ui packer.Ui, drv driver.Driver, opts *driver.ServerOpts) (*driver.ServerDetails, error) {
+	zones := append([]string{opts.Zone}, s.Config.FallbackZones...)
+	plans := append([]string{opts.ServerPlan}, s.Config.FallbackPlans...)
+
+	var errs []error
+	for z, zone := range zones {
+		for i, plan := range plans {
+			attempt := *opts
+			attempt.Zone = zone
+			// plan was selected in the primary zone, but plans and prices differ between zones
+			if i == 0 && z > 0 && s.Config.PlanSelection() {
+				selected, err := s.selectZonePlan(ctx, ui, drv, zone)
+				if err != nil {
+					ui.Error(err.Error())
+					errs = append(errs, err)
+					continue
+				}
+				attempt.ServerPlan = selected
+			}
+			if i > 0 {
+				// fallback plans replace custom plan
+				attempt.ServerPlan = plan
+				attempt.CoreNumber = 0
+				attempt.MemoryAmount = 0
+			}
+			if len(errs) > 0 {
+				ui.Say(fmt.Sprintf("Retrying in zone %q using plan %q...", zone, serverPlanName(&attempt)))
+			}
+
+			response, err := drv.CreateServer(ctx, &attempt)
+			if err == nil {
+				return response, nil
+			}
+			if !errors.Is(err, driver.ErrCapacity) {
+				return nil, fmt.Errorf("failed to create server in zone %s: %w", zone, err)
+			}
+			ui.Error(fmt.Sprintf("Server resources are not available in zone %q using plan %q", zone, serverPlanName(&attempt)))
+			errs = append(errs, fmt.Errorf("zone %s, plan %s: %w", zone, serverPlanName(&attempt), err))
+		}
+	}
+	return nil, fmt.Errorf("failed to create server: %w", errors.Join(errs...))
+}
+
+// selectZonePlan returns the cheapest plan meeting minimum resource requirements in the zone.
+func (s *StepCreateServer) selectZonePlan(ctx context.Context, ui packer.Ui, drv driver.Driver, zone string) (string, error) {
+	prices, err := drv.GetPlanPrices(ctx, zone)
+	if err != nil {
+		return "", fmt.Errorf("zone %s: %w", zone, err)
+	}
+	plan, err := selectPlan(prices, s.Config.planRequirements())
+	if err != nil {
+		return "", fmt.Errorf("zone %s: %w", zone, err)
+	}
+	ui.Say(fmt.Sprintf("Using plan %q (%g euros per hour) in zone %q", plan.Name, plan.HourlyPrice, zone))
+	return plan.Name, nil
+}
+
+// labelStorageDevices sets server labels to the disks of the server.
+func (s *StepCreateServer) labelStorageDevices(ctx context.Context, ui packer.Ui, drv driver.Driver, storage *upcloud.Storage, response *upcloud.ServerDetails) error {
+	labels := s.Config.serverLabels(storage.UUID)
+	for _, device := range response.StorageDevices {
+		if device.Type != upcloud.StorageTypeDisk {
+			continue
+		}
+		ui.Say(fmt.Sprintf("Setting labels to storage %q...", device.UUID))
+		if err := drv.SetStorageLabels(ctx, device.UUID, labels); err != nil {
+			return err //nolint:wrapcheck // driver errors are already wrapped
+		}
+	}
+	return nil
+}
+
 // selectIPAddress selects the appropriate IP address for the server.
 func (s *StepCreateServer) selectIPAddress(ui packer.Ui, response *upcloud.ServerDetails) (*IPAddress, error) {
 	addr, infType := s.Config.DefaultIPaddress()
@@ -149,24 +303,29 @@ func (s *StepCreateServer) selectDefaultIPAddress(ui packer.Ui, response *upclou
 }
 
 // populateState populates the state bag with server and storage information.
-func (s *StepCreateServer) populateState(state multistep.StateBag, storage *upcloud.Storage, response *upcloud.ServerDetails, addr *IPAddress) {
+func (s *StepCreateServer) populateState(state multistep.StateBag, storage *upcloud.Storage, response *driver.ServerDetails, addr *IPAddress) {
+	if response.Password != "" {
+		packer.LogSecretFilter.Set(response.Password)
+		state.Put("winrm_password", response.Password)
+	}
+
+	if response.RemoteAccessEnabled.Bool() {
+		packer.LogSecretFilter.Set(response.RemoteAccessPassword)
+		state.Put("vnc_host", response.RemoteAccessHost)
+		state.Put("vnc_port", response.RemoteAccessPort)
+		state.Put("vnc_password", response.RemoteAccessPassword)
+	}
+
 	state.Put("source_template_uuid", storage.UUID)
 	state.Put("source_template_title", storage.Title)
 	state.Put("server_ip_address", addr)
-	state.Put("server_uuid", response.UUID)
-	state.Put("server_title", response.Title)
 
 	s.GeneratedData.Put("ServerUUID", response.UUID)
 	s.GeneratedData.Put("ServerTitle", response.Title)
+	state.Put("server_plan", response.Plan)
+	state.Put("server_zone", response.Zone)
 	s.GeneratedData.Put("ServerSize", response.Plan)
-}
-
-// handleBootWait handles the boot wait period if configured.
-func (s *StepCreateServer) handleBootWait(ui packer.Ui) {
-	if s.Config.BootWait > 0 {
-		ui.Say(fmt.Sprintf("Waitig boot: %s", s.Config.BootWait.String()))
-		time.Sleep(s.Config.BootWait)
-	}
+	s.GeneratedData.Put("ServerZone", response.Zone)
 }
 
 // Cleanup stops and destroys the server if server details are found in the state.
@@ -210,6 +369,16 @@ func (s *StepCreateServer) Cleanup(state multistep.StateBag) {
 		return
 	}
 
+	// eject ISO image so that it's not deleted together with the server storages
+	if s.Config.ISOMode() {
+		ui.Say(fmt.Sprintf("Ejecting ISO image from server %q...", serverTitle))
+
+		if err := driver.EjectCDROM(ctx, serverUUID); err != nil {
+			ui.Error(err.Error())
+			return
+		}
+	}
+
 	// delete server
 	ui.Say(fmt.Sprintf("Deleting server %q...", serverTitle))
 
@@ -0,0 +1,107 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// capacityDriver fails to create servers in the listed zone and plan combinations. Calling other methods panics.
+type capacityDriver struct {
+	driver.Driver
+
+	unavailable map[string]bool
+	prices      map[string][]driver.PlanPrice
+	attempts    []string
+}
+
+func (d *capacityDriver) GetPlanPrices(_ context.Context, zone string) ([]driver.PlanPrice, error) {
+	return d.prices[zone], nil
+}
+
+func (d *capacityDriver) CreateServer(_ context.Context, opts *driver.ServerOpts) (*driver.ServerDetails, error) {
+	attempt := opts.Zone + "/" + serverPlanName(opts)
+	d.attempts = append(d.attempts, attempt)
+	if d.unavailable[attempt] {
+		return nil, fmt.Errorf("error creating server: %w", driver.ErrCapacity)
+	}
+	if opts.Zone == "zone-invalid" {
+		return nil, fmt.Errorf("error creating server: %w", driver.ErrNotFound)
+	}
+	return &driver.ServerDetails{ServerDetails: upcloud.ServerDetails{Server: upcloud.Server{Zone: opts.Zone, Plan: serverPlanName(opts)}}}, nil
+}
+
+func TestStepCreateServer_createServerWithFallbacks(t *testing.T) {
+	t.Parallel()
+	drv := &capacityDriver{unavailable: map[string]bool{
+		"fi-hel1/custom":    true,
+		"fi-hel1/2xCPU-4GB": true,
+		"fi-hel2/custom":    true,
+	}}
+	step := &StepCreateServer{Config: &Config{
+		FallbackZones: []string{"fi-hel2", "de-fra1"},
+		FallbackPlans: []string{"2xCPU-4GB"},
+	}}
+
+	response, err := step.createServerWithFallbacks(t.Context(), packersdk.TestUi(t), drv, &driver.ServerOpts{
+		Zone:         "fi-hel1",
+		CoreNumber:   2,
+		MemoryAmount: 4096,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "fi-hel2", response.Zone)
+	assert.Equal(t, "2xCPU-4GB", response.Plan)
+	assert.Equal(t, []string{"fi-hel1/custom", "fi-hel1/2xCPU-4GB", "fi-hel2/custom", "fi-hel2/2xCPU-4GB"}, drv.attempts)
+}
+
+func TestStepCreateServer_createServerWithFallbacks_planSelection(t *testing.T) {
+	t.Parallel()
+	drv := &capacityDriver{
+		unavailable: map[string]bool{"fi-hel1/2xCPU-4GB": true},
+		prices: map[string][]driver.PlanPrice{
+			// plan selected in fi-hel1 is too expensive in fi-hel2 and not available in de-fra1
+			"fi-hel2": {
+				{Plan: upcloud.Plan{Name: "2xCPU-4GB", CoreNumber: 2, MemoryAmount: 4096}, HourlyPrice: 0.1},
+			},
+			"de-fra1": {
+				{Plan: upcloud.Plan{Name: "4xCPU-8GB", CoreNumber: 4, MemoryAmount: 8192}, HourlyPrice: 0.05},
+			},
+		},
+	}
+	step := &StepCreateServer{Config: &Config{
+		MinCPU:         2,
+		MaxHourlyPrice: 0.06,
+		FallbackZones:  []string{"fi-hel2", "de-fra1"},
+	}}
+
+	response, err := step.createServerWithFallbacks(t.Context(), packersdk.TestUi(t), drv, &driver.ServerOpts{
+		Zone:       "fi-hel1",
+		ServerPlan: "2xCPU-4GB",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "de-fra1", response.Zone)
+	assert.Equal(t, "4xCPU-8GB", response.Plan)
+	assert.Equal(t, []string{"fi-hel1/2xCPU-4GB", "de-fra1/4xCPU-8GB"}, drv.attempts)
+}
+
+func TestStepCreateServer_createServerWithFallbacks_error(t *testing.T) {
+	t.Parallel()
+	drv := &capacityDriver{unavailable: map[string]bool{"fi-hel1/1xCPU-2GB": true}}
+	step := &StepCreateServer{Config: &Config{
+		FallbackZones: []string{"zone-invalid", "de-fra1"},
+	}}
+
+	_, err := step.createServerWithFallbacks(t.Context(), packersdk.TestUi(t), drv, &driver.ServerOpts{Zone: "fi-hel1"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to create server in zone zone-invalid")
+	assert.Equal(t, []string{"fi-hel1/1xCPU-2GB", "zone-invalid/1xCPU-2GB"}, drv.attempts)
+}
@@ -36,55 +36,113 @@ func (s *StepCreateTemplate) Run(ctx context.Context, state multistep.StateBag)
 		return stepHaltWithError(state, errors.New("driver is not of expected type"))
 	}
 
+	// server might have been created in a fallback zone
+	cloneZones := s.Config.CloneZones
+	if serverZone, _ := state.Get("server_zone").(string); serverZone != "" && serverZone != s.Config.Zone {
+		cloneZones = templateZones(s.Config.Zone, serverZone, s.Config.CloneZones)
+	}
+
 	// get storage details
-	storage, err := drv.GetServerStorage(ctx, serverUUID)
+	disks, err := drv.GetServerStorages(ctx, serverUUID)
 	if err != nil {
 		return stepHaltWithError(state, err)
 	}
+	disks = templateDisks(s.Config.StorageDevices, s.Config.TemplateAllDisks, disks)
+
+	sourceUUID, _ := state.Get("source_storage_uuid").(string)
+
+	// we either use template name or prefix.
+	var templateTitle string
+	if len(s.Config.TemplatePrefix) > 0 {
+		templateTitle = fmt.Sprintf("%s-%s", s.Config.TemplatePrefix, getNowString())
+	} else {
+		templateTitle = s.Config.TemplateName
+	}
 
-	// cloning to zones
 	cleanupStorageUUID := []string{}
-	storageUUIDs := []string{}
-	storageUUIDs = append(storageUUIDs, storage.UUID)
+	diskTemplates := []*DiskTemplates{}
+	for i, disk := range disks {
+		title := templateTitle
+		if i > 0 {
+			// templates of extra disks are named after the disk
+			title = fmt.Sprintf("%s-%s", templateTitle, disk.Title)
+		}
 
-	for _, zone := range s.Config.CloneZones {
-		ui.Say(fmt.Sprintf("Cloning storage %q to zone %q...", storage.UUID, zone))
-		title := fmt.Sprintf("packer-%s-cloned-disk1", getNowString())
-		clonedStorage, err := drv.CloneStorage(ctx, storage.UUID, zone, title)
+		templates, cleanup, err := s.createDiskTemplates(ctx, ui, drv, &disk, title, sourceUUID, cloneZones)
+		cleanupStorageUUID = append(cleanupStorageUUID, cleanup...)
 		if err != nil {
+			// templates of previous disks must not be left behind either
+			for _, d := range diskTemplates {
+				for _, t := range d.Templates {
+					cleanupStorageUUID = append(cleanupStorageUUID, t.UUID)
+				}
+			}
+			state.Put("cleanup_storage_uuids", cleanupStorageUUID)
 			return stepHaltWithError(state, err)
 		}
+		diskTemplates = append(diskTemplates, &DiskTemplates{Disk: disk.Title, Templates: templates})
+	}
+
+	state.Put("cleanup_storage_uuids", cleanupStorageUUID)
+	state.Put("templates", diskTemplates)
+
+	return multistep.ActionContinue
+}
+
+// createDiskTemplates clones the disk to clone zones and creates templates from the disk and the clones. Clones are
+// returned for cleanup, and templates too if the step fails.
+func (s *StepCreateTemplate) createDiskTemplates(ctx context.Context, ui packer.Ui, drv driver.Driver, disk *upcloud.ServerStorageDevice, templateTitle, sourceUUID string, cloneZones []string) ([]*upcloud.Storage, []string, error) {
+	// cloning to zones
+	cleanupStorageUUID := []string{}
+	storageUUIDs := []string{disk.UUID}
+
+	for _, zone := range cloneZones {
+		ui.Say(fmt.Sprintf("Cloning storage %q to zone %q...", disk.UUID, zone))
+		title := fmt.Sprintf("packer-%s-cloned-%s", getNowString(), disk.Title)
+		clonedStorage, err := drv.CloneStorage(ctx, disk.UUID, zone, title, s.Config.StorageEncryption)
+		if err != nil {
+			return nil, cleanupStorageUUID, err //nolint:wrapcheck // driver errors are already wrapped
+		}
 		storageUUIDs = append(storageUUIDs, clonedStorage.UUID)
 		cleanupStorageUUID = append(cleanupStorageUUID, clonedStorage.UUID)
+		if err := drv.SetStorageLabels(ctx, clonedStorage.UUID, s.Config.serverLabels(sourceUUID)); err != nil {
+			return nil, cleanupStorageUUID, err //nolint:wrapcheck // driver errors are already wrapped
+		}
+	}
+	if len(cloneZones) > 0 {
+		ui.Say("Cloning completed...")
 	}
-	ui.Say("Cloning completed...")
 
-	// creating template
+	// creating templates
 	templates := []*upcloud.Storage{}
-
-	// we either use template name or prefix.
-	var templateTitle string
-	if len(s.Config.TemplatePrefix) > 0 {
-		templateTitle = fmt.Sprintf("%s-%s", s.Config.TemplatePrefix, getNowString())
-	} else {
-		templateTitle = s.Config.TemplateName
+	failed := func(err error) ([]*upcloud.Storage, []string, error) {
+		for _, template := range templates {
+			cleanupStorageUUID = append(cleanupStorageUUID, template.UUID)
+		}
+		return nil, cleanupStorageUUID, err
 	}
 
 	for _, uuid := range storageUUIDs {
 		ui.Say(fmt.Sprintf("Creating template for storage %q...", uuid))
 		t, err := drv.CreateTemplate(ctx, uuid, templateTitle)
 		if err != nil {
-			return stepHaltWithError(state, err)
+			return failed(err)
 		}
 
 		templates = append(templates, t)
 		ui.Say(fmt.Sprintf("Template for storage %q created...", uuid))
-	}
 
-	state.Put("cleanup_storage_uuids", cleanupStorageUUID)
-	state.Put("templates", templates)
+		if s.Config.StorageEncryption && !t.Encrypted.Bool() {
+			// unencrypted templates must not be left behind
+			return failed(fmt.Errorf("template %q was created without encryption", t.UUID))
+		}
 
-	return multistep.ActionContinue
+		if err := drv.SetStorageLabels(ctx, t.UUID, s.Config.templateLabels(sourceUUID)); err != nil {
+			return failed(err)
+		}
+	}
+
+	return templates, cleanupStorageUUID, nil
 }
 
 // Cleanup cleans up after the step.
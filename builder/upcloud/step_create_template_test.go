@@ -0,0 +1,82 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// templateDriver clones storages and creates templates in memory. Calling other methods panics.
+type templateDriver struct {
+	driver.Driver
+
+	disks []upcloud.ServerStorageDevice
+	zones map[string]string
+}
+
+func (d *templateDriver) GetServerStorages(_ context.Context, _ string) ([]upcloud.ServerStorageDevice, error) {
+	return d.disks, nil
+}
+
+func (d *templateDriver) CloneStorage(_ context.Context, storageUUID, zone, _ string, _ bool) (*upcloud.Storage, error) {
+	uuid := storageUUID + "-" + zone
+	d.zones[uuid] = zone
+	return &upcloud.Storage{UUID: uuid, Zone: zone}, nil
+}
+
+func (d *templateDriver) CreateTemplate(_ context.Context, storageUUID, title string) (*upcloud.Storage, error) {
+	return &upcloud.Storage{UUID: "template-" + storageUUID, Title: title, Zone: d.zones[storageUUID]}, nil
+}
+
+func (d *templateDriver) SetStorageLabels(_ context.Context, _ string, _ map[string]string) error {
+	return nil
+}
+
+func TestStepCreateTemplate(t *testing.T) {
+	t.Parallel()
+	drv := &templateDriver{
+		disks: []upcloud.ServerStorageDevice{
+			{UUID: "system", Title: "custom-disk1"},
+			{UUID: "data", Title: "data"},
+			{UUID: "scratch", Title: "scratch"},
+		},
+		zones: map[string]string{"system": "fi-hel1", "data": "fi-hel1"},
+	}
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", drv)
+	state.Put("server_uuid", "00000000-0000-4000-8000-000000000000")
+
+	step := &StepCreateTemplate{Config: &Config{
+		Zone:         "fi-hel1",
+		CloneZones:   []string{"fi-hel2"},
+		TemplateName: "custom",
+		StorageDevices: []StorageDevice{
+			{Title: "data", Template: true},
+			{Title: "scratch"},
+		},
+	}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+
+	templates, ok := state.Get("templates").([]*DiskTemplates)
+	require.True(t, ok)
+	require.Len(t, templates, 2)
+	assert.Equal(t, "custom-disk1", templates[0].Disk)
+	assert.Equal(t, "data", templates[1].Disk)
+	assert.Equal(t, map[string]map[string]string{
+		"custom-disk1": {"fi-hel1": "template-system", "fi-hel2": "template-system-fi-hel2"},
+		"data":         {"fi-hel1": "template-data", "fi-hel2": "template-data-fi-hel2"},
+	}, diskTemplateUUIDs(templates))
+	assert.Equal(t, "custom", templates[0].Templates[0].Title)
+	assert.Equal(t, "custom-data", templates[1].Templates[0].Title)
+	assert.Equal(t, []string{"system-fi-hel2", "data-fi-hel2"}, state.Get("cleanup_storage_uuids"))
+}
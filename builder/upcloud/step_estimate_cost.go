@@ -0,0 +1,229 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+const (
+	centsPerEuro  = 100
+	hoursPerMonth = 730
+
+	priceServerCore      = "server_core"
+	priceServerMemory    = "server_memory"
+	priceStorageTemplate = "storage_template"
+)
+
+// costEstimate is a rough estimate of the build cost in euros by zone.
+type costEstimate struct {
+	// Build is the cost of one billing hour of the build server, its disks and the clones.
+	Build map[string]float64
+	// Templates is the cost of one month of template storage.
+	Templates map[string]float64
+}
+
+// Total returns the estimated cost of the build and one month of template storage.
+func (e *costEstimate) Total() float64 {
+	var total float64
+	for _, cost := range e.Build {
+		total += cost
+	}
+	for _, cost := range e.Templates {
+		total += cost
+	}
+	return total
+}
+
+// StateData returns the estimate for artifact state.
+func (e *costEstimate) StateData() map[string]float64 {
+	data := map[string]float64{"total": e.Total()}
+	for zone, cost := range e.Build {
+		data[zone+".build"] = cost
+	}
+	for zone, cost := range e.Templates {
+		data[zone+".templates_monthly"] = cost
+	}
+	return data
+}
+
+// StepEstimateCost represents the step that estimates the cost of the build and checks it against the account balance.
+type StepEstimateCost struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepEstimateCost) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+
+	// Plan is selected by the previous step when using minimum resource requirements
+	plan, _ := state.Get("selected_server_plan").(string)
+	if plan == "" {
+		plan = s.Config.ServerPlan
+	}
+
+	// Sizes of cloned storage devices are resolved by the preflight step
+	deviceSizes, _ := state.Get("storage_device_sizes").([]int)
+
+	ui.Say("Estimating build cost...")
+	estimate, err := s.estimate(ctx, drv, plan, deviceSizes)
+	if err != nil {
+		if s.Config.MaxEstimatedCost > 0 {
+			return stepHaltWithError(state, fmt.Errorf("failed to estimate build cost: %w", err))
+		}
+		ui.Error(fmt.Sprintf("Failed to estimate build cost: %s", err))
+		return multistep.ActionContinue
+	}
+
+	zones := make([]string, 0, len(estimate.Templates))
+	for zone := range estimate.Templates {
+		zones = append(zones, zone)
+	}
+	sort.Strings(zones)
+	for _, zone := range zones {
+		ui.Say(fmt.Sprintf("Zone %s: %.4f EUR for the build hour, %.4f EUR per month for templates", zone, estimate.Build[zone], estimate.Templates[zone]))
+	}
+	total := estimate.Total()
+	ui.Say(fmt.Sprintf("Estimated cost: %.4f EUR", total))
+	if len(s.Config.FallbackZones) > 0 {
+		ui.Say("Estimate does not include fallback zones, the cost differs if the server is created in a fallback zone")
+	}
+	state.Put("cost_estimate", estimate.StateData())
+
+	if s.Config.MaxEstimatedCost > 0 && total > s.Config.MaxEstimatedCost {
+		return stepHaltWithError(state, fmt.Errorf("estimated cost %.4f EUR exceeds 'max_estimated_cost' %.4f EUR", total, s.Config.MaxEstimatedCost))
+	}
+
+	account, err := drv.GetAccount(ctx)
+	if err != nil {
+		ui.Error(fmt.Sprintf("Failed to check account balance: %s", err))
+	} else if credits := account.Credits / centsPerEuro; credits < total {
+		ui.Error(fmt.Sprintf("Account balance %.2f EUR is less than the estimated cost", credits))
+	}
+
+	return multistep.ActionContinue
+}
+
+// estimate fetches prices of the build zone and clone zones and estimates the build cost.
+func (s *StepEstimateCost) estimate(ctx context.Context, drv driver.Driver, plan string, deviceSizes []int) (*costEstimate, error) {
+	prices := make(map[string]map[string]upcloud.Price)
+	for _, zone := range append([]string{s.Config.Zone}, s.Config.CloneZones...) {
+		zonePrices, err := drv.GetZonePrices(ctx, zone)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // driver errors are already wrapped
+		}
+		prices[zone] = zonePrices
+	}
+	return estimateCost(s.Config, plan, deviceSizes, prices)
+}
+
+// estimateCost estimates the cost of one billing hour of the build server, its disks and clones, and one month of
+// template storage using prices by zone. Disks included in the plan are not taken into account. Device sizes override
+// sizes of the storage devices when given, e.g. clones without size.
+func estimateCost(c *Config, plan string, deviceSizes []int, prices map[string]map[string]upcloud.Price) (*costEstimate, error) {
+	estimate := &costEstimate{
+		Build:     make(map[string]float64),
+		Templates: make(map[string]float64),
+	}
+
+	buildPrices := prices[c.Zone]
+	server, err := serverCost(c, plan, buildPrices)
+	if err != nil {
+		return nil, err
+	}
+	estimate.Build[c.Zone] = server
+
+	// disks of the build server and templatized disks by tier
+	disks := map[string]int{c.StorageTier: c.StorageSize}
+	templateDisks := map[string]int{c.StorageTier: c.StorageSize}
+	templateSize := c.StorageSize
+	for i, device := range c.StorageDevices {
+		size := device.Size
+		if i < len(deviceSizes) {
+			size = deviceSizes[i]
+		}
+		disks[device.Tier] += size
+		if device.Template || c.TemplateAllDisks {
+			templateDisks[device.Tier] += size
+			templateSize += size
+		}
+	}
+	for tier, size := range disks {
+		cost, err := itemCost(buildPrices, "storage_"+tier, size)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Build[c.Zone] += cost
+	}
+
+	for zone, zonePrices := range prices {
+		if zone != c.Zone {
+			// clones are deleted after templates have been created from them
+			for tier, size := range templateDisks {
+				cost, err := itemCost(zonePrices, "storage_"+tier, size)
+				if err != nil {
+					return nil, err
+				}
+				estimate.Build[zone] += cost
+			}
+		}
+
+		cost, err := itemCost(zonePrices, priceStorageTemplate, templateSize)
+		if err != nil {
+			return nil, err
+		}
+		estimate.Templates[zone] = cost * hoursPerMonth
+	}
+
+	return estimate, nil
+}
+
+// serverCost returns hourly cost of the server plan.
+func serverCost(c *Config, plan string, prices map[string]upcloud.Price) (float64, error) {
+	if c.CoreNumber > 0 {
+		cores, err := itemCost(prices, priceServerCore, c.CoreNumber)
+		if err != nil {
+			return 0, err
+		}
+		memory, err := itemCost(prices, priceServerMemory, c.MemoryAmount)
+		if err != nil {
+			return 0, err
+		}
+		return cores + memory, nil
+	}
+
+	if plan == "" {
+		plan = driver.DefaultPlan
+	}
+	return itemCost(prices, "server_plan_"+plan, 1)
+}
+
+// itemCost returns hourly cost of the quantity of the price item in euros.
+func itemCost(prices map[string]upcloud.Price, item string, quantity int) (float64, error) {
+	price, ok := prices[item]
+	if !ok {
+		return 0, fmt.Errorf("price of %s not found", item)
+	}
+	amount := price.Amount
+	if amount <= 0 {
+		amount = 1
+	}
+	return float64(quantity) / float64(amount) * price.Price / centsPerEuro, nil
+}
+
+// Cleanup does nothing, no resources are created.
+func (s *StepEstimateCost) Cleanup(_ multistep.StateBag) {}
@@ -0,0 +1,108 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+var testZonePrices = map[string]upcloud.Price{ //nolint:gochecknoglobals // test data
+	"server_plan_1xCPU-2GB": {Amount: 1, Price: 1.5},
+	"server_core":           {Amount: 1, Price: 0.6},
+	"server_memory":         {Amount: 256, Price: 0.1},
+	"storage_maxiops":       {Amount: 1, Price: 0.03},
+	"storage_standard":      {Amount: 1, Price: 0.01},
+	"storage_template":      {Amount: 1, Price: 0.02},
+}
+
+// estimateCostDriver implements methods used in cost estimation. Calling other methods panics.
+type estimateCostDriver struct {
+	driver.Driver
+}
+
+func (d *estimateCostDriver) GetZonePrices(_ context.Context, _ string) (map[string]upcloud.Price, error) {
+	return testZonePrices, nil
+}
+
+func (d *estimateCostDriver) GetAccount(_ context.Context) (*upcloud.Account, error) {
+	return &upcloud.Account{Credits: 1000}, nil
+}
+
+func TestEstimateCost(t *testing.T) {
+	t.Parallel()
+	c := &Config{
+		Zone:        "fi-hel1",
+		StorageSize: 10,
+		StorageTier: "maxiops",
+		CloneZones:  []string{"de-fra1"},
+		StorageDevices: []StorageDevice{
+			{Size: 100, Tier: "standard"},
+			{Size: 20, Tier: "standard", Template: true},
+			{Action: "clone", Tier: "maxiops"},
+		},
+	}
+	prices := map[string]map[string]upcloud.Price{
+		"fi-hel1": testZonePrices,
+		"de-fra1": testZonePrices,
+	}
+
+	// size of the cloned device is resolved from its source storage
+	estimate, err := estimateCost(c, "1xCPU-2GB", []int{100, 20, 50}, prices)
+	require.NoError(t, err)
+	// 1.5 + 60*0.03 + 120*0.01 cents
+	assert.InDelta(t, 0.045, estimate.Build["fi-hel1"], 1e-9)
+	// 10*0.03 + 20*0.01 cents for the clones of the templatized disks at their own tiers
+	assert.InDelta(t, 0.005, estimate.Build["de-fra1"], 1e-9)
+	// 30*0.02*730 cents
+	assert.InDelta(t, 4.38, estimate.Templates["fi-hel1"], 1e-9)
+	assert.InDelta(t, 4.38, estimate.Templates["de-fra1"], 1e-9)
+	assert.InDelta(t, 8.81, estimate.Total(), 1e-9)
+
+	c.CoreNumber = 2
+	c.MemoryAmount = 4096
+	c.CloneZones = nil
+	c.StorageDevices = nil
+	estimate, err = estimateCost(c, "", nil, map[string]map[string]upcloud.Price{"fi-hel1": testZonePrices})
+	require.NoError(t, err)
+	// 2*0.6 + 16*0.1 + 10*0.03 cents
+	assert.InDelta(t, 0.031, estimate.Build["fi-hel1"], 1e-9)
+
+	_, err = estimateCost(c, "", nil, map[string]map[string]upcloud.Price{"fi-hel1": {}})
+	require.EqualError(t, err, "price of server_core not found")
+}
+
+func TestStepEstimateCost(t *testing.T) {
+	t.Parallel()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", &estimateCostDriver{})
+	state.Put("selected_server_plan", "1xCPU-2GB")
+
+	step := &StepEstimateCost{Config: &Config{
+		Zone:        "fi-hel1",
+		ServerPlan:  "2xCPU-4GB",
+		StorageSize: 10,
+		StorageTier: "maxiops",
+	}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+	estimate, ok := state.Get("cost_estimate").(map[string]float64)
+	require.True(t, ok)
+	assert.InDelta(t, 0.018, estimate["fi-hel1.build"], 1e-9)
+	assert.InDelta(t, 1.46, estimate["fi-hel1.templates_monthly"], 1e-9)
+	assert.InDelta(t, 1.478, estimate["total"], 1e-9)
+
+	step.Config.MaxEstimatedCost = 1
+	assert.Equal(t, multistep.ActionHalt, step.Run(t.Context(), state))
+	err, ok := state.Get("error").(error)
+	require.True(t, ok)
+	assert.EqualError(t, err, "estimated cost 1.4780 EUR exceeds 'max_estimated_cost' 1.0000 EUR")
+}
@@ -0,0 +1,167 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+// StepPreflight represents the step that validates the configuration against the API before any resources are created.
+// Only read-only API calls are made.
+type StepPreflight struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepPreflight) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+
+	ui.Say("Running preflight checks...")
+
+	errs, zoneAvailable := s.checkZones(ctx, drv)
+	if zoneAvailable {
+		errs = append(errs, s.checkPrices(ctx, drv)...)
+		errs = append(errs, s.checkNetworks(ctx, drv)...)
+	}
+	storage, deviceSizes, storageErrs := s.checkStorages(ctx, ui, drv)
+	errs = append(errs, storageErrs...)
+
+	if len(errs) > 0 {
+		return stepHaltWithError(state, &packer.MultiError{Errors: errs})
+	}
+
+	// Server is created using the same storage, a name or a filter could match another storage when looked up again
+	state.Put("source_storage", storage)
+	state.Put("storage_device_sizes", deviceSizes)
+
+	ui.Say("Preflight checks passed")
+	return multistep.ActionContinue
+}
+
+// checkZones checks that zone, clone zones and fallback zones are available. Zone dependent checks can be run only if
+// zone is available.
+func (s *StepPreflight) checkZones(ctx context.Context, drv driver.Driver) ([]error, bool) {
+	available := drv.GetAvailableZones(ctx)
+	if len(available) == 0 {
+		return []error{errors.New("failed to get available zones")}, false
+	}
+
+	var errs []error
+	if !slices.Contains(available, s.Config.Zone) {
+		errs = append(errs, fmt.Errorf("'zone' %q is not available", s.Config.Zone))
+	}
+	for _, zone := range s.Config.CloneZones {
+		if !slices.Contains(available, zone) {
+			errs = append(errs, fmt.Errorf("'clone_zones' zone %q is not available", zone))
+		}
+	}
+	for _, zone := range s.Config.FallbackZones {
+		if !slices.Contains(available, zone) {
+			errs = append(errs, fmt.Errorf("'fallback_zones' zone %q is not available", zone))
+		}
+	}
+	return errs, slices.Contains(available, s.Config.Zone)
+}
+
+// checkPrices checks that server plans and storage tiers are available in the zone, i.e. they have a price in the zone.
+func (s *StepPreflight) checkPrices(ctx context.Context, drv driver.Driver) []error {
+	prices, err := drv.GetZonePrices(ctx, s.Config.Zone)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	plans := s.Config.FallbackPlans
+	// custom plan and plans selected using resource requirements are always available
+	if s.Config.CoreNumber == 0 && !s.Config.PlanSelection() {
+		plans = append([]string{serverPlanName(&driver.ServerOpts{ServerPlan: s.Config.ServerPlan})}, plans...)
+	}
+	for _, plan := range plans {
+		if _, ok := prices["server_plan_"+plan]; !ok {
+			errs = append(errs, fmt.Errorf("server plan %q is not available in zone %s", plan, s.Config.Zone))
+		}
+	}
+
+	tiers := []string{s.Config.StorageTier}
+	for _, device := range s.Config.StorageDevices {
+		if !slices.Contains(tiers, device.Tier) {
+			tiers = append(tiers, device.Tier)
+		}
+	}
+	for _, tier := range tiers {
+		if _, ok := prices["storage_"+tier]; !ok {
+			errs = append(errs, fmt.Errorf("storage tier %q is not available in zone %s", tier, s.Config.Zone))
+		}
+	}
+	return errs
+}
+
+// checkNetworks checks that private networks are in the zone.
+func (s *StepPreflight) checkNetworks(ctx context.Context, drv driver.Driver) []error {
+	var errs []error
+	for _, iface := range s.Config.NetworkInterfaces {
+		if iface.Network == "" {
+			continue
+		}
+		network, err := drv.GetNetwork(ctx, iface.Network)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if network.Zone != s.Config.Zone {
+			errs = append(errs, fmt.Errorf("'network_interfaces' network %q is in zone %s instead of %s", iface.Network, network.Zone, s.Config.Zone))
+		}
+	}
+	return errs
+}
+
+// checkStorages checks that source storages exist and fit into the disks. Returns the source storage of the server and
+// sizes of the storage devices, clones without size being the size of their source storage.
+func (s *StepPreflight) checkStorages(ctx context.Context, ui packer.Ui, drv driver.Driver) (*upcloud.Storage, []int, []error) {
+	var errs []error
+	storage, err := getSourceStorage(ctx, ui, drv, s.Config)
+	switch {
+	case err != nil:
+		errs = append(errs, err)
+	case !s.Config.ISOMode() && storage.Size > s.Config.StorageSize:
+		errs = append(errs, fmt.Errorf("'storage_size' %d is smaller than the size %d of source storage %q", s.Config.StorageSize, storage.Size, storage.Title))
+	}
+
+	sizes := make([]int, len(s.Config.StorageDevices))
+	for i, device := range s.Config.StorageDevices {
+		sizes[i] = device.Size
+		if device.Action != request.CreateServerStorageDeviceActionClone {
+			continue
+		}
+		source, err := drv.GetStorage(ctx, device.SourceUUID, "")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'storage_device' %d: %w", i+1, err))
+			continue
+		}
+		if device.Size > 0 && source.Size > device.Size {
+			errs = append(errs, fmt.Errorf("invalid 'storage_device' %d: 'size' %d is smaller than the size %d of source storage %q", i+1, device.Size, source.Size, source.Title))
+		}
+		if device.Size == 0 {
+			sizes[i] = source.Size
+		}
+	}
+	return storage, sizes, errs
+}
+
+// Cleanup does nothing, no resources are created.
+func (s *StepPreflight) Cleanup(_ multistep.StateBag) {}
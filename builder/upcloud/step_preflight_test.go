@@ -0,0 +1,117 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// preflightDriver implements read-only methods used in preflight checks. Calling other methods panics.
+type preflightDriver struct {
+	driver.Driver
+}
+
+func (d *preflightDriver) GetAvailableZones(_ context.Context) []string {
+	return []string{"fi-hel1", "fi-hel2", "de-fra1"}
+}
+
+func (d *preflightDriver) GetZonePrices(_ context.Context, _ string) (map[string]upcloud.Price, error) {
+	return map[string]upcloud.Price{
+		"server_plan_1xCPU-2GB": {Amount: 1, Price: 1.5},
+		"server_plan_2xCPU-4GB": {Amount: 1, Price: 3},
+		"storage_maxiops":       {Amount: 1, Price: 0.03},
+		"storage_standard":      {Amount: 1, Price: 0.01},
+	}, nil
+}
+
+func (d *preflightDriver) GetNetwork(_ context.Context, networkUUID string) (*upcloud.Network, error) {
+	return &upcloud.Network{UUID: networkUUID, Zone: "fi-hel2"}, nil
+}
+
+func (d *preflightDriver) GetStorage(_ context.Context, storageUUID, _ string) (*upcloud.Storage, error) {
+	if storageUUID == "" {
+		return &upcloud.Storage{Title: "Ubuntu Server 24.04", Size: 10}, nil
+	}
+	if storageUUID == "01000000-0000-4000-8000-000000000000" {
+		return &upcloud.Storage{UUID: storageUUID, Title: "data", Size: 50}, nil
+	}
+	return nil, errors.New("storage not found")
+}
+
+func TestStepPreflight(t *testing.T) {
+	t.Parallel()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", &preflightDriver{})
+
+	step := &StepPreflight{Config: &Config{
+		Zone:          "fi-hel1",
+		ServerPlan:    "2xCPU-4GB",
+		StorageName:   "ubuntu",
+		StorageSize:   25,
+		StorageTier:   "maxiops",
+		CloneZones:    []string{"de-fra1"},
+		FallbackPlans: []string{"1xCPU-2GB"},
+		StorageDevices: []StorageDevice{
+			{Action: "create", Size: 100, Tier: "standard"},
+			{Action: "clone", SourceUUID: "01000000-0000-4000-8000-000000000000", Tier: "maxiops"},
+		},
+	}}
+	require.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+	storage, ok := state.Get("source_storage").(*upcloud.Storage)
+	require.True(t, ok)
+	assert.Equal(t, "Ubuntu Server 24.04", storage.Title)
+	assert.Equal(t, []int{100, 50}, state.Get("storage_device_sizes"))
+}
+
+func TestStepPreflight_errors(t *testing.T) {
+	t.Parallel()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("driver", &preflightDriver{})
+
+	step := &StepPreflight{Config: &Config{
+		Zone:          "fi-hel1",
+		ServerPlan:    "8xCPU-32GB",
+		StorageName:   "ubuntu",
+		StorageSize:   5,
+		StorageTier:   "hdd",
+		CloneZones:    []string{"xx-xxx1"},
+		FallbackZones: []string{"yy-yyy1"},
+		NetworkInterfaces: []NetworkInterface{
+			{Type: InterfaceTypePrivate, Network: "03000000-0000-4000-8000-000000000000"},
+		},
+		StorageDevices: []StorageDevice{
+			{Action: "clone", SourceUUID: "01000000-0000-4000-8000-000000000000", Size: 20, Tier: "hdd"},
+			{Action: "clone", SourceUUID: "01000000-0000-4000-8000-000000000001", Tier: "hdd"},
+		},
+	}}
+	require.Equal(t, multistep.ActionHalt, step.Run(t.Context(), state))
+
+	var errs *packersdk.MultiError
+	require.ErrorAs(t, state.Get("error").(error), &errs)
+	messages := make([]string, 0, len(errs.Errors))
+	for _, err := range errs.Errors {
+		messages = append(messages, err.Error())
+	}
+	assert.ElementsMatch(t, []string{
+		`'clone_zones' zone "xx-xxx1" is not available`,
+		`'fallback_zones' zone "yy-yyy1" is not available`,
+		`server plan "8xCPU-32GB" is not available in zone fi-hel1`,
+		`storage tier "hdd" is not available in zone fi-hel1`,
+		`'network_interfaces' network "03000000-0000-4000-8000-000000000000" is in zone fi-hel2 instead of fi-hel1`,
+		`'storage_size' 5 is smaller than the size 10 of source storage "Ubuntu Server 24.04"`,
+		`invalid 'storage_device' 1: 'size' 20 is smaller than the size 50 of source storage "data"`,
+		`invalid 'storage_device' 2: storage not found`,
+	}, messages)
+}
@@ -0,0 +1,54 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+// StepSelectPlan represents the step that selects the cheapest server plan meeting minimum resource requirements.
+type StepSelectPlan struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepSelectPlan) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+
+	ui.Say(fmt.Sprintf("Selecting server plan in zone %q...", s.Config.Zone))
+
+	plans, err := drv.GetPlanPrices(ctx, s.Config.Zone)
+	if err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	plan, err := selectPlan(plans, s.Config.planRequirements())
+	if err != nil {
+		if s.Config.MaxHourlyPrice > 0 {
+			err = fmt.Errorf("%w in zone %s for at most %g euros per hour", err, s.Config.Zone, s.Config.MaxHourlyPrice)
+		} else {
+			err = fmt.Errorf("%w in zone %s", err, s.Config.Zone)
+		}
+		return stepHaltWithError(state, err)
+	}
+
+	ui.Say(fmt.Sprintf("Using plan %q (%g euros per hour)", plan.Name, plan.HourlyPrice))
+	state.Put("selected_server_plan", plan.Name)
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing.
+func (s *StepSelectPlan) Cleanup(_ multistep.StateBag) {}
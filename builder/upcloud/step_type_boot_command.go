@@ -0,0 +1,118 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/bootcommand"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/mitchellh/go-vnc"
+)
+
+const (
+	// VNC authentication uses only the first 8 characters of the password.
+	vncPasswordLength = 8
+)
+
+type bootCommandTemplateData struct {
+	HTTPIP   string
+	HTTPPort int
+	Name     string
+}
+
+// StepTypeBootCommand represents the step that waits for the server to boot and types the boot command over the
+// server's VNC console.
+type StepTypeBootCommand struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepTypeBootCommand) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	if s.Config.BootWait <= 0 && len(s.Config.BootCommand) == 0 {
+		return multistep.ActionContinue
+	}
+
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+
+	if s.Config.BootWait > 0 {
+		ui.Say(fmt.Sprintf("Waiting %s for boot...", s.Config.BootWait))
+		select {
+		case <-time.After(s.Config.BootWait):
+		case <-ctx.Done():
+			return stepHaltWithError(state, fmt.Errorf("interrupted while waiting for boot: %w", ctx.Err()))
+		}
+	}
+
+	if len(s.Config.BootCommand) == 0 {
+		return multistep.ActionContinue
+	}
+	vncHost, ok := state.Get("vnc_host").(string)
+	if !ok || vncHost == "" {
+		return stepHaltWithError(state, errors.New("vnc_host is not of expected type"))
+	}
+	vncPort, ok := state.Get("vnc_port").(int)
+	if !ok {
+		return stepHaltWithError(state, errors.New("vnc_port is not of expected type"))
+	}
+	vncPassword, _ := state.Get("vnc_password").(string)
+	httpPort, _ := state.Get("http_port").(int)
+	serverTitle, _ := state.Get("server_title").(string)
+
+	vncAddr := net.JoinHostPort(vncHost, strconv.Itoa(vncPort))
+	ui.Say(fmt.Sprintf("Connecting to VNC console at %s...", vncAddr))
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", vncAddr)
+	if err != nil {
+		return stepHaltWithError(state, fmt.Errorf("failed to connect to VNC console: %w", err))
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	auth := []vnc.ClientAuth{new(vnc.ClientAuthNone)}
+	if vncPassword != "" {
+		auth = []vnc.ClientAuth{&vnc.PasswordAuth{Password: vncPassword}}
+	}
+
+	client, err := vnc.Client(conn, &vnc.ClientConfig{Auth: auth, Exclusive: false})
+	if err != nil {
+		return stepHaltWithError(state, fmt.Errorf("failed to handshake with VNC console: %w", err))
+	}
+	defer func() {
+		_ = client.Close()
+	}()
+
+	s.Config.ctx.Data = &bootCommandTemplateData{
+		HTTPIP:   s.Config.httpIP(),
+		HTTPPort: httpPort,
+		Name:     serverTitle,
+	}
+	command, err := interpolate.Render(s.Config.FlatBootCommand(), &s.Config.ctx)
+	if err != nil {
+		return stepHaltWithError(state, fmt.Errorf("failed to prepare boot command: %w", err))
+	}
+
+	seq, err := bootcommand.GenerateExpressionSequence(command)
+	if err != nil {
+		return stepHaltWithError(state, fmt.Errorf("failed to generate boot command sequence: %w", err))
+	}
+
+	ui.Say("Typing the boot command over VNC...")
+	if err := seq.Do(ctx, bootcommand.NewVNCDriver(client, s.Config.BootKeyInterval)); err != nil {
+		return stepHaltWithError(state, fmt.Errorf("failed to type boot command: %w", err))
+	}
+
+	return multistep.ActionContinue
+}
+
+func (s *StepTypeBootCommand) Cleanup(state multistep.StateBag) {}
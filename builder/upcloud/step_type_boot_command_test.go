@@ -0,0 +1,156 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const vncKeyEventMessage uint8 = 4
+
+// vncStandIn is a minimal RFB server which accepts a single connection without authentication
+// and records key down events sent by the client.
+type vncStandIn struct {
+	listener net.Listener
+	keys     chan uint32
+}
+
+func newVNCStandIn(t *testing.T) *vncStandIn {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = l.Close()
+	})
+	v := &vncStandIn{listener: l, keys: make(chan uint32, 100)}
+	go v.serve(t)
+	return v
+}
+
+func (v *vncStandIn) port() int {
+	addr, _ := v.listener.Addr().(*net.TCPAddr)
+	return addr.Port
+}
+
+func (v *vncStandIn) serve(t *testing.T) {
+	t.Helper()
+	defer close(v.keys)
+
+	conn, err := v.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	clientVersion := make([]byte, 12)
+	clientSecurityType := make([]byte, 1)
+	clientSharedFlag := make([]byte, 1)
+	serverInit := make([]byte, 2+2+16+4) // width, height, pixel format and empty name
+
+	if _, err := conn.Write([]byte("RFB 003.008\n")); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, clientVersion); err != nil {
+		return
+	}
+	// offer only security type 'None'
+	if _, err := conn.Write([]byte{1, 1}); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, clientSecurityType); err != nil {
+		return
+	}
+	if err := binary.Write(conn, binary.BigEndian, uint32(0)); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, clientSharedFlag); err != nil {
+		return
+	}
+	if _, err := conn.Write(serverInit); err != nil {
+		return
+	}
+
+	msg := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(conn, msg); err != nil {
+			return
+		}
+		if msg[0] == vncKeyEventMessage && msg[1] == 1 {
+			v.keys <- binary.BigEndian.Uint32(msg[4:])
+		}
+	}
+}
+
+func TestStepTypeBootCommand(t *testing.T) {
+	t.Parallel()
+
+	vnc := newVNCStandIn(t)
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	state.Put("vnc_host", "127.0.0.1")
+	state.Put("vnc_port", vnc.port())
+	state.Put("http_port", 8080)
+	state.Put("server_title", "packer-custom")
+
+	step := &StepTypeBootCommand{
+		Config: &Config{
+			BootCommand:     []string{"a{{ .HTTPIP }}/{{ .HTTPPort }}", "<enter>"},
+			BootKeyInterval: time.Millisecond,
+			HTTPIP:          "10.0.0.1",
+		},
+	}
+	action := step.Run(t.Context(), state)
+	require.Equal(t, multistep.ActionContinue, action, state.Get("error"))
+
+	want := []uint32{'a', '1', '0', '.', '0', '.', '0', '.', '1', '/', '8', '0', '8', '0', 0xFF0D}
+	got := make([]uint32, 0, len(want))
+	for k := range vnc.keys {
+		got = append(got, k)
+	}
+	assert.Equal(t, want, got)
+}
+
+func TestStepTypeBootCommand_noBootCommand(t *testing.T) {
+	t.Parallel()
+
+	state := new(multistep.BasicStateBag)
+	step := &StepTypeBootCommand{Config: &Config{}}
+	assert.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+}
+
+func TestStepTypeBootCommand_bootWait(t *testing.T) {
+	t.Parallel()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	step := &StepTypeBootCommand{Config: &Config{BootWait: 10 * time.Millisecond}}
+	assert.Equal(t, multistep.ActionContinue, step.Run(t.Context(), state))
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	step.Config.BootWait = time.Hour
+	assert.Equal(t, multistep.ActionHalt, step.Run(ctx, state))
+	assert.ErrorIs(t, state.Get("error").(error), context.Canceled)
+}
+
+func TestStepTypeBootCommand_missingVNC(t *testing.T) {
+	t.Parallel()
+
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", packersdk.TestUi(t))
+	step := &StepTypeBootCommand{Config: &Config{BootCommand: []string{"<enter>"}}}
+	assert.Equal(t, multistep.ActionHalt, step.Run(t.Context(), state))
+	assert.Error(t, state.Get("error").(error))
+}
@@ -0,0 +1,50 @@
+package upcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+// StepWaitServerStopped represents the step that waits for cloud-init to provision and power off the server.
+type StepWaitServerStopped struct {
+	Config *Config
+}
+
+// Run runs the actual step.
+func (s *StepWaitServerStopped) Run(ctx context.Context, state multistep.StateBag) multistep.StepAction {
+	serverUUID, ok := state.Get("server_uuid").(string)
+	if !ok {
+		return stepHaltWithError(state, errors.New("server_uuid is not of expected type"))
+	}
+	serverTitle, ok := state.Get("server_title").(string)
+	if !ok {
+		return stepHaltWithError(state, errors.New("server_title is not of expected type"))
+	}
+	ui, ok := state.Get("ui").(packer.Ui)
+	if !ok {
+		return stepHaltWithError(state, errors.New("UI is not of expected type"))
+	}
+	drv, ok := state.Get("driver").(driver.Driver)
+	if !ok {
+		return stepHaltWithError(state, errors.New("driver is not of expected type"))
+	}
+
+	ui.Say(fmt.Sprintf("Waiting up to %s for cloud-init to power off server %q...", s.Config.CloudInitTimeout, serverTitle))
+
+	if err := drv.WaitServerStopped(ctx, serverUUID, s.Config.CloudInitTimeout); err != nil {
+		return stepHaltWithError(state, err)
+	}
+
+	ui.Say(fmt.Sprintf("Server %q is now in 'stopped' state", serverTitle))
+
+	return multistep.ActionContinue
+}
+
+// Cleanup does nothing, the server is deleted by StepCreateServer.
+func (s *StepWaitServerStopped) Cleanup(_ multistep.StateBag) {}
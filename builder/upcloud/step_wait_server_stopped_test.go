@@ -0,0 +1,59 @@
+//go:build !integration
+
+package upcloud //nolint:testpackage // not all fields can be exported
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+// waitStoppedDriver returns err from WaitServerStopped. Calling other methods panics.
+type waitStoppedDriver struct {
+	driver.Driver
+
+	err     error
+	timeout time.Duration
+}
+
+func (d *waitStoppedDriver) WaitServerStopped(_ context.Context, _ string, timeout time.Duration) error {
+	d.timeout = timeout
+	return d.err
+}
+
+func TestStepWaitServerStopped(t *testing.T) {
+	t.Parallel()
+	for _, tt := range []struct {
+		name   string
+		err    error
+		action multistep.StepAction
+	}{
+		{name: "stopped", action: multistep.ActionContinue},
+		{name: "timeout", err: errors.New("server did not stop within 1m0s"), action: multistep.ActionHalt},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			drv := &waitStoppedDriver{err: tt.err}
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", packersdk.TestUi(t))
+			state.Put("driver", drv)
+			state.Put("server_uuid", "00000000-0000-4000-8000-000000000000")
+			state.Put("server_title", "packer-custom")
+
+			step := &StepWaitServerStopped{Config: &Config{CloudInitTimeout: time.Minute}}
+			require.Equal(t, tt.action, step.Run(t.Context(), state))
+			assert.Equal(t, time.Minute, drv.timeout)
+			if tt.err != nil {
+				assert.Equal(t, tt.err, state.Get("error"))
+			}
+		})
+	}
+}
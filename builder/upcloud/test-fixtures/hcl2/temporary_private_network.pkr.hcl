@@ -0,0 +1,16 @@
+source "upcloud" "temporary_private_network" {
+  storage_name = "Debian GNU/Linux 12 (Bookworm)"
+  storage_size = 10
+  zone         = "pl-waw1"
+
+  temporary_private_network {
+    cidr = "172.31.250.0/24"
+  }
+
+  communicator = "none"
+  boot_wait    = "1m"
+}
+
+build {
+  sources = ["source.upcloud.temporary_private_network"]
+}
@@ -0,0 +1,19 @@
+source "upcloud" "winrm" {
+  server_plan  = "2xCPU-4GB"
+  storage_name = "Windows Server 2022 Standard"
+  storage_size = 40
+  zone         = "pl-waw1"
+
+  communicator   = "winrm"
+  winrm_insecure = true
+  winrm_use_ssl  = true
+  winrm_timeout  = "15m"
+}
+
+build {
+  sources = ["source.upcloud.winrm"]
+
+  provisioner "powershell" {
+    inline = ["Get-ComputerInfo -Property WindowsProductName"]
+  }
+}
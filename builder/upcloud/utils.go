@@ -2,13 +2,22 @@ package upcloud
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
 )
@@ -45,6 +54,20 @@ func findIPAddressByType(addrs upcloud.IPAddressSlice, infType InterfaceType) (*
 	return nil, fmt.Errorf("unable to find '%s' IP address", infType)
 }
 
+// generatePassword returns random alphanumeric password.
+func generatePassword(length int) (string, error) {
+	const chars = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	password := make([]byte, length)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(chars))))
+		if err != nil {
+			return "", fmt.Errorf("failed to generate password: %w", err)
+		}
+		password[i] = chars[n.Int64()]
+	}
+	return string(password), nil
+}
+
 func getNowString() string {
 	return time.Now().Format("20060102-150405")
 }
@@ -62,6 +85,30 @@ func sshHostCallback(state multistep.StateBag) (string, error) {
 	return addr.Address, nil
 }
 
+// winrmHostCallback returns server's IP address.
+// Unlike SSH, WinRM communicator encloses IPv6 address in square brackets by itself.
+func winrmHostCallback(state multistep.StateBag) (string, error) {
+	addr, ok := state.Get("server_ip_address").(*IPAddress)
+	if !ok || addr == nil {
+		return "", errors.New("unable to get server_ip_address from state")
+	}
+	return addr.Address, nil
+}
+
+// winrmConfigCallback returns WinRM credentials using the administrator password generated during server creation.
+func winrmConfigCallback(username string) func(multistep.StateBag) (*communicator.WinRMConfig, error) {
+	return func(state multistep.StateBag) (*communicator.WinRMConfig, error) {
+		password, ok := state.Get("winrm_password").(string)
+		if !ok || password == "" {
+			return nil, errors.New("unable to get winrm_password from state")
+		}
+		return &communicator.WinRMConfig{
+			Username: username,
+			Password: password,
+		}, nil
+	}
+}
+
 func convertNetworkTypes(rawNetworking []NetworkInterface) []request.CreateServerInterface {
 	networking := make([]request.CreateServerInterface, 0, len(rawNetworking))
 	for _, iface := range rawNetworking {
@@ -78,6 +125,135 @@ func convertNetworkTypes(rawNetworking []NetworkInterface) []request.CreateServe
 	return networking
 }
 
+func convertStorageFilter(filter *StorageFilter) *driver.StorageFilter {
+	return &driver.StorageFilter{
+		Name:       filter.Name,
+		NameRegex:  filter.NameRegex,
+		Access:     filter.Access,
+		Type:       filter.Type,
+		Zone:       filter.Zone,
+		Labels:     filter.Labels,
+		MostRecent: filter.MostRecent,
+	}
+}
+
+func convertStorageDevices(devices []StorageDevice) []driver.StorageDeviceOpts {
+	opts := make([]driver.StorageDeviceOpts, 0, len(devices))
+	for _, device := range devices {
+		opts = append(opts, driver.StorageDeviceOpts{
+			Action:      device.Action,
+			StorageUUID: device.SourceUUID,
+			Title:       device.Title,
+			Size:        device.Size,
+			Tier:        device.Tier,
+		})
+	}
+	return opts
+}
+
+// templateDisks returns the system disk and the extra disks which should be templatized.
+func templateDisks(devices []StorageDevice, all bool, disks []upcloud.ServerStorageDevice) []upcloud.ServerStorageDevice {
+	if all || len(disks) == 0 {
+		return disks
+	}
+
+	titles := make(map[string]bool)
+	for _, device := range devices {
+		if device.Template {
+			titles[device.Title] = true
+		}
+	}
+
+	result := []upcloud.ServerStorageDevice{disks[0]}
+	for _, disk := range disks[1:] {
+		if titles[disk.Title] {
+			result = append(result, disk)
+		}
+	}
+	return result
+}
+
+// planRequirements are the requirements of the plan selected by selectPlan. Zero MaxPrice means no price limit.
+type planRequirements struct {
+	MinCPU      int
+	MinMemoryGB int
+	MaxPrice    float64
+	StorageTier string
+	StorageSize int
+	AllowDev    bool
+	AllowGPU    bool
+}
+
+// selectPlan returns the cheapest plan meeting the requirements. Plans including storage are used only if the storage
+// is of the required tier and large enough, and developer and GPU plans only if allowed.
+func selectPlan(plans []driver.PlanPrice, req planRequirements) (*driver.PlanPrice, error) {
+	var selected *driver.PlanPrice
+	for i := range plans {
+		plan := &plans[i]
+		if !planMeetsRequirements(plan, req) {
+			continue
+		}
+		if selected == nil || plan.HourlyPrice < selected.HourlyPrice ||
+			(plan.HourlyPrice == selected.HourlyPrice && plan.Name < selected.Name) {
+			selected = plan
+		}
+	}
+	if selected == nil {
+		return nil, fmt.Errorf("no plan with at least %d CPU cores, %d GB memory and %d GB of %s storage found",
+			req.MinCPU, req.MinMemoryGB, req.StorageSize, req.StorageTier)
+	}
+	return selected, nil
+}
+
+func planMeetsRequirements(plan *driver.PlanPrice, req planRequirements) bool {
+	switch {
+	case plan.CoreNumber < req.MinCPU || plan.MemoryAmount < req.MinMemoryGB*1024:
+		return false
+	case req.MaxPrice > 0 && plan.HourlyPrice > req.MaxPrice:
+		return false
+	case plan.StorageTier != "" && plan.StorageTier != req.StorageTier:
+		return false
+	case plan.StorageSize > 0 && plan.StorageSize < req.StorageSize:
+		return false
+	case !req.AllowDev && strings.HasPrefix(plan.Name, "DEV-"):
+		return false
+	case !req.AllowGPU && (plan.GPUAmount > 0 || strings.HasPrefix(plan.Name, "GPU-")):
+		return false
+	}
+	return true
+}
+
+// serverPlanName returns the name of the plan the server is created with.
+func serverPlanName(opts *driver.ServerOpts) string {
+	switch {
+	case opts.CoreNumber > 0:
+		return driver.CustomPlan
+	case opts.ServerPlan == "":
+		return driver.DefaultPlan
+	default:
+		return opts.ServerPlan
+	}
+}
+
+// templateZones returns zones where templates are cloned when the server is created in serverZone instead of zone.
+func templateZones(zone, serverZone string, cloneZones []string) []string {
+	zones := make([]string, 0, len(cloneZones)+1)
+	for _, z := range append([]string{zone}, cloneZones...) {
+		if z != serverZone && !slices.Contains(zones, z) {
+			zones = append(zones, z)
+		}
+	}
+	return zones
+}
+
+// reportJournal tells user about resources which were not cleaned up.
+func reportJournal(ui packer.Ui, j *journal.Journal) {
+	if entries := j.Entries(); len(entries) > 0 {
+		ui.Error(fmt.Sprintf("%d resources were not cleaned up and are recorded in %s. Use 'upcloud-packer-reaper -recover' to remove them.",
+			len(entries), j.Path()))
+	}
+}
+
 func contextWithDefaultTimeout() (context.Context, context.CancelFunc) {
 	return context.WithTimeout(context.Background(), defaultTimeout)
 }
@@ -94,3 +270,117 @@ func defaultNetworking() []request.CreateServerInterface {
 		},
 	}
 }
+
+// privateNetworking returns interface attached to the private network.
+func privateNetworking(networkUUID, cidr string) request.CreateServerInterface {
+	family := upcloud.IPAddressFamilyIPv4
+	if ip, _, err := net.ParseCIDR(cidr); err == nil && ip.To4() == nil {
+		family = upcloud.IPAddressFamilyIPv6
+	}
+	return request.CreateServerInterface{
+		IPAddresses: []request.CreateServerIPAddress{
+			{
+				Family: family,
+			},
+		},
+		Type:    upcloud.IPAddressAccessPrivate,
+		Network: networkUUID,
+	}
+}
+
+// addressRange returns IP address family and the first and last address of IP address or network in CIDR notation.
+func addressRange(cidr string) (string, string, string, error) {
+	if ip := net.ParseIP(cidr); ip != nil {
+		return addressFamily(ip), ip.String(), ip.String(), nil
+	}
+	ip, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		return "", "", "", fmt.Errorf("invalid IP address or network %q", cidr)
+	}
+	first := network.IP
+	last := make(net.IP, len(first))
+	for i := range first {
+		last[i] = first[i] | ^network.Mask[i]
+	}
+	return addressFamily(ip), first.String(), last.String(), nil
+}
+
+func addressFamily(ip net.IP) string {
+	if ip.To4() != nil {
+		return upcloud.IPAddressFamilyIPv4
+	}
+	return upcloud.IPAddressFamilyIPv6
+}
+
+// firewallRules returns rules allowing communicator port from allowed addresses, extra rules from the configuration
+// and rules dropping other incoming traffic. Communicator rules are omitted when port is 0.
+func firewallRules(fw *TemporaryFirewall, allowed []string, port int) ([]upcloud.FirewallRule, error) {
+	rules := make([]upcloud.FirewallRule, 0, len(allowed)+len(fw.Rules)+2)
+	for _, cidr := range allowed {
+		if port == 0 {
+			break
+		}
+		family, start, end, err := addressRange(cidr)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, upcloud.FirewallRule{
+			Action:               upcloud.FirewallRuleActionAccept,
+			Comment:              "Allow Packer communicator",
+			DestinationPortStart: strconv.Itoa(port),
+			DestinationPortEnd:   strconv.Itoa(port),
+			Direction:            upcloud.FirewallRuleDirectionIn,
+			Family:               family,
+			Protocol:             upcloud.FirewallRuleProtocolTCP,
+			SourceAddressStart:   start,
+			SourceAddressEnd:     end,
+		})
+	}
+
+	for _, r := range fw.Rules {
+		rule := upcloud.FirewallRule{
+			Action:    r.Action,
+			Comment:   r.Comment,
+			Direction: r.Direction,
+			Family:    r.Family,
+			Protocol:  r.Protocol,
+		}
+		if r.SourceCIDR != "" {
+			_, start, end, err := addressRange(r.SourceCIDR)
+			if err != nil {
+				return nil, err
+			}
+			rule.SourceAddressStart, rule.SourceAddressEnd = start, end
+		}
+		if r.DestinationPortStart != 0 {
+			rule.DestinationPortStart = strconv.Itoa(r.DestinationPortStart)
+			rule.DestinationPortEnd = strconv.Itoa(r.DestinationPortEnd)
+		}
+		rules = append(rules, rule)
+	}
+
+	for _, family := range []string{upcloud.IPAddressFamilyIPv4, upcloud.IPAddressFamilyIPv6} {
+		rules = append(rules, upcloud.FirewallRule{
+			Action:    upcloud.FirewallRuleActionDrop,
+			Comment:   "Drop other incoming traffic",
+			Direction: upcloud.FirewallRuleDirectionIn,
+			Family:    family,
+		})
+	}
+	return rules, nil
+}
+
+// publicInterfaceMAC returns MAC address of the first public network interface with IPv4 address.
+func publicInterfaceMAC(details *upcloud.ServerDetails) (string, error) {
+	for _, iface := range details.Networking.Interfaces {
+		if iface.Type != upcloud.NetworkTypePublic {
+			continue
+		}
+		for _, ip := range iface.IPAddresses {
+			if ip.Family == upcloud.IPAddressFamilyIPv4 {
+				return iface.MAC, nil
+			}
+		}
+	}
+	return "", errors.New("unable to find public IPv4 network interface for floating IP address")
+}
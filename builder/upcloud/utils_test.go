@@ -3,11 +3,14 @@
 package upcloud //nolint:testpackage // not all fields can be exported
 
 import (
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
 )
@@ -103,6 +106,63 @@ func TestSSHHostCallback(t *testing.T) {
 	}
 }
 
+func TestWinRMHostCallback(t *testing.T) {
+	t.Parallel()
+	state := multistep.BasicStateBag{}
+	state.Put("server_ip_address", &IPAddress{
+		Family:  "IPv6",
+		Address: "IPv6_address",
+	})
+	want := "IPv6_address"
+	got, err := winrmHostCallback(&state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("IPv6 winrmHostCallback failed want %s got %s", want, got)
+	}
+
+	if _, err := winrmHostCallback(&multistep.BasicStateBag{}); err == nil {
+		t.Error("winrmHostCallback should fail when server_ip_address is missing")
+	}
+}
+
+func TestWinRMConfigCallback(t *testing.T) {
+	t.Parallel()
+	state := multistep.BasicStateBag{}
+	callback := winrmConfigCallback("Administrator")
+	if _, err := callback(&state); err == nil {
+		t.Error("winrmConfigCallback should fail when winrm_password is missing")
+	}
+
+	state.Put("winrm_password", "secret")
+	got, err := callback(&state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Username != "Administrator" || got.Password != "secret" {
+		t.Errorf("winrmConfigCallback failed got %+v", got)
+	}
+}
+
+func TestGeneratePassword(t *testing.T) {
+	t.Parallel()
+	a, err := generatePassword(vncPasswordLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := generatePassword(vncPasswordLength)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(a) != vncPasswordLength || len(b) != vncPasswordLength {
+		t.Errorf("generatePassword returned invalid length %d, %d", len(a), len(b))
+	}
+	if a == b {
+		t.Errorf("generatePassword returned same password twice: %s", a)
+	}
+}
+
 func TestConvertNetworkTypes(t *testing.T) {
 	t.Parallel()
 	want := []request.CreateServerInterface{
@@ -169,3 +229,198 @@ func TestConvertNetworkTypes(t *testing.T) {
 		}
 	}
 }
+
+func TestPrivateNetworking(t *testing.T) {
+	t.Parallel()
+	want := request.CreateServerInterface{
+		IPAddresses: []request.CreateServerIPAddress{{Family: upcloud.IPAddressFamilyIPv4}},
+		Type:        upcloud.IPAddressAccessPrivate,
+		Network:     "03000000-0000-4000-8000-000000000000",
+	}
+	got := privateNetworking("03000000-0000-4000-8000-000000000000", "10.0.0.0/24")
+	if !reflect.DeepEqual(want, got) {
+		t.Fatalf("privateNetworking failed want %+v got %+v", want, got)
+	}
+
+	got = privateNetworking("03000000-0000-4000-8000-000000000000", "fd00::/64")
+	if got.IPAddresses[0].Family != upcloud.IPAddressFamilyIPv6 {
+		t.Errorf("privateNetworking returned invalid family %s for IPv6 network", got.IPAddresses[0].Family)
+	}
+}
+
+func TestAddressRange(t *testing.T) {
+	t.Parallel()
+	for cidr, want := range map[string][3]string{
+		"192.0.2.10":      {upcloud.IPAddressFamilyIPv4, "192.0.2.10", "192.0.2.10"},
+		"192.0.2.0/23":    {upcloud.IPAddressFamilyIPv4, "192.0.2.0", "192.0.3.255"},
+		"2001:db8::1/126": {upcloud.IPAddressFamilyIPv6, "2001:db8::", "2001:db8::3"},
+		"2001:db8::abcd":  {upcloud.IPAddressFamilyIPv6, "2001:db8::abcd", "2001:db8::abcd"},
+	} {
+		family, start, end, err := addressRange(cidr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := [3]string{family, start, end}; got != want {
+			t.Errorf("addressRange(%q) want %v got %v", cidr, want, got)
+		}
+	}
+	if _, _, _, err := addressRange("192.0.2.0/33"); err == nil {
+		t.Error("addressRange accepted invalid network")
+	}
+}
+
+func TestFirewallRules(t *testing.T) {
+	t.Parallel()
+	fw := &TemporaryFirewall{
+		Rules: []FirewallRule{{
+			Action:               upcloud.FirewallRuleActionAccept,
+			Direction:            upcloud.FirewallRuleDirectionIn,
+			Family:               upcloud.IPAddressFamilyIPv4,
+			Protocol:             upcloud.FirewallRuleProtocolTCP,
+			DestinationPortStart: 8000,
+			DestinationPortEnd:   8080,
+		}},
+	}
+	rules, err := firewallRules(fw, []string{"192.0.2.10", "2001:db8::/64"}, 22)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 5 {
+		t.Fatalf("firewallRules returned %d rules, want 5", len(rules))
+	}
+	if rules[1].Family != upcloud.IPAddressFamilyIPv6 || rules[1].SourceAddressEnd != "2001:db8::ffff:ffff:ffff:ffff" {
+		t.Errorf("firewallRules returned invalid IPv6 communicator rule %+v", rules[1])
+	}
+	if rules[2].DestinationPortStart != "8000" || rules[2].DestinationPortEnd != "8080" {
+		t.Errorf("firewallRules returned invalid extra rule %+v", rules[2])
+	}
+
+	// communicator rules are omitted without communicator
+	rules, err = firewallRules(fw, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 3 || rules[2].Action != upcloud.FirewallRuleActionDrop {
+		t.Errorf("firewallRules returned invalid rules %+v", rules)
+	}
+}
+
+func TestConfig_userData(t *testing.T) {
+	t.Parallel()
+	path := filepath.Join(t.TempDir(), "user-data.yaml")
+	if err := os.WriteFile(path, []byte("#cloud-config\npackages: [nginx]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Config{UserDataFile: path}
+	got, err := c.userData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "#cloud-config\npackages: [nginx]\n" {
+		t.Errorf("userData returned unexpected content %q", got)
+	}
+
+	c = &Config{UserData: "#cloud-config"}
+	if got, _ := c.userData(); got != "#cloud-config" {
+		t.Errorf("userData returned unexpected content %q", got)
+	}
+}
+
+func TestTemplateDisks(t *testing.T) {
+	t.Parallel()
+	devices := []StorageDevice{
+		{Title: "data", Template: true},
+		{Title: "scratch"},
+	}
+	disks := []upcloud.ServerStorageDevice{
+		{UUID: "system", Title: "custom-disk1", Type: upcloud.StorageTypeDisk},
+		{UUID: "data", Title: "data", Type: upcloud.StorageTypeDisk},
+		{UUID: "scratch", Title: "scratch", Type: upcloud.StorageTypeDisk},
+	}
+	want := []upcloud.ServerStorageDevice{disks[0], disks[1]}
+	if got := templateDisks(devices, false, disks); !reflect.DeepEqual(got, want) {
+		t.Errorf("templateDisks = %+v, want %+v", got, want)
+	}
+	if got := templateDisks(devices, true, disks); !reflect.DeepEqual(got, disks) {
+		t.Errorf("templateDisks = %+v, want all disks %+v", got, disks)
+	}
+}
+
+func TestSelectPlan(t *testing.T) {
+	t.Parallel()
+	plans := []driver.PlanPrice{
+		{Plan: upcloud.Plan{Name: "1xCPU-2GB", CoreNumber: 1, MemoryAmount: 2048, StorageSize: 50, StorageTier: upcloud.StorageTierMaxIOPS}, HourlyPrice: 0.0149},
+		{Plan: upcloud.Plan{Name: "4xCPU-8GB", CoreNumber: 4, MemoryAmount: 8192, StorageSize: 160, StorageTier: upcloud.StorageTierMaxIOPS}, HourlyPrice: 0.0595},
+		{Plan: upcloud.Plan{Name: "HICPU-8xCPU-12GB", CoreNumber: 8, MemoryAmount: 12288, StorageSize: 100, StorageTier: upcloud.StorageTierMaxIOPS}, HourlyPrice: 0.1429},
+		{Plan: upcloud.Plan{Name: "DEV-4xCPU-8GB", CoreNumber: 4, MemoryAmount: 8192, StorageSize: 60, StorageTier: upcloud.StorageTierStandard}, HourlyPrice: 0.0476},
+		{Plan: upcloud.Plan{Name: "GPU-8xCPU-64GB-1xL40S", CoreNumber: 8, MemoryAmount: 65536, StorageSize: 960, StorageTier: upcloud.StorageTierMaxIOPS, GPUAmount: 1}, HourlyPrice: 0.0001},
+	}
+	req := planRequirements{MinCPU: 4, MinMemoryGB: 8, StorageTier: upcloud.StorageTierMaxIOPS, StorageSize: 25}
+
+	plan, err := selectPlan(plans, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "4xCPU-8GB" {
+		t.Errorf("selectPlan selected %q, want 4xCPU-8GB", plan.Name)
+	}
+
+	// cheapest plan includes storage of wrong tier
+	devReq := req
+	devReq.AllowDev = true
+	plan, err = selectPlan(plans, devReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "4xCPU-8GB" {
+		t.Errorf("selectPlan selected %q with wrong storage tier, want 4xCPU-8GB", plan.Name)
+	}
+	devReq.StorageTier = upcloud.StorageTierStandard
+	plan, err = selectPlan(plans, devReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "DEV-4xCPU-8GB" {
+		t.Errorf("selectPlan selected %q, want DEV-4xCPU-8GB", plan.Name)
+	}
+
+	gpuReq := req
+	gpuReq.AllowGPU = true
+	plan, err = selectPlan(plans, gpuReq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "GPU-8xCPU-64GB-1xL40S" {
+		t.Errorf("selectPlan selected %q, want GPU-8xCPU-64GB-1xL40S", plan.Name)
+	}
+
+	req.MinCPU = 6
+	plan, err = selectPlan(plans, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Name != "HICPU-8xCPU-12GB" {
+		t.Errorf("selectPlan selected %q, want HICPU-8xCPU-12GB", plan.Name)
+	}
+
+	req.StorageSize = 120
+	if _, err := selectPlan(plans, req); err == nil {
+		t.Error("selectPlan did not fail when plans include too little storage")
+	}
+
+	req.StorageSize = 25
+	req.MaxPrice = 0.1
+	if _, err := selectPlan(plans, req); err == nil {
+		t.Error("selectPlan did not fail when plans exceed maximum price")
+	}
+}
+
+func TestTemplateZones(t *testing.T) {
+	t.Parallel()
+	got := templateZones("fi-hel1", "fi-hel2", []string{"fi-hel2", "de-fra1", "fi-hel1"})
+	want := []string{"fi-hel1", "de-fra1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("templateZones = %v, want %v", got, want)
+	}
+}
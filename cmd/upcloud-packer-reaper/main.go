@@ -0,0 +1,146 @@
+// Command upcloud-packer-reaper deletes servers and storages left behind by interrupted Packer builds.
+//
+// Resources are identified by the titles and labels set by the UpCloud Packer plugin. By default, only a report
+// of the matching resources is printed and confirmation is asked before deleting them. Use -yes to delete
+// without confirmation, e.g. when running from cron.
+//
+// With -recover, resources recorded in journals of crashed builds are deleted instead. Journals created on other
+// hosts, e.g. in a shared cache directory, are recovered once they have not been written for -min-age.
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/reaper"
+)
+
+const (
+	defaultMinAge  time.Duration = 6 * time.Hour
+	defaultTimeout time.Duration = 20 * time.Minute
+)
+
+type labelsFlag map[string]string
+
+func (l labelsFlag) String() string {
+	pairs := make([]string, 0, len(l))
+	for k, v := range l {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (l labelsFlag) Set(value string) error {
+	k, v, ok := strings.Cut(value, "=")
+	if !ok || k == "" {
+		return fmt.Errorf("label %q is not in key=value format", value)
+	}
+	l[k] = v
+	return nil
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer) error {
+	labels := make(labelsFlag)
+	fs := flag.NewFlagSet("upcloud-packer-reaper", flag.ContinueOnError)
+	fs.SetOutput(stdout)
+	minAge := fs.Duration("min-age", defaultMinAge, "minimum age of resources to delete")
+	zone := fs.String("zone", "", "only delete resources in this zone")
+	dryRun := fs.Bool("dry-run", false, "only print the report, do not delete anything")
+	yes := fs.Bool("yes", false, "delete without asking for confirmation")
+	timeout := fs.Duration("timeout", defaultTimeout, "amount of time to wait for resource state changes")
+	fs.Var(labels, "label", "only delete resources with this `key=value` label, can be repeated")
+	recoverJournals := fs.Bool("recover", false, "delete resources recorded in journals of crashed builds instead of searching by title and labels")
+	journalDir := fs.String("journal-dir", "", "journal directory used with -recover, defaults to the Packer cache directory")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: upcloud-packer-reaper [options]\n\n"+
+			"Deletes servers and storages left behind by interrupted UpCloud Packer builds.\n"+
+			"Credentials are read from UPCLOUD_USERNAME and UPCLOUD_PASSWORD or UPCLOUD_TOKEN environment variables.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err //nolint:wrapcheck // flag errors are descriptive
+	}
+
+	creds, err := driver.CredentialsFromEnv("", "", "")
+	if err != nil {
+		return err //nolint:wrapcheck // Use the original error from shared credentials package
+	}
+	drv := driver.NewDriver(&driver.DriverConfig{
+		Username: creds.Username,
+		Password: creds.Password,
+		Token:    creds.Token,
+		Timeout:  *timeout,
+		APIURL:   driver.APIURLFromEnv(""),
+	})
+
+	r := reaper.New(drv, reaper.Options{
+		MinAge: *minAge,
+		Zone:   *zone,
+		Labels: labels,
+	})
+	resources, err := findResources(ctx, r, *recoverJournals, *journalDir)
+	if err != nil {
+		return fmt.Errorf("failed to find resources: %w", err)
+	}
+	if len(resources) == 0 {
+		fmt.Fprintln(stdout, "No orphaned resources found.")
+		return nil
+	}
+	if err := r.WriteReport(stdout, resources); err != nil {
+		return err //nolint:wrapcheck // writer errors are returned as is
+	}
+
+	if *dryRun {
+		return nil
+	}
+	if !*yes && !confirm(stdin, stdout, len(resources)) {
+		fmt.Fprintln(stdout, "Nothing deleted.")
+		return nil
+	}
+	return r.Delete(ctx, stdout, resources) //nolint:wrapcheck // errors are already wrapped
+}
+
+func findResources(ctx context.Context, r *reaper.Reaper, recoverJournals bool, journalDir string) ([]reaper.Resource, error) {
+	if !recoverJournals {
+		return r.Find(ctx) //nolint:wrapcheck // wrapped by the caller
+	}
+	if journalDir == "" {
+		var err error
+		if journalDir, err = journal.DefaultDir(); err != nil {
+			return nil, err //nolint:wrapcheck // wrapped by the caller
+		}
+	}
+	return r.FromJournals(journalDir) //nolint:wrapcheck // wrapped by the caller
+}
+
+func confirm(stdin io.Reader, stdout io.Writer, count int) bool {
+	fmt.Fprintf(stdout, "\nDelete %d resources? Only 'yes' will be accepted: ", count)
+	answer, err := bufio.NewReader(stdin).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false
+	}
+	return strings.TrimSpace(answer) == "yes"
+}
@@ -0,0 +1,22 @@
+source "upcloud" "debian" {
+  zone             = "pl-waw1"
+  iso_storage_name = "Debian GNU/Linux 12 (Bookworm) Installation CD"
+  storage_size     = 20
+  template_prefix  = "debian-iso"
+  boot_wait        = "10s"
+  http_directory   = "http"
+  http_ip          = "203.0.113.10" # public address of the machine running Packer
+
+  boot_command = [
+    "<esc><wait>",
+    "auto url=http://{{ .HTTPIP }}:{{ .HTTPPort }}/preseed.cfg<enter>",
+  ]
+
+  ssh_username = "root"
+  ssh_password = "packer"
+  ssh_timeout  = "30m"
+}
+
+build {
+  sources = ["source.upcloud.debian"]
+}
@@ -0,0 +1,19 @@
+source "upcloud" "hardened" {
+  zone            = "fi-hel1"
+  template_prefix = "ubuntu-hardened"
+
+  source_storage_filter {
+    name_regex  = "^ubuntu-server-24\\.04-hardened"
+    access      = "private"
+    zone        = "fi-hel1"
+    most_recent = true
+
+    labels = {
+      os = "ubuntu"
+    }
+  }
+}
+
+build {
+  sources = ["source.upcloud.hardened"]
+}
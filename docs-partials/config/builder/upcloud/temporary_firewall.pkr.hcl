@@ -0,0 +1,16 @@
+source "upcloud" "example" {
+  zone         = "fi-hel1"
+  storage_name = "ubuntu server 24.04"
+
+  temporary_firewall {
+    # Defaults to the public IP addresses of the machine running Packer
+    allowed_cidrs = ["192.0.2.0/24"]
+
+    # Allow HTTPS from everywhere, e.g. to test a web server during provisioning
+    rules {
+      family                 = "IPv4"
+      protocol               = "tcp"
+      destination_port_start = 443
+    }
+  }
+}
@@ -0,0 +1,10 @@
+source "upcloud" "example" {
+  zone         = "fi-hel1"
+  storage_name = "ubuntu server 24.04"
+
+  temporary_private_network {
+    cidr = "10.0.0.0/24"
+    # Optionally attach an existing router, e.g. to reach other private networks
+    # router = "<router_uuid>"
+  }
+}
@@ -0,0 +1,19 @@
+source "upcloud" "windows" {
+  zone            = "pl-waw1"
+  storage_name    = "Windows Server 2022 Standard"
+  storage_size    = 40
+  template_prefix = "windows-server"
+
+  communicator   = "winrm"
+  winrm_insecure = true
+  winrm_use_ssl  = true
+  winrm_timeout  = "15m"
+}
+
+build {
+  sources = ["source.upcloud.windows"]
+
+  provisioner "powershell" {
+    inline = ["Install-WindowsFeature -Name Web-Server"]
+  }
+}
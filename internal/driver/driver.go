@@ -2,13 +2,18 @@ package driver
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"os"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
 	"github.com/UpCloudLtd/upcloud-go-api/credentials"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
@@ -19,33 +24,52 @@ import (
 const (
 	DefaultPlan                      string = "1xCPU-2GB"
 	DefaultHostname                  string = "custom"
+	CustomPlan                       string = "custom"
+	SystemDiskTitle                  string = DefaultHostname + "-disk1"
 	EnvConfigUsername                string = "UPCLOUD_USERNAME"
 	EnvConfigPassword                string = "UPCLOUD_PASSWORD"
 	EnvConfigAPIToken                string = "UPCLOUD_TOKEN"
 	EnvConfigUsernameLegacy          string = "UPCLOUD_API_USER"
 	EnvConfigPasswordLegacy          string = "UPCLOUD_API_PASSWORD"
+	EnvConfigAPIURL                  string = "UPCLOUD_API_URL"
 	upcloudErrorCodeMetadataDisabled string = "METADATA_DISABLED_ON_CLOUD-INIT"
+	storageTierArchive               string = "archive"
 )
 
+// capacityErrorCodes are returned when the zone lacks capacity for the requested resources.
+var capacityErrorCodes = []string{ //nolint:gochecknoglobals // constant list
+	upcloud.ErrCodeServerResourcesUnavailable,
+	upcloud.ErrCodeStorageResourcesUnavailable,
+	upcloud.ErrCodeIpAddressResourcesUnavailable,
+}
+
 type (
 	// ServerManager handles server lifecycle operations.
 	ServerManager interface {
-		CreateServer(ctx context.Context, opts *ServerOpts) (*upcloud.ServerDetails, error)
+		CreateServer(ctx context.Context, opts *ServerOpts) (*ServerDetails, error)
 		DeleteServer(ctx context.Context, serverUUID string) error
 		StopServer(ctx context.Context, serverUUID string) error
-		// TODO: rename method or split into two separate method GetStorageByUUID and GetTemplateByName
-		GetServerStorage(ctx context.Context, serverUUID string) (*upcloud.ServerStorageDevice, error)
+		WaitServerStopped(ctx context.Context, serverUUID string, timeout time.Duration) error
+		EjectCDROM(ctx context.Context, serverUUID string) error
+		GetServerDetails(ctx context.Context, serverUUID string) (*upcloud.ServerDetails, error)
+		ListServers(ctx context.Context, filters ...request.QueryFilter) ([]upcloud.Server, error)
+		GetServerStorages(ctx context.Context, serverUUID string) ([]upcloud.ServerStorageDevice, error)
 	}
 
 	// StorageManager handles storage operations.
 	StorageManager interface {
 		GetStorage(ctx context.Context, storageUUID, templateName string) (*upcloud.Storage, error)
+		GetISOStorage(ctx context.Context, storageUUID, storageName string) (*upcloud.Storage, error)
+		FindStorage(ctx context.Context, filter *StorageFilter) (*upcloud.Storage, error)
 		RenameStorage(ctx context.Context, storageUUID, name string) (*upcloud.Storage, error)
-		CloneStorage(ctx context.Context, storageUUID, zone, title string) (*upcloud.Storage, error)
-		CreateTemplateStorage(ctx context.Context, title, zone string, size int, tier string) (*upcloud.Storage, error)
+		CloneStorage(ctx context.Context, storageUUID, zone, title string, encrypted bool) (*upcloud.Storage, error)
+		CreateTemplateStorage(ctx context.Context, title, zone string, size int, tier string, encrypted bool) (*upcloud.Storage, error)
 		ImportStorage(ctx context.Context, storageUUID, contentType string, f io.Reader) (*upcloud.StorageImportDetails, error)
 		WaitStorageOnline(ctx context.Context, storageUUID string) (*upcloud.Storage, error)
 		DeleteStorage(ctx context.Context, storageUUID string) error
+		SetStorageLabels(ctx context.Context, storageUUID string, labels map[string]string) error
+		GetStorageDetails(ctx context.Context, storageUUID string) (*upcloud.StorageDetails, error)
+		ListStorages(ctx context.Context, access, storageType string) ([]upcloud.Storage, error)
 	}
 
 	// TemplateManager handles template operations.
@@ -60,16 +84,54 @@ type (
 		GetAvailableZones(ctx context.Context) []string
 	}
 
+	// PlanManager handles server plan and price operations.
+	PlanManager interface {
+		GetPlanPrices(ctx context.Context, zone string) ([]PlanPrice, error)
+		GetZonePrices(ctx context.Context, zone string) (map[string]upcloud.Price, error)
+	}
+
+	// AccountManager handles account operations.
+	AccountManager interface {
+		GetAccount(ctx context.Context) (*upcloud.Account, error)
+	}
+
+	// NetworkManager handles private network operations.
+	NetworkManager interface {
+		CreateNetwork(ctx context.Context, opts *NetworkOpts) (*upcloud.Network, error)
+		DeleteNetwork(ctx context.Context, networkUUID string) error
+		GetNetwork(ctx context.Context, networkUUID string) (*upcloud.Network, error)
+	}
+
+	// FirewallManager handles server firewall operations.
+	FirewallManager interface {
+		CreateFirewallRules(ctx context.Context, serverUUID string, rules []upcloud.FirewallRule) error
+		EnableFirewall(ctx context.Context, serverUUID string) error
+	}
+
+	// IPAddressManager handles floating IP address operations.
+	IPAddressManager interface {
+		CreateFloatingIPAddress(ctx context.Context, zone, mac string) (*upcloud.IPAddress, error)
+		AttachFloatingIPAddress(ctx context.Context, address, mac string) (*upcloud.IPAddress, error)
+		DetachFloatingIPAddress(ctx context.Context, address string) error
+		ReleaseIPAddress(ctx context.Context, address string) error
+	}
+
 	// Driver combines all management interfaces.
 	Driver interface {
 		ServerManager
 		StorageManager
 		TemplateManager
 		ZoneManager
+		PlanManager
+		AccountManager
+		NetworkManager
+		FirewallManager
+		IPAddressManager
 	}
 
 	driver struct {
 		svc    *service.Service
+		client service.Client
 		config *DriverConfig
 	}
 
@@ -79,6 +141,27 @@ type (
 		Token       string
 		Timeout     time.Duration
 		SSHUsername string
+
+		// Journal records created resources so that they can be removed if the build is killed. Optional.
+		Journal *journal.Journal
+
+		// Retry configures retrying of failed API requests. Zero value uses default settings.
+		Retry RetryConfig
+
+		// APIURL overrides the base URL of the API, e.g. for the local API emulator. Optional.
+		APIURL string
+
+		// HTTPProxy is the URL of the proxy used for API requests and image uploads instead of the proxy
+		// environment variables. Optional.
+		HTTPProxy string
+
+		// CABundleFile is the path to PEM encoded CA certificates trusted in addition to the system certificates,
+		// e.g. of a TLS intercepting proxy. Optional.
+		CABundleFile string
+
+		// APIDebugLog is the path to a file where API requests are traced in addition to Packer's log. Tracing is
+		// enabled when set or when EnvConfigAPIDebug is set to 1. Optional.
+		APIDebugLog string
 	}
 
 	ServerOpts struct {
@@ -89,36 +172,147 @@ type (
 		SSHPublicKey string
 		Networking   []request.CreateServerInterface
 		StorageTier  string
+
+		// CreatePassword requests the API to generate a login password instead of
+		// authorizing SSH public key. Generated password is returned in ServerDetails.
+		CreatePassword bool
+
+		// ISOStorageUUID creates an empty disk and attaches the ISO storage as CD-ROM
+		// instead of cloning the disk from StorageUUID.
+		ISOStorageUUID string
+
+		// RemoteAccessPassword enables VNC console using the given password.
+		RemoteAccessPassword string
+
+		// Labels are set to the server during creation.
+		Labels map[string]string
+
+		// StorageEncryption encrypts the server disk at rest.
+		StorageEncryption bool
+
+		// UserData is passed to cloud-init using the metadata service.
+		UserData string
+
+		// Metadata enables or disables the metadata service. When nil, the metadata service is enabled if
+		// user data is set or if the API requires it for the storage template.
+		Metadata *bool
+
+		// StorageDevices are extra disks attached after the system disk. They are deleted together with the server.
+		StorageDevices []StorageDeviceOpts
+
+		// CoreNumber and MemoryAmount (MiB) create the server using custom plan instead of ServerPlan.
+		CoreNumber   int
+		MemoryAmount int
+	}
+
+	// StorageDeviceOpts defines an extra disk of the server.
+	StorageDeviceOpts struct {
+		// Action is either create or clone.
+		Action string
+		// StorageUUID is the storage to clone.
+		StorageUUID string
+		Title       string
+		// Size in gigabytes. Optional when cloning.
+		Size int
+		Tier string
+	}
+
+	NetworkOpts struct {
+		Zone string
+		// Address is the IP network in CIDR notation, e.g. 10.0.0.0/24.
+		Address string
+		DHCP    bool
+		// Router is the UUID of the router attached to the network. Optional.
+		Router string
+		Labels map[string]string
+	}
+
+	// StorageFilter defines criteria for selecting exactly one storage. Empty fields match any storage.
+	StorageFilter struct {
+		// Name matches storage title exactly (case-insensitive).
+		Name string
+		// NameRegex matches storage title against regular expression.
+		NameRegex string
+		// Access matches storage access type (public or private).
+		Access string
+		// Type matches storage type (e.g. template or normal).
+		Type string
+		// Zone matches storage zone.
+		Zone string
+		// Labels must all be present in storage labels with the same values.
+		Labels map[string]string
+		// MostRecent selects the most recently created storage when several storages match.
+		MostRecent bool
+	}
+
+	// ServerDetails extends server details with login credentials which are
+	// returned only in the server creation response.
+	ServerDetails struct {
+		upcloud.ServerDetails
+
+		Username string
+		Password string
+	}
+
+	// PlanPrice is a server plan available in a zone.
+	PlanPrice struct {
+		upcloud.Plan
+
+		// HourlyPrice of the plan in euros.
+		HourlyPrice float64
+	}
+
+	// responseRecorder records the response body of the last successful POST request.
+	responseRecorder struct {
+		service.Client
+
+		body []byte
 	}
 )
 
 func NewDriver(c *DriverConfig) Driver {
 	var cl *client.Client
 
+	opts := make([]client.ConfigFn, 0)
+	if c.APIURL != "" {
+		opts = append(opts, client.WithBaseURL(c.APIURL))
+	}
+	httpClient := newHTTPClient(c)
+	if apiDebugEnabled(c) {
+		if httpClient == nil {
+			httpClient = client.NewDefaultHTTPClient()
+		}
+		httpClient.Transport = newTraceTransport(httpClient.Transport, c.APIDebugLog)
+	}
+	if httpClient != nil {
+		opts = append(opts, client.WithHTTPClient(httpClient))
+	}
+
 	// Use API token if provided, otherwise fall back to username/password
 	if c.Token != "" {
 		// TODO: Update this with a proper token auth wrapper when upcloud-go-api supports it
-		cl = client.New("", "", client.WithBearerAuth(c.Token))
+		cl = client.New("", "", append(opts, client.WithBearerAuth(c.Token))...)
 	} else {
-		cl = client.New(c.Username, c.Password)
+		cl = client.New(c.Username, c.Password, opts...)
 	}
 
-	svc := service.New(cl)
+	ec := &errorClient{Client: newRetryClient(cl, c.Retry)}
 	return &driver{
-		svc:    svc,
+		svc:    service.New(ec),
+		client: ec,
 		config: c,
 	}
 }
 
-func (d *driver) CreateServer(ctx context.Context, opts *ServerOpts) (*upcloud.ServerDetails, error) {
+func (d *driver) CreateServer(ctx context.Context, opts *ServerOpts) (*ServerDetails, error) {
 	// Create server
 	request := d.prepareCreateRequest(opts)
-	response, err := d.svc.CreateServer(ctx, request)
+	response, err := d.createServer(ctx, request)
 	if err != nil {
 		var upcloudErr *upcloud.Problem
-		if errors.As(err, &upcloudErr) && upcloudErr.ErrorCode() == upcloudErrorCodeMetadataDisabled {
+		if opts.Metadata == nil && errors.As(err, &upcloudErr) && upcloudErr.ErrorCode() == upcloudErrorCodeMetadataDisabled {
 			request.Metadata = upcloud.True
-			if response, err = d.svc.CreateServer(ctx, request); err != nil {
+			if response, err = d.createServer(ctx, request); err != nil {
 				return nil, fmt.Errorf("error creating metadata enabled server: %w", err)
 			}
 		} else {
@@ -134,6 +328,33 @@ func (d *driver) CreateServer(ctx context.Context, opts *ServerOpts) (*upcloud.S
 	return response, nil
 }
 
+// createServer creates a server and reads login credentials from the raw response,
+// because they are not part of upcloud.ServerDetails.
+func (d *driver) createServer(ctx context.Context, r *request.CreateServerRequest) (*ServerDetails, error) {
+	recorder := &responseRecorder{Client: d.client}
+	details, err := service.New(recorder).CreateServer(ctx, r)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // error is wrapped by the caller
+	}
+	d.record(journal.KindServer, details.UUID, details.Zone, details.Title)
+
+	credentials := struct {
+		Server struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"server"`
+	}{}
+	if err := json.Unmarshal(recorder.body, &credentials); err != nil {
+		return nil, fmt.Errorf("failed to parse server credentials: %w", err)
+	}
+
+	return &ServerDetails{
+		ServerDetails: *details,
+		Username:      credentials.Server.Username,
+		Password:      credentials.Server.Password,
+	}, nil
+}
+
 func (d *driver) DeleteServer(ctx context.Context, serverUUID string) error {
 	err := d.svc.DeleteServerAndStorages(ctx, &request.DeleteServerAndStoragesRequest{
 		UUID: serverUUID,
@@ -141,6 +362,7 @@ func (d *driver) DeleteServer(ctx context.Context, serverUUID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete server %s and its storages: %w", serverUUID, err)
 	}
+	d.forget(serverUUID)
 	return nil
 }
 
@@ -152,7 +374,7 @@ func (d *driver) StopServer(ctx context.Context, serverUUID string) error {
 	}
 
 	// Check current server state and do nothing if already stopped
-	response, err := d.getServerDetails(ctx, serverUUID)
+	response, err := d.GetServerDetails(ctx, serverUUID)
 	if err != nil {
 		return err
 	}
@@ -177,6 +399,33 @@ func (d *driver) StopServer(ctx context.Context, serverUUID string) error {
 	return nil
 }
 
+// WaitServerStopped waits until the server stops by itself, e.g. when cloud-init powers off the server.
+func (d *driver) WaitServerStopped(ctx context.Context, serverUUID string, timeout time.Duration) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	_, err := d.svc.WaitForServerState(timeoutCtx, &request.WaitForServerStateRequest{
+		UUID:         serverUUID,
+		DesiredState: upcloud.ServerStateStopped,
+	})
+	if err != nil {
+		if ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("server %s did not stop within %s: %w", serverUUID, timeout, ErrTimeout)
+		}
+		return fmt.Errorf("error while waiting for server %s to stop: %w", serverUUID, err)
+	}
+	return nil
+}
+
+func (d *driver) EjectCDROM(ctx context.Context, serverUUID string) error {
+	if _, err := d.svc.EjectCDROM(ctx, &request.EjectCDROMRequest{
+		ServerUUID: serverUUID,
+	}); err != nil {
+		return fmt.Errorf("failed to eject CD-ROM from server %s: %w", serverUUID, err)
+	}
+	return nil
+}
+
 func (d *driver) CreateTemplate(ctx context.Context, serverStorageUUID, templateTitle string) (*upcloud.Storage, error) {
 	// create image
 	response, err := d.svc.TemplatizeStorage(ctx, &request.TemplatizeStorageRequest{
@@ -186,6 +435,7 @@ func (d *driver) CreateTemplate(ctx context.Context, serverStorageUUID, template
 	if err != nil {
 		return nil, fmt.Errorf("error creating image: %w", err)
 	}
+	d.record(journal.KindTemplate, response.UUID, response.Zone, response.Title)
 	return d.WaitStorageOnline(ctx, response.UUID)
 }
 
@@ -198,7 +448,7 @@ func (d *driver) WaitStorageOnline(ctx context.Context, storageUUID string) (*up
 		DesiredState: upcloud.StorageStateOnline,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("error while waiting for storage to change state to 'online': %w", err)
+		return nil, fmt.Errorf("error while waiting for storage to change state to 'online': %w", timeoutError(timeoutCtx, err))
 	}
 	return &details.Storage, nil
 }
@@ -217,7 +467,7 @@ func (d *driver) GetTemplateByName(ctx context.Context, name, zone string) (*upc
 		}
 	}
 
-	return nil, fmt.Errorf("failed to find storage by name %q", name)
+	return nil, fmt.Errorf("failed to find template by name %q in zone %s: %w", name, zone, ErrNotFound)
 }
 
 // fetch storage by uuid or name.
@@ -231,7 +481,7 @@ func (d *driver) GetStorage(ctx context.Context, storageUUID, storageName string
 	}
 
 	if storageName != "" {
-		storage, err := d.getStorageByName(ctx, storageName)
+		storage, err := d.getStorageByName(ctx, upcloud.StorageTypeTemplate, storageName)
 		if err != nil {
 			return nil, fmt.Errorf("error retrieving storage by name %q: %w", storageName, err)
 		}
@@ -240,6 +490,129 @@ func (d *driver) GetStorage(ctx context.Context, storageUUID, storageName string
 	return nil, errors.New("error retrieving storage")
 }
 
+// fetch ISO image (CD-ROM storage) by uuid or name.
+func (d *driver) GetISOStorage(ctx context.Context, storageUUID, storageName string) (*upcloud.Storage, error) {
+	var storage *upcloud.Storage
+	var err error
+	switch {
+	case storageUUID != "":
+		if storage, err = d.getStorageByUUID(ctx, storageUUID); err != nil {
+			return nil, fmt.Errorf("error retrieving ISO storage by uuid %q: %w", storageUUID, err)
+		}
+	case storageName != "":
+		if storage, err = d.getStorageByName(ctx, upcloud.StorageTypeCDROM, storageName); err != nil {
+			return nil, fmt.Errorf("error retrieving ISO storage by name %q: %w", storageName, err)
+		}
+	default:
+		return nil, errors.New("error retrieving ISO storage")
+	}
+
+	if storage.Type != upcloud.StorageTypeCDROM {
+		return nil, fmt.Errorf("storage %q is not a CD-ROM storage", storage.UUID)
+	}
+	return storage, nil
+}
+
+// FindStorage returns the storage matching the filter.
+// Error is returned if no storage matches or if several storages match and MostRecent is not set.
+func (d *driver) FindStorage(ctx context.Context, filter *StorageFilter) (*upcloud.Storage, error) {
+	response, err := d.svc.GetStorages(ctx, &request.GetStoragesRequest{
+		Access: filter.Access,
+		Type:   filter.Type,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching storages: %w", err)
+	}
+
+	matches, err := filter.Match(response.Storages)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no storage matches the filter: %w", ErrNotFound)
+	case len(matches) == 1:
+		return &matches[0], nil
+	case !filter.MostRecent:
+		found := make([]string, 0, len(matches))
+		for _, s := range matches {
+			found = append(found, fmt.Sprintf("%q (%s)", s.Title, s.UUID))
+		}
+		return nil, fmt.Errorf("filter matches %d storages: %s; use more specific filter or enable most recent selection", len(matches), strings.Join(found, ", "))
+	}
+
+	// storage list does not include creation time so it needs to be fetched from storage details
+	var latest *upcloud.Storage
+	for _, s := range matches {
+		storage, err := d.getStorageByUUID(ctx, s.UUID)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil || storage.Created.After(latest.Created) {
+			latest = storage
+		}
+	}
+	return latest, nil
+}
+
+// Match returns storages matching the filter.
+func (f *StorageFilter) Match(storages []upcloud.Storage) ([]upcloud.Storage, error) {
+	var re *regexp.Regexp
+	if f.NameRegex != "" {
+		var err error
+		if re, err = regexp.Compile(f.NameRegex); err != nil {
+			return nil, fmt.Errorf("invalid storage name regex %q: %w", f.NameRegex, err)
+		}
+	}
+
+	matches := make([]upcloud.Storage, 0)
+	for _, s := range storages {
+		switch {
+		case f.Name != "" && !strings.EqualFold(s.Title, f.Name),
+			re != nil && !re.MatchString(s.Title),
+			f.Access != "" && s.Access != f.Access,
+			f.Type != "" && s.Type != f.Type,
+			f.Zone != "" && s.Zone != f.Zone,
+			!hasLabels(s.Labels, f.Labels):
+			continue
+		}
+		matches = append(matches, s)
+	}
+	return matches, nil
+}
+
+func hasLabels(labels []upcloud.Label, want map[string]string) bool {
+	for k, v := range want {
+		found := false
+		for _, l := range labels {
+			if l.Key == k && l.Value == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// SetStorageLabels replaces storage labels with the given labels.
+func (d *driver) SetStorageLabels(ctx context.Context, storageUUID string, labels map[string]string) error {
+	if len(labels) == 0 {
+		return nil
+	}
+	l := labelSlice(labels)
+	if _, err := d.svc.ModifyStorage(ctx, &request.ModifyStorageRequest{
+		UUID:   storageUUID,
+		Labels: &l,
+	}); err != nil {
+		return fmt.Errorf("failed to set labels to storage %s: %w", storageUUID, err)
+	}
+	return nil
+}
+
 func (d *driver) RenameStorage(ctx context.Context, storageUUID, name string) (*upcloud.Storage, error) {
 	details, err := d.svc.ModifyStorage(ctx, &request.ModifyStorageRequest{
 		UUID:  storageUUID,
@@ -252,16 +625,18 @@ func (d *driver) RenameStorage(ctx context.Context, storageUUID, name string) (*
 	return d.WaitStorageOnline(ctx, details.UUID)
 }
 
-func (d *driver) CreateTemplateStorage(ctx context.Context, title, zone string, size int, tier string) (*upcloud.Storage, error) {
+func (d *driver) CreateTemplateStorage(ctx context.Context, title, zone string, size int, tier string, encrypted bool) (*upcloud.Storage, error) {
 	storage, err := d.svc.CreateStorage(ctx, &request.CreateStorageRequest{
-		Size:  size,
-		Tier:  tier,
-		Title: title,
-		Zone:  zone,
+		Size:      size,
+		Encrypted: encryption(encrypted),
+		Tier:      tier,
+		Title:     title,
+		Zone:      zone,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create template storage %s in zone %s: %w", title, zone, err)
 	}
+	d.record(journal.KindStorage, storage.UUID, storage.Zone, storage.Title)
 	return d.WaitStorageOnline(ctx, storage.UUID)
 }
 
@@ -282,7 +657,7 @@ func (d *driver) ImportStorage(ctx context.Context, storageUUID, contentType str
 		StorageUUID: storageUUID,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to wait for storage import completion for %s: %w", storageUUID, err)
+		return nil, fmt.Errorf("failed to wait for storage import completion for %s: %w", storageUUID, timeoutError(timeoutCtx, err))
 	}
 	return result, nil
 }
@@ -298,18 +673,21 @@ func (d *driver) DeleteStorage(ctx context.Context, storageUUID string) error {
 	if err != nil {
 		return fmt.Errorf("failed to delete storage %s: %w", storageUUID, err)
 	}
+	d.forget(storageUUID)
 	return nil
 }
 
-func (d *driver) CloneStorage(ctx context.Context, storageUUID, zone, title string) (*upcloud.Storage, error) {
+func (d *driver) CloneStorage(ctx context.Context, storageUUID, zone, title string, encrypted bool) (*upcloud.Storage, error) {
 	response, err := d.svc.CloneStorage(ctx, &request.CloneStorageRequest{
-		UUID:  storageUUID,
-		Zone:  zone,
-		Title: title,
+		UUID:      storageUUID,
+		Encrypted: encryption(encrypted),
+		Zone:      zone,
+		Title:     title,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to clone storage %s to zone %s with title %s: %w", storageUUID, zone, title, err)
 	}
+	d.record(journal.KindStorage, response.UUID, response.Zone, response.Title)
 	return d.WaitStorageOnline(ctx, response.UUID)
 }
 
@@ -323,9 +701,31 @@ func (d *driver) getStorageByUUID(ctx context.Context, storageUUID string) (*upc
 	return &response.Storage, nil
 }
 
-func (d *driver) getStorageByName(ctx context.Context, storageName string) (*upcloud.Storage, error) {
+func (d *driver) GetStorageDetails(ctx context.Context, storageUUID string) (*upcloud.StorageDetails, error) {
+	response, err := d.svc.GetStorageDetails(ctx, &request.GetStorageDetailsRequest{
+		UUID: storageUUID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get details for storage %s: %w", storageUUID, err)
+	}
+	return response, nil
+}
+
+// ListStorages returns storages filtered by access and type. Empty values match all storages.
+func (d *driver) ListStorages(ctx context.Context, access, storageType string) ([]upcloud.Storage, error) {
 	response, err := d.svc.GetStorages(ctx, &request.GetStoragesRequest{
-		Type: upcloud.StorageTypeTemplate,
+		Access: access,
+		Type:   storageType,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storages: %w", err)
+	}
+	return response.Storages, nil
+}
+
+func (d *driver) getStorageByName(ctx context.Context, storageType, storageName string) (*upcloud.Storage, error) {
+	response, err := d.svc.GetStorages(ctx, &request.GetStoragesRequest{
+		Type: storageType,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("error fetching storages: %w", err)
@@ -343,7 +743,7 @@ func (d *driver) getStorageByName(ctx context.Context, storageName string) (*upc
 	}
 
 	if !found {
-		return nil, fmt.Errorf("failed to find storage by name %q", storageName)
+		return nil, fmt.Errorf("failed to find storage by name %q: %w", storageName, ErrNotFound)
 	}
 	return &storage, nil
 }
@@ -357,7 +757,7 @@ func (d *driver) waitDesiredState(ctx context.Context, serverUUID, state string)
 		DesiredState: state,
 	}
 	if _, err := d.svc.WaitForServerState(timeoutCtx, request); err != nil {
-		return fmt.Errorf("error while waiting for server to change state to %q: %w", state, err)
+		return fmt.Errorf("error while waiting for server to change state to %q: %w", state, timeoutError(timeoutCtx, err))
 	}
 	return nil
 }
@@ -371,12 +771,12 @@ func (d *driver) waitUndesiredState(ctx context.Context, serverUUID, state strin
 		UndesiredState: state,
 	}
 	if _, err := d.svc.WaitForServerState(timeoutCtx, request); err != nil {
-		return fmt.Errorf("error while waiting for server to change state from %q: %w", state, err)
+		return fmt.Errorf("error while waiting for server to change state from %q: %w", state, timeoutError(timeoutCtx, err))
 	}
 	return nil
 }
 
-func (d *driver) getServerDetails(ctx context.Context, serverUUID string) (*upcloud.ServerDetails, error) {
+func (d *driver) GetServerDetails(ctx context.Context, serverUUID string) (*upcloud.ServerDetails, error) {
 	response, err := d.svc.GetServerDetails(ctx, &request.GetServerDetailsRequest{
 		UUID: serverUUID,
 	})
@@ -386,48 +786,63 @@ func (d *driver) getServerDetails(ctx context.Context, serverUUID string) (*upcl
 	return response, nil
 }
 
-func (d *driver) GetServerStorage(ctx context.Context, serverUUID string) (*upcloud.ServerStorageDevice, error) {
-	details, err := d.getServerDetails(ctx, serverUUID)
+// ListServers returns servers matching the filters, or all servers if no filters are given.
+func (d *driver) ListServers(ctx context.Context, filters ...request.QueryFilter) ([]upcloud.Server, error) {
+	response, err := d.svc.GetServersWithFilters(ctx, &request.GetServersWithFiltersRequest{
+		Filters: filters,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list servers: %w", err)
+	}
+	return response.Servers, nil
+}
+
+// GetServerStorages returns the disks of the server. The system disk is the first disk.
+func (d *driver) GetServerStorages(ctx context.Context, serverUUID string) ([]upcloud.ServerStorageDevice, error) {
+	details, err := d.GetServerDetails(ctx, serverUUID)
 	if err != nil {
 		return nil, err
 	}
 
-	var found bool
-	var storage upcloud.ServerStorageDevice
+	storages := make([]upcloud.ServerStorageDevice, 0, len(details.StorageDevices))
 	for _, s := range details.StorageDevices {
 		if s.Type == upcloud.StorageTypeDisk {
-			found = true
-			storage = s
-			break
+			storages = append(storages, s)
 		}
 	}
-	if !found {
+	if len(storages) == 0 {
 		return nil, fmt.Errorf("failed to find storage type disk for server %q", serverUUID)
 	}
-	return &storage, nil
+	return storages, nil
 }
 
 func (d *driver) prepareCreateRequest(opts *ServerOpts) *request.CreateServerRequest {
 	title := fmt.Sprintf("packer-%s-%s", DefaultHostname, getNowString())
-	titleDisk := fmt.Sprintf("%s-disk1", DefaultHostname)
+	titleDisk := SystemDiskTitle
 	plan := opts.ServerPlan
+	if opts.CoreNumber > 0 {
+		plan = CustomPlan
+	}
 	if plan == "" {
 		plan = DefaultPlan
 	}
 
-	request := request.CreateServerRequest{
+	r := request.CreateServerRequest{
 		Title:            title,
 		Hostname:         DefaultHostname,
 		Zone:             opts.Zone,
 		PasswordDelivery: request.PasswordDeliveryNone,
 		Plan:             plan,
+		CoreNumber:       opts.CoreNumber,
+		MemoryAmount:     opts.MemoryAmount,
 		StorageDevices: []request.CreateServerStorageDevice{
 			{
-				Action:  request.CreateServerStorageDeviceActionClone,
-				Storage: opts.StorageUUID,
-				Title:   titleDisk,
-				Size:    opts.StorageSize,
-				Tier:    opts.StorageTier,
+				Action:    request.CreateServerStorageDeviceActionClone,
+				Storage:   opts.StorageUUID,
+				Title:     titleDisk,
+				Size:      opts.StorageSize,
+				Tier:      opts.StorageTier,
+				Encrypted: encryption(opts.StorageEncryption),
 			},
 		},
 		Networking: &request.CreateServerNetworking{
@@ -439,7 +854,197 @@ func (d *driver) prepareCreateRequest(opts *ServerOpts) *request.CreateServerReq
 			SSHKeys:        []string{opts.SSHPublicKey},
 		},
 	}
-	return &request
+
+	if opts.CreatePassword {
+		// Password is returned in the response when password delivery is set to 'none'
+		r.LoginUser.CreatePassword = "yes"
+		r.LoginUser.SSHKeys = nil
+	}
+
+	if opts.ISOStorageUUID != "" {
+		// Empty disk is tried first so that the server boots from the installed system after installation
+		r.BootOrder = "disk,cdrom"
+		r.StorageDevices = []request.CreateServerStorageDevice{
+			{
+				Action:    request.CreateServerStorageDeviceActionCreate,
+				Title:     titleDisk,
+				Size:      opts.StorageSize,
+				Tier:      opts.StorageTier,
+				Encrypted: encryption(opts.StorageEncryption),
+			},
+			{
+				Action:  request.CreateServerStorageDeviceActionAttach,
+				Storage: opts.ISOStorageUUID,
+				Type:    upcloud.StorageTypeCDROM,
+			},
+		}
+		// Login user can only be configured for servers created from templates
+		r.LoginUser = nil
+	}
+
+	if len(opts.StorageDevices) > 0 {
+		// Extra disks are attached after the system disk and before the ISO image
+		devices := []request.CreateServerStorageDevice{r.StorageDevices[0]}
+		for _, device := range opts.StorageDevices {
+			devices = append(devices, request.CreateServerStorageDevice{
+				Action:    device.Action,
+				Storage:   device.StorageUUID,
+				Title:     device.Title,
+				Size:      device.Size,
+				Tier:      device.Tier,
+				Encrypted: encryption(opts.StorageEncryption),
+			})
+		}
+		r.StorageDevices = append(devices, r.StorageDevices[1:]...)
+	}
+
+	if len(opts.Labels) > 0 {
+		labels := upcloud.LabelSlice(labelSlice(opts.Labels))
+		r.Labels = &labels
+	}
+
+	if opts.UserData != "" {
+		r.UserData = opts.UserData
+		r.Metadata = upcloud.True
+	}
+
+	if opts.Metadata != nil {
+		r.Metadata = upcloud.FromBool(*opts.Metadata)
+	}
+
+	if opts.RemoteAccessPassword != "" {
+		r.RemoteAccessEnabled = upcloud.True
+		r.RemoteAccessType = upcloud.RemoteAccessTypeVNC
+		r.RemoteAccessPassword = opts.RemoteAccessPassword
+	}
+	return &r
+}
+
+func (r *responseRecorder) Post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	res, err := r.Client.Post(ctx, path, body)
+	if err == nil {
+		r.body = res
+	}
+	return res, err //nolint:wrapcheck // service parses client errors
+}
+
+func (d *driver) CreateNetwork(ctx context.Context, opts *NetworkOpts) (*upcloud.Network, error) {
+	family := upcloud.IPAddressFamilyIPv4
+	if ip, _, err := net.ParseCIDR(opts.Address); err == nil && ip.To4() == nil {
+		family = upcloud.IPAddressFamilyIPv6
+	}
+	response, err := d.svc.CreateNetwork(ctx, &request.CreateNetworkRequest{
+		Name:   fmt.Sprintf("packer-network-%s", getNowString()),
+		Zone:   opts.Zone,
+		Router: opts.Router,
+		IPNetworks: upcloud.IPNetworkSlice{
+			{
+				Address: opts.Address,
+				DHCP:    upcloud.FromBool(opts.DHCP),
+				Family:  family,
+			},
+		},
+		Labels: labelSlice(opts.Labels),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create network %s in zone %s: %w", opts.Address, opts.Zone, err)
+	}
+	d.record(journal.KindNetwork, response.UUID, response.Zone, response.Name)
+	return response, nil
+}
+
+func (d *driver) DeleteNetwork(ctx context.Context, networkUUID string) error {
+	err := d.svc.DeleteNetwork(ctx, &request.DeleteNetworkRequest{
+		UUID: networkUUID,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete network %s: %w", networkUUID, err)
+	}
+	d.forget(networkUUID)
+	return nil
+}
+
+func (d *driver) GetNetwork(ctx context.Context, networkUUID string) (*upcloud.Network, error) {
+	network, err := d.svc.GetNetworkDetails(ctx, &request.GetNetworkDetailsRequest{
+		UUID: networkUUID,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get network %s: %w", networkUUID, err)
+	}
+	return network, nil
+}
+
+// CreateFirewallRules replaces the firewall rule set of the server. Rules are deleted together with the server.
+func (d *driver) CreateFirewallRules(ctx context.Context, serverUUID string, rules []upcloud.FirewallRule) error {
+	err := d.svc.CreateFirewallRules(ctx, &request.CreateFirewallRulesRequest{
+		ServerUUID:    serverUUID,
+		FirewallRules: rules,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create firewall rules for server %s: %w", serverUUID, err)
+	}
+	return nil
+}
+
+func (d *driver) EnableFirewall(ctx context.Context, serverUUID string) error {
+	_, err := d.svc.ModifyServer(ctx, &request.ModifyServerRequest{
+		UUID:     serverUUID,
+		Firewall: "on",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to enable firewall for server %s: %w", serverUUID, err)
+	}
+	return nil
+}
+
+// CreateFloatingIPAddress reserves a new floating IPv4 address and attaches it to the network interface with given MAC address.
+func (d *driver) CreateFloatingIPAddress(ctx context.Context, zone, mac string) (*upcloud.IPAddress, error) {
+	response, err := d.svc.AssignIPAddress(ctx, &request.AssignIPAddressRequest{
+		Access:   upcloud.IPAddressAccessPublic,
+		Family:   upcloud.IPAddressFamilyIPv4,
+		Floating: upcloud.True,
+		MAC:      mac,
+		Zone:     zone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create floating IP address in zone %s: %w", zone, err)
+	}
+	d.record(journal.KindIPAddress, response.Address, response.Zone, "")
+	return response, nil
+}
+
+// AttachFloatingIPAddress moves an existing floating IP address to the network interface with given MAC address.
+func (d *driver) AttachFloatingIPAddress(ctx context.Context, address, mac string) (*upcloud.IPAddress, error) {
+	response, err := d.svc.ModifyIPAddress(ctx, &request.ModifyIPAddressRequest{
+		IPAddress: address,
+		MAC:       mac,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to attach floating IP address %s: %w", address, err)
+	}
+	return response, nil
+}
+
+func (d *driver) DetachFloatingIPAddress(ctx context.Context, address string) error {
+	// modify request without MAC address detaches the floating IP address
+	_, err := d.svc.ModifyIPAddress(ctx, &request.ModifyIPAddressRequest{
+		IPAddress: address,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to detach floating IP address %s: %w", address, err)
+	}
+	return nil
+}
+
+func (d *driver) ReleaseIPAddress(ctx context.Context, address string) error {
+	err := d.svc.ReleaseIPAddress(ctx, &request.ReleaseIPAddressRequest{
+		IPAddress: address,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to release IP address %s: %w", address, err)
+	}
+	d.forget(address)
+	return nil
 }
 
 func (d *driver) GetAvailableZones(ctx context.Context) []string {
@@ -452,10 +1057,116 @@ func (d *driver) GetAvailableZones(ctx context.Context) []string {
 	return zones
 }
 
+// GetPlanPrices returns server plans available in the zone with their hourly prices.
+// Plans which are not priced in the zone are not available in the zone.
+func (d *driver) GetPlanPrices(ctx context.Context, zone string) ([]PlanPrice, error) {
+	plans, err := d.svc.GetPlans(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get plans: %w", err)
+	}
+
+	prices, err := d.GetZonePrices(ctx, zone)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]PlanPrice, 0, len(plans.Plans))
+	for _, plan := range plans.Plans {
+		price, ok := prices["server_plan_"+plan.Name]
+		if !ok {
+			continue
+		}
+		// prices are in cents
+		result = append(result, PlanPrice{Plan: plan, HourlyPrice: price.Price / 100})
+	}
+	return result, nil
+}
+
+// GetZonePrices returns prices of the zone by item name, e.g. `storage_maxiops`. Price is given in cents per hour for
+// the amount of units, e.g. gigabytes of storage. Response is parsed here, because upcloud.PriceZone has fields only
+// for a few server plans.
+func (d *driver) GetZonePrices(ctx context.Context, zone string) (map[string]upcloud.Price, error) {
+	body, err := d.client.Get(ctx, "/price")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get prices: %w", err)
+	}
+
+	response := struct {
+		Prices struct {
+			Zone []map[string]json.RawMessage `json:"zone"`
+		} `json:"prices"`
+	}{}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse prices: %w", err)
+	}
+
+	for _, items := range response.Prices.Zone {
+		var name string
+		if err := json.Unmarshal(items["name"], &name); err != nil || name != zone {
+			continue
+		}
+
+		prices := make(map[string]upcloud.Price, len(items))
+		for item, raw := range items {
+			var price upcloud.Price
+			if err := json.Unmarshal(raw, &price); err == nil {
+				prices[item] = price
+			}
+		}
+		return prices, nil
+	}
+	return nil, fmt.Errorf("failed to find prices for zone %s", zone)
+}
+
+// GetAccount returns account details. Credits are in cents.
+func (d *driver) GetAccount(ctx context.Context) (*upcloud.Account, error) {
+	account, err := d.svc.GetAccount(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account: %w", err)
+	}
+	return account, nil
+}
+
+// record adds created resource to the build journal. Failing to record does not fail the build.
+func (d *driver) record(kind, uuid, zone, title string) {
+	if err := d.config.Journal.Add(journal.Entry{Kind: kind, UUID: uuid, Zone: zone, Title: title}); err != nil {
+		log.Printf("[WARN] failed to record %s %s to journal: %s", kind, uuid, err)
+	}
+}
+
+// forget removes deleted resource from the build journal.
+func (d *driver) forget(uuid string) {
+	if err := d.config.Journal.Remove(uuid); err != nil {
+		log.Printf("[WARN] failed to remove %s from journal: %s", uuid, err)
+	}
+}
+
+// StorageTierSupportsEncryption returns false for tiers which do not support encryption at rest.
+// Empty tier is the default tier, which supports encryption.
+func StorageTierSupportsEncryption(tier string) bool {
+	return tier != upcloud.StorageTierHDD && tier != storageTierArchive
+}
+
+// encryption returns value for the encrypted field of storage requests. Unencrypted storages are requested by omitting the field.
+func encryption(encrypted bool) upcloud.Boolean {
+	if encrypted {
+		return upcloud.True
+	}
+	return upcloud.Empty
+}
+
 func getNowString() string {
 	return time.Now().Format("20060102-150405")
 }
 
+// APIURLFromEnv returns the API URL, or the URL set in EnvConfigAPIURL if the API URL is empty.
+func APIURLFromEnv(apiURL string) string {
+	if apiURL == "" {
+		return os.Getenv(EnvConfigAPIURL)
+	}
+	return apiURL
+}
+
 func CredentialsFromEnv(username, password, token string) (credentials.Credentials, error) {
 	config := credentials.Credentials{
 		Username: username,
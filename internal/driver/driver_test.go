@@ -0,0 +1,100 @@
+//go:build !integration
+
+package driver_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+func TestStorageFilter_Match(t *testing.T) {
+	t.Parallel()
+	storages := []upcloud.Storage{
+		{UUID: "1", Title: "Ubuntu Server 24.04 LTS (Noble Numbat)", Access: upcloud.StorageAccessPublic, Type: upcloud.StorageTypeTemplate, Zone: "fi-hel1"},
+		{UUID: "2", Title: "ubuntu server 24.04 hardened", Access: upcloud.StorageAccessPrivate, Type: upcloud.StorageTypeTemplate, Zone: "fi-hel1", Labels: []upcloud.Label{
+			{Key: "os", Value: "ubuntu"},
+			{Key: "hardened", Value: "true"},
+		}},
+		{UUID: "3", Title: "ubuntu server 24.04 hardened", Access: upcloud.StorageAccessPrivate, Type: upcloud.StorageTypeTemplate, Zone: "de-fra1", Labels: []upcloud.Label{
+			{Key: "os", Value: "ubuntu"},
+		}},
+	}
+
+	tests := []struct {
+		name   string
+		filter driver.StorageFilter
+		want   []string
+	}{
+		{"exact name", driver.StorageFilter{Name: "UBUNTU SERVER 24.04 HARDENED"}, []string{"2", "3"}},
+		{"exact name does not match substring", driver.StorageFilter{Name: "ubuntu server 24.04"}, []string{}},
+		{"regex", driver.StorageFilter{NameRegex: "^Ubuntu Server 24\\.04"}, []string{"1"}},
+		{"access", driver.StorageFilter{NameRegex: "(?i)ubuntu", Access: upcloud.StorageAccessPrivate}, []string{"2", "3"}},
+		{"zone", driver.StorageFilter{NameRegex: "(?i)ubuntu", Zone: "de-fra1"}, []string{"3"}},
+		{"labels", driver.StorageFilter{Labels: map[string]string{"os": "ubuntu", "hardened": "true"}}, []string{"2"}},
+		{"type", driver.StorageFilter{Type: upcloud.StorageTypeNormal}, []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			matches, err := test.filter.Match(storages)
+			require.NoError(t, err)
+			got := make([]string, 0, len(matches))
+			for _, s := range matches {
+				got = append(got, s.UUID)
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestStorageFilter_Match_InvalidRegex(t *testing.T) {
+	t.Parallel()
+	_, err := (&driver.StorageFilter{NameRegex: "("}).Match(nil)
+	assert.Error(t, err)
+}
+
+func TestValidateLabels(t *testing.T) {
+	t.Parallel()
+	errs := driver.ValidateLabels(map[string]string{
+		"team":              "platform",
+		"os_family":         "linux",
+		"x":                 "too short key",
+		"_private":          "starts with underscore",
+		"packer-build-name": "reserved",
+		"long":              strings.Repeat("a", 256),
+	})
+	require.Len(t, errs, 4)
+	assert.ErrorContains(t, errs[0], `invalid label key "_private"`)
+	assert.ErrorContains(t, errs[1], `value of label "long" exceeds 255 characters`)
+	assert.ErrorContains(t, errs[2], `label key "packer-build-name" is reserved`)
+	assert.ErrorContains(t, errs[3], `invalid label key "x"`)
+}
+
+func TestAutomaticLabels(t *testing.T) {
+	t.Setenv("PACKER_RUN_UUID", "a1b2c3")
+	labels := driver.MergeLabels(map[string]string{"team": "platform"}, driver.AutomaticLabels("ubuntu", "01000000-0000-4000-8000-000030240200"))
+	assert.Equal(t, "platform", labels["team"])
+	assert.Equal(t, "ubuntu", labels[driver.LabelBuildName])
+	assert.Equal(t, "a1b2c3", labels[driver.LabelBuildUUID])
+	assert.Equal(t, "01000000-0000-4000-8000-000030240200", labels[driver.LabelSourceUUID])
+	assert.NotEmpty(t, labels[driver.LabelPluginVersion])
+
+	labels = driver.AutomaticLabels("", "")
+	assert.NotContains(t, labels, driver.LabelBuildName)
+	assert.NotContains(t, labels, driver.LabelSourceUUID)
+}
+
+func TestStorageTierSupportsEncryption(t *testing.T) {
+	t.Parallel()
+	assert.True(t, driver.StorageTierSupportsEncryption(""))
+	assert.True(t, driver.StorageTierSupportsEncryption(upcloud.StorageTierMaxIOPS))
+	assert.True(t, driver.StorageTierSupportsEncryption(upcloud.StorageTierStandard))
+	assert.False(t, driver.StorageTierSupportsEncryption(upcloud.StorageTierHDD))
+	assert.False(t, driver.StorageTierSupportsEncryption("archive"))
+}
@@ -0,0 +1,172 @@
+package driver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/service"
+)
+
+// Errors returned by the driver can be checked with errors.Is against these sentinel errors. The underlying
+// *upcloud.Problem is still available with errors.As.
+var (
+	// ErrNotFound is returned when the resource does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when the resource already exists or its state does not allow the operation.
+	ErrConflict = errors.New("conflict")
+	// ErrCapacity is returned when the zone lacks capacity for the requested resources, so that the request might
+	// succeed in another zone or using another plan.
+	ErrCapacity = errors.New("insufficient capacity")
+	// ErrTimeout is returned when the operation did not complete in time.
+	ErrTimeout = errors.New("timeout")
+	// ErrAuth is returned when the credentials are invalid or do not allow the operation.
+	ErrAuth = errors.New("authentication failed")
+)
+
+type (
+	// apiError is a problem returned by the API classified by its status and error code.
+	apiError struct {
+		kind error
+		err  error
+	}
+
+	// errorClient parses API error responses into classified problems. Service passes the errors through as they
+	// are not client errors.
+	errorClient struct {
+		service.Client
+	}
+)
+
+func (e *apiError) Error() string {
+	return e.err.Error()
+}
+
+func (e *apiError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+func (c *errorClient) Get(ctx context.Context, path string) ([]byte, error) {
+	res, err := c.Client.Get(ctx, path)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) GetStream(ctx context.Context, path string) (io.ReadCloser, error) {
+	res, err := c.Client.GetStream(ctx, path)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) Post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	res, err := c.Client.Post(ctx, path, body)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) Put(ctx context.Context, path string, body []byte) ([]byte, error) {
+	res, err := c.Client.Put(ctx, path, body)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) Patch(ctx context.Context, path string, body []byte) ([]byte, error) {
+	res, err := c.Client.Patch(ctx, path, body)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) Delete(ctx context.Context, path string) ([]byte, error) {
+	res, err := c.Client.Delete(ctx, path)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) Do(r *http.Request) ([]byte, error) {
+	res, err := c.Client.Do(r)
+	return res, classifyError(err)
+}
+
+func (c *errorClient) DoStream(r *http.Request) (io.ReadCloser, error) {
+	res, err := c.Client.DoStream(r)
+	return res, classifyError(err)
+}
+
+// classifyError wraps API errors and timeouts with the matching sentinel error. Client errors are parsed into
+// *upcloud.Problem like the service would. Other errors are returned as is.
+func classifyError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var classified *apiError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	var clientErr *client.Error
+	if errors.As(err, &clientErr) {
+		problem := parseProblem(clientErr)
+		if kind := problemKind(problem); kind != nil {
+			return &apiError{kind: kind, err: problem}
+		}
+		return problem
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return &apiError{kind: ErrTimeout, err: err}
+	}
+	return err
+}
+
+// timeoutError wraps error of waiting for a state with ErrTimeout if the deadline of the context was exceeded.
+func timeoutError(ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return &apiError{kind: ErrTimeout, err: err}
+	}
+	return err
+}
+
+func problemKind(problem *upcloud.Problem) error {
+	switch {
+	case slices.Contains(capacityErrorCodes, problem.ErrorCode()):
+		return ErrCapacity
+	case problem.Status == http.StatusNotFound:
+		return ErrNotFound
+	case problem.Status == http.StatusConflict:
+		return ErrConflict
+	case problem.Status == http.StatusUnauthorized || problem.Status == http.StatusForbidden:
+		return ErrAuth
+	}
+	return nil
+}
+
+// parseProblem parses both problem and legacy error responses.
+func parseProblem(err *client.Error) *upcloud.Problem {
+	problem := &upcloud.Problem{}
+	if err.Type == client.ErrorTypeProblem {
+		if json.Unmarshal(err.ResponseBody, problem) != nil {
+			problem.Title = string(err.ResponseBody)
+		}
+	} else {
+		legacy := struct {
+			Error struct {
+				ErrorCode    string `json:"error_code"`
+				ErrorMessage string `json:"error_message"`
+			} `json:"error"`
+		}{}
+		if json.Unmarshal(err.ResponseBody, &legacy) != nil {
+			problem.Title = string(err.ResponseBody)
+		} else {
+			problem.Type = legacy.Error.ErrorCode
+			problem.Title = legacy.Error.ErrorMessage
+		}
+	}
+	if problem.Status == 0 {
+		problem.Status = err.ErrorCode
+	}
+	if problem.Title == "" {
+		problem.Title = err.ErrorMessage
+	}
+	return problem
+}
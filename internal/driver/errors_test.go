@@ -0,0 +1,62 @@
+//go:build !integration
+
+package driver //nolint:testpackage // error classification is not exported
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
+)
+
+func TestClassifyError(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"not found", problemError(http.StatusNotFound, "STORAGE_NOT_FOUND"), ErrNotFound},
+		{"conflict", problemError(http.StatusConflict, "STORAGE_STATE_ILLEGAL"), ErrConflict},
+		{"capacity", problemError(http.StatusConflict, "SERVER_RESOURCES_UNAVAILABLE"), ErrCapacity},
+		{"unauthorized", problemError(http.StatusUnauthorized, "AUTHENTICATION_FAILED"), ErrAuth},
+		{"forbidden", problemError(http.StatusForbidden, "INSUFFICIENT_PERMISSIONS"), ErrAuth},
+		{"deadline", context.DeadlineExceeded, ErrTimeout},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			err := fmt.Errorf("failed: %w", classifyError(test.err))
+			require.ErrorIs(t, err, test.want)
+		})
+	}
+
+	err := classifyError(problemError(http.StatusBadRequest, "PLAN_INVALID"))
+	for _, sentinel := range []error{ErrNotFound, ErrConflict, ErrCapacity, ErrTimeout, ErrAuth} {
+		assert.NotErrorIs(t, err, sentinel)
+	}
+	assert.Nil(t, classifyError(nil))
+}
+
+func TestClassifyError_problem(t *testing.T) {
+	t.Parallel()
+	err := classifyError(&client.Error{
+		ErrorCode:    http.StatusNotFound,
+		ResponseBody: []byte(`{"error":{"error_code":"SERVER_NOT_FOUND","error_message":"The server does not exist."}}`),
+		Type:         client.ErrorTypeError,
+	})
+	require.ErrorIs(t, err, ErrNotFound)
+
+	var problem *upcloud.Problem
+	require.True(t, errors.As(err, &problem))
+	assert.Equal(t, upcloud.ErrCodeServerNotFound, problem.ErrorCode())
+	assert.Equal(t, http.StatusNotFound, problem.Status)
+	assert.Equal(t, "The server does not exist. (type=SERVER_NOT_FOUND, status=404)", err.Error())
+}
@@ -0,0 +1,1198 @@
+// Package fake implements driver.Driver using an in-memory model of UpCloud zones, servers, storages, templates,
+// networks and IP addresses. It is used for testing builder and post-processor steps without credentials.
+package fake
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+const (
+	// PublicTemplateUUID is the UUID of the public template, which can be used in all zones.
+	PublicTemplateUUID string = "01000000-0000-4000-8000-000030240200"
+	// PublicTemplateTitle is the title of the public template.
+	PublicTemplateTitle string = "Ubuntu Server 24.04 LTS (Noble Numbat)"
+	// ISOStorageUUID is the UUID of the public ISO image, which can be used in all zones.
+	ISOStorageUUID string = "01000000-0000-4000-8000-000020080100"
+	// ISOStorageTitle is the title of the public ISO image.
+	ISOStorageTitle string = "Debian GNU/Linux 12 (Bookworm) Installation CD"
+
+	prefixServer    string = "00"
+	prefixStorage   string = "01"
+	prefixNetwork   string = "03"
+	defaultUsername string = "root"
+)
+
+type (
+	// Driver is an in-memory driver.Driver. It is safe for concurrent use. Use New to create one.
+	Driver struct {
+		// Zones are the available zones.
+		Zones []string
+		// Plans are the server plans available in all zones.
+		Plans []upcloud.Plan
+		// Prices are the hourly prices in cents by zone and price item.
+		Prices map[string]map[string]upcloud.Price
+		// Account is returned by GetAccount.
+		Account upcloud.Account
+		// Delay is the time that waiting for a server or storage state takes, e.g. for simulating slow operations.
+		Delay time.Duration
+		// Timeout limits waiting for a server or storage state like driver.DriverConfig.Timeout. Zero means no limit.
+		Timeout time.Duration
+
+		mu          sync.Mutex
+		seq         int
+		servers     map[string]*upcloud.ServerDetails
+		storages    map[string]*upcloud.Storage
+		networks    map[string]*upcloud.Network
+		ipAddresses map[string]*upcloud.IPAddress
+		firewall    map[string][]upcloud.FirewallRule
+		calls       map[string]int
+		faults      map[string][]fault
+	}
+
+	fault struct {
+		call int
+		err  error
+	}
+)
+
+var _ driver.Driver = (*Driver)(nil)
+
+// New returns a driver with zones fi-hel1, fi-hel2 and de-fra1, a few plans with prices, a public template and an ISO
+// image.
+func New() *Driver {
+	d := &Driver{
+		Zones: []string{"fi-hel1", "fi-hel2", "de-fra1"},
+		Plans: []upcloud.Plan{
+			{Name: "1xCPU-1GB", CoreNumber: 1, MemoryAmount: 1024, StorageSize: 25, StorageTier: upcloud.StorageTierMaxIOPS},
+			{Name: "1xCPU-2GB", CoreNumber: 1, MemoryAmount: 2048, StorageSize: 50, StorageTier: upcloud.StorageTierMaxIOPS},
+			{Name: "2xCPU-4GB", CoreNumber: 2, MemoryAmount: 4096, StorageSize: 80, StorageTier: upcloud.StorageTierMaxIOPS},
+		},
+		Prices:      make(map[string]map[string]upcloud.Price),
+		Account:     upcloud.Account{Credits: 10000, UserName: "fake"},
+		servers:     make(map[string]*upcloud.ServerDetails),
+		storages:    make(map[string]*upcloud.Storage),
+		networks:    make(map[string]*upcloud.Network),
+		ipAddresses: make(map[string]*upcloud.IPAddress),
+		firewall:    make(map[string][]upcloud.FirewallRule),
+		calls:       make(map[string]int),
+		faults:      make(map[string][]fault),
+	}
+	for _, zone := range d.Zones {
+		d.Prices[zone] = map[string]upcloud.Price{
+			"server_plan_1xCPU-1GB": {Amount: 1, Price: 0.744},
+			"server_plan_1xCPU-2GB": {Amount: 1, Price: 1.042},
+			"server_plan_2xCPU-4GB": {Amount: 1, Price: 2.083},
+			"server_core":           {Amount: 1, Price: 0.6},
+			"server_memory":         {Amount: 256, Price: 0.1},
+			"storage_maxiops":       {Amount: 1, Price: 0.031},
+			"storage_standard":      {Amount: 1, Price: 0.006},
+			"storage_hdd":           {Amount: 1, Price: 0.006},
+			"storage_template":      {Amount: 1, Price: 0.031},
+		}
+	}
+	d.storages[PublicTemplateUUID] = &upcloud.Storage{
+		UUID:         PublicTemplateUUID,
+		Title:        PublicTemplateTitle,
+		Access:       upcloud.StorageAccessPublic,
+		Type:         upcloud.StorageTypeTemplate,
+		TemplateType: upcloud.StorageTemplateTypeCloudInit,
+		State:        upcloud.StorageStateOnline,
+		Size:         4,
+		Zone:         d.Zones[0],
+	}
+	d.storages[ISOStorageUUID] = &upcloud.Storage{
+		UUID:   ISOStorageUUID,
+		Title:  ISOStorageTitle,
+		Access: upcloud.StorageAccessPublic,
+		Type:   upcloud.StorageTypeCDROM,
+		State:  upcloud.StorageStateOnline,
+		Size:   1,
+		Zone:   d.Zones[0],
+	}
+	return d
+}
+
+// Fail makes the nth call of the method, counting from 1, return the error. When n is zero, all calls fail.
+func (d *Driver) Fail(method string, n int, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.faults[method] = append(d.faults[method], fault{call: n, err: err})
+}
+
+// Calls returns the number of calls of the method.
+func (d *Driver) Calls(method string) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.calls[method]
+}
+
+// AddStorage adds the storage to the model, e.g. a private template or a storage to clone. UUID is generated if empty.
+func (d *Driver) AddStorage(storage upcloud.Storage) *upcloud.Storage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if storage.UUID == "" {
+		storage.UUID = d.uuid(prefixStorage)
+	}
+	if storage.State == "" {
+		storage.State = upcloud.StorageStateOnline
+	}
+	if storage.Created.IsZero() {
+		storage.Created = time.Now()
+	}
+	d.storages[storage.UUID] = &storage
+	return copyStorage(&storage)
+}
+
+// Servers returns the servers sorted by UUID.
+func (d *Driver) Servers() []upcloud.ServerDetails {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	servers := make([]upcloud.ServerDetails, 0, len(d.servers))
+	for _, uuid := range slices.Sorted(maps.Keys(d.servers)) {
+		servers = append(servers, *d.servers[uuid])
+	}
+	return servers
+}
+
+// Storages returns the private storages of the given type, or all types if empty, sorted by UUID.
+func (d *Driver) Storages(storageType string) []upcloud.Storage {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.listStorages(upcloud.StorageAccessPrivate, storageType)
+}
+
+// Networks returns the private networks sorted by UUID.
+func (d *Driver) Networks() []upcloud.Network {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	networks := make([]upcloud.Network, 0, len(d.networks))
+	for _, uuid := range slices.Sorted(maps.Keys(d.networks)) {
+		networks = append(networks, *d.networks[uuid])
+	}
+	return networks
+}
+
+// IPAddresses returns the floating IP addresses sorted by address.
+func (d *Driver) IPAddresses() []upcloud.IPAddress {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	addrs := make([]upcloud.IPAddress, 0, len(d.ipAddresses))
+	for _, addr := range slices.Sorted(maps.Keys(d.ipAddresses)) {
+		addrs = append(addrs, *d.ipAddresses[addr])
+	}
+	return addrs
+}
+
+// FirewallRules returns the firewall rules of the server.
+func (d *Driver) FirewallRules(serverUUID string) []upcloud.FirewallRule {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return slices.Clone(d.firewall[serverUUID])
+}
+
+// call counts the call of the method and returns the injected fault, if any. Caller must hold the lock.
+func (d *Driver) call(method string) error {
+	d.calls[method]++
+	for _, f := range d.faults[method] {
+		if f.call == 0 || f.call == d.calls[method] {
+			return fmt.Errorf("%s failed: %w", method, f.err)
+		}
+	}
+	return nil
+}
+
+// uuid returns a new UUID with the prefix used by the API for the resource type. Caller must hold the lock.
+func (d *Driver) uuid(prefix string) string {
+	d.seq++
+	return fmt.Sprintf("%s%06x-0000-4000-8000-%012x", prefix, d.seq, d.seq)
+}
+
+// wait simulates waiting for a state change. It must be called without holding the lock.
+func (d *Driver) wait(ctx context.Context, timeout time.Duration) error {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	timer := time.NewTimer(d.Delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("error while waiting for state change: %w", driver.ErrTimeout)
+		}
+		return ctx.Err() //nolint:wrapcheck // context errors are returned as is
+	}
+}
+
+func notFound(kind, uuid string) error {
+	return fmt.Errorf("%s %s does not exist: %w", kind, uuid, driver.ErrNotFound)
+}
+
+func copyStorage(s *upcloud.Storage) *upcloud.Storage {
+	c := *s
+	c.Labels = slices.Clone(s.Labels)
+	return &c
+}
+
+func labelSlice(labels map[string]string) []upcloud.Label {
+	slice := make([]upcloud.Label, 0, len(labels))
+	for _, k := range slices.Sorted(maps.Keys(labels)) {
+		slice = append(slice, upcloud.Label{Key: k, Value: labels[k]})
+	}
+	return slice
+}
+
+func (d *Driver) CreateServer(ctx context.Context, opts *driver.ServerOpts) (*driver.ServerDetails, error) {
+	details, err := d.createServer(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.wait(ctx, d.Timeout); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+func (d *Driver) createServer(opts *driver.ServerOpts) (*driver.ServerDetails, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreateServer"); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(d.Zones, opts.Zone) {
+		return nil, notFound("zone", opts.Zone)
+	}
+
+	plan := opts.ServerPlan
+	cores, memory := opts.CoreNumber, opts.MemoryAmount
+	if cores > 0 {
+		plan = driver.CustomPlan
+	} else {
+		if plan == "" {
+			plan = driver.DefaultPlan
+		}
+		i := slices.IndexFunc(d.Plans, func(p upcloud.Plan) bool { return p.Name == plan })
+		if i < 0 {
+			return nil, notFound("plan", plan)
+		}
+		cores, memory = d.Plans[i].CoreNumber, d.Plans[i].MemoryAmount
+	}
+
+	server := &upcloud.ServerDetails{
+		Server: upcloud.Server{
+			UUID:         d.uuid(prefixServer),
+			Title:        fmt.Sprintf("packer-%s-%d", driver.DefaultHostname, d.seq),
+			Hostname:     driver.DefaultHostname,
+			Zone:         opts.Zone,
+			Plan:         plan,
+			CoreNumber:   cores,
+			MemoryAmount: memory,
+			State:        upcloud.ServerStateStarted,
+		},
+		Labels: labelSlice(opts.Labels),
+	}
+
+	// system disk is cloned from the template or created empty for the ISO installation
+	source := opts.StorageUUID
+	action := request.CreateServerStorageDeviceActionClone
+	if opts.ISOStorageUUID != "" {
+		source = ""
+		action = request.CreateServerStorageDeviceActionCreate
+	}
+	devices := []driver.StorageDeviceOpts{{
+		Action:      action,
+		StorageUUID: source,
+		Title:       driver.SystemDiskTitle,
+		Size:        opts.StorageSize,
+		Tier:        opts.StorageTier,
+	}}
+	devices = append(devices, opts.StorageDevices...)
+	created := make([]string, 0, len(devices))
+	for i, device := range devices {
+		storage, err := d.createDisk(opts.Zone, &device, opts.StorageEncryption)
+		if err != nil {
+			for _, uuid := range created {
+				delete(d.storages, uuid)
+			}
+			return nil, err
+		}
+		created = append(created, storage.UUID)
+		server.StorageDevices = append(server.StorageDevices, upcloud.ServerStorageDevice{
+			Address:   fmt.Sprintf("virtio:%d", i),
+			UUID:      storage.UUID,
+			Size:      storage.Size,
+			Tier:      storage.Tier,
+			Title:     storage.Title,
+			Type:      upcloud.StorageTypeDisk,
+			Encrypted: storage.Encrypted,
+		})
+	}
+	if opts.ISOStorageUUID != "" {
+		iso, ok := d.storages[opts.ISOStorageUUID]
+		if !ok || iso.Type != upcloud.StorageTypeCDROM {
+			return nil, notFound("ISO storage", opts.ISOStorageUUID)
+		}
+		server.StorageDevices = append(server.StorageDevices, upcloud.ServerStorageDevice{
+			Address: "ide:0:0",
+			UUID:    iso.UUID,
+			Size:    iso.Size,
+			Title:   iso.Title,
+			Type:    upcloud.StorageTypeCDROM,
+		})
+	}
+
+	for i, iface := range opts.Networking {
+		server.Networking.Interfaces = append(server.Networking.Interfaces, d.serverInterface(i+1, &iface))
+		for _, addr := range server.Networking.Interfaces[i].IPAddresses {
+			server.IPAddresses = append(server.IPAddresses, upcloud.IPAddress{
+				Access:  iface.Type,
+				Address: addr.Address,
+				Family:  addr.Family,
+			})
+		}
+	}
+
+	if opts.RemoteAccessPassword != "" {
+		server.RemoteAccessEnabled = upcloud.True
+		server.RemoteAccessType = upcloud.RemoteAccessTypeVNC
+		server.RemoteAccessHost = "127.0.0.1"
+		server.RemoteAccessPort = 5900
+		server.RemoteAccessPassword = opts.RemoteAccessPassword
+	}
+	if opts.UserData != "" || (opts.Metadata != nil && *opts.Metadata) {
+		server.Metadata = upcloud.True
+	}
+
+	d.servers[server.UUID] = server
+	response := &driver.ServerDetails{ServerDetails: *server, Username: defaultUsername}
+	if opts.CreatePassword {
+		response.Username = "Administrator"
+		response.Password = "fake-" + server.UUID[:8]
+	}
+	return response, nil
+}
+
+// createDisk creates the disk of a new server. Caller must hold the lock.
+func (d *Driver) createDisk(zone string, device *driver.StorageDeviceOpts, encrypted bool) (*upcloud.Storage, error) {
+	storage := &upcloud.Storage{
+		UUID:      d.uuid(prefixStorage),
+		Title:     device.Title,
+		Access:    upcloud.StorageAccessPrivate,
+		Type:      upcloud.StorageTypeNormal,
+		State:     upcloud.StorageStateOnline,
+		Size:      device.Size,
+		Tier:      device.Tier,
+		Zone:      zone,
+		Encrypted: upcloud.FromBool(encrypted),
+		Created:   time.Now(),
+	}
+	if storage.Tier == "" {
+		storage.Tier = upcloud.StorageTierMaxIOPS
+	}
+	if device.Action == request.CreateServerStorageDeviceActionClone {
+		source, ok := d.storages[device.StorageUUID]
+		if !ok {
+			return nil, notFound("storage", device.StorageUUID)
+		}
+		// public storages can be used in all zones
+		if source.Access == upcloud.StorageAccessPrivate && source.Zone != zone {
+			return nil, fmt.Errorf("storage %s is in zone %s instead of %s: %w", source.UUID, source.Zone, zone, driver.ErrConflict)
+		}
+		if storage.Size == 0 {
+			storage.Size = source.Size
+		}
+		if storage.Size < source.Size {
+			return nil, fmt.Errorf("storage size %d is smaller than %d of storage %s", storage.Size, source.Size, source.UUID)
+		}
+	}
+	if storage.Size == 0 {
+		return nil, fmt.Errorf("size of storage %q is missing", storage.Title)
+	}
+	d.storages[storage.UUID] = storage
+	return storage, nil
+}
+
+// serverInterface assigns addresses to the network interface. Caller must hold the lock.
+func (d *Driver) serverInterface(index int, iface *request.CreateServerInterface) upcloud.ServerInterface {
+	si := upcloud.ServerInterface{
+		Index:   index,
+		MAC:     fmt.Sprintf("ee:1b:db:ca:%02x:%02x", d.seq%256, index),
+		Network: iface.Network,
+		Type:    iface.Type,
+	}
+	for _, ip := range iface.IPAddresses {
+		addr := ip.Address
+		switch {
+		case addr != "":
+		case ip.Family == upcloud.IPAddressFamilyIPv6:
+			addr = fmt.Sprintf("2001:db8::%x:%x", d.seq, index)
+		case iface.Type == upcloud.NetworkTypePublic:
+			addr = fmt.Sprintf("198.51.100.%d", (d.seq*4+index)%254+1)
+		default:
+			addr = d.privateAddress(iface.Network, index)
+		}
+		si.IPAddresses = append(si.IPAddresses, upcloud.IPAddress{Address: addr, Family: ip.Family})
+	}
+	return si
+}
+
+// privateAddress returns an address from the private network. Caller must hold the lock.
+func (d *Driver) privateAddress(networkUUID string, index int) string {
+	if network, ok := d.networks[networkUUID]; ok && len(network.IPNetworks) > 0 {
+		if _, ipNet, err := net.ParseCIDR(network.IPNetworks[0].Address); err == nil && ipNet.IP.To4() != nil {
+			ip := ipNet.IP.To4()
+			return fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], (d.seq*4+index)%254+1)
+		}
+	}
+	return fmt.Sprintf("10.0.0.%d", (d.seq*4+index)%254+1)
+}
+
+func (d *Driver) DeleteServer(_ context.Context, serverUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeleteServer"); err != nil {
+		return err
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return notFound("server", serverUUID)
+	}
+	if server.State != upcloud.ServerStateStopped {
+		return fmt.Errorf("server %s is not stopped: %w", serverUUID, driver.ErrConflict)
+	}
+	for _, device := range server.StorageDevices {
+		if device.Type == upcloud.StorageTypeDisk {
+			delete(d.storages, device.UUID)
+		}
+	}
+	for addr, ip := range d.ipAddresses {
+		if ip.ServerUUID == serverUUID {
+			d.ipAddresses[addr].ServerUUID = ""
+			d.ipAddresses[addr].MAC = ""
+		}
+	}
+	delete(d.servers, serverUUID)
+	delete(d.firewall, serverUUID)
+	return nil
+}
+
+func (d *Driver) StopServer(ctx context.Context, serverUUID string) error {
+	if err := d.setServerState("StopServer", serverUUID, upcloud.ServerStateStopped); err != nil {
+		return err
+	}
+	return d.wait(ctx, d.Timeout)
+}
+
+// WaitServerStopped simulates the server powering itself off after Delay, e.g. when cloud-init is done.
+func (d *Driver) WaitServerStopped(ctx context.Context, serverUUID string, timeout time.Duration) error {
+	d.mu.Lock()
+	err := d.call("WaitServerStopped")
+	if _, ok := d.servers[serverUUID]; err == nil && !ok {
+		err = notFound("server", serverUUID)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := d.wait(ctx, timeout); err != nil {
+		if errors.Is(err, driver.ErrTimeout) {
+			return fmt.Errorf("server %s did not stop within %s: %w", serverUUID, timeout, driver.ErrTimeout)
+		}
+		return err
+	}
+	return d.setServerState("", serverUUID, upcloud.ServerStateStopped)
+}
+
+// setServerState sets the state of the server. Empty method is not counted as a call.
+func (d *Driver) setServerState(method, serverUUID, state string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if method != "" {
+		if err := d.call(method); err != nil {
+			return err
+		}
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return notFound("server", serverUUID)
+	}
+	server.State = state
+	return nil
+}
+
+func (d *Driver) EjectCDROM(_ context.Context, serverUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("EjectCDROM"); err != nil {
+		return err
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return notFound("server", serverUUID)
+	}
+	server.StorageDevices = slices.DeleteFunc(server.StorageDevices, func(s upcloud.ServerStorageDevice) bool {
+		return s.Type == upcloud.StorageTypeCDROM
+	})
+	return nil
+}
+
+func (d *Driver) GetServerDetails(_ context.Context, serverUUID string) (*upcloud.ServerDetails, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetServerDetails"); err != nil {
+		return nil, err
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return nil, notFound("server", serverUUID)
+	}
+	details := *server
+	return &details, nil
+}
+
+// ListServers returns servers matching all label filters. Other filters are ignored.
+func (d *Driver) ListServers(_ context.Context, filters ...request.QueryFilter) ([]upcloud.Server, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListServers"); err != nil {
+		return nil, err
+	}
+	servers := []upcloud.Server{}
+	for _, uuid := range slices.Sorted(maps.Keys(d.servers)) {
+		server := d.servers[uuid]
+		match := true
+		for _, f := range filters {
+			if label, ok := f.(request.FilterLabel); ok && !slices.Contains(server.Labels, label.Label) {
+				match = false
+			}
+		}
+		if match {
+			servers = append(servers, server.Server)
+		}
+	}
+	return servers, nil
+}
+
+func (d *Driver) GetServerStorages(_ context.Context, serverUUID string) ([]upcloud.ServerStorageDevice, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetServerStorages"); err != nil {
+		return nil, err
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return nil, notFound("server", serverUUID)
+	}
+	disks := []upcloud.ServerStorageDevice{}
+	for _, device := range server.StorageDevices {
+		if device.Type == upcloud.StorageTypeDisk {
+			disks = append(disks, device)
+		}
+	}
+	return disks, nil
+}
+
+// attachedServer returns the server the storage is attached to. Caller must hold the lock.
+func (d *Driver) attachedServer(storageUUID string) *upcloud.ServerDetails {
+	for _, server := range d.servers {
+		if server.StorageDevice(storageUUID) != nil {
+			return server
+		}
+	}
+	return nil
+}
+
+// listStorages returns storages matching access and type, or all if empty. Caller must hold the lock.
+func (d *Driver) listStorages(access, storageType string) []upcloud.Storage {
+	storages := []upcloud.Storage{}
+	for _, uuid := range slices.Sorted(maps.Keys(d.storages)) {
+		s := d.storages[uuid]
+		if (access == "" || s.Access == access) && (storageType == "" || s.Type == storageType) {
+			storages = append(storages, *copyStorage(s))
+		}
+	}
+	return storages
+}
+
+// findStorage finds storage by UUID or by title containing the name like the real driver. Caller must hold the lock.
+func (d *Driver) findStorage(storageUUID, name, storageType string) (*upcloud.Storage, error) {
+	if storageUUID != "" {
+		s, ok := d.storages[storageUUID]
+		if !ok {
+			return nil, notFound("storage", storageUUID)
+		}
+		return copyStorage(s), nil
+	}
+	if name == "" {
+		return nil, errors.New("error retrieving storage")
+	}
+	for _, s := range d.listStorages("", storageType) {
+		if strings.Contains(strings.ToLower(s.Title), strings.ToLower(name)) {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find storage by name %q: %w", name, driver.ErrNotFound)
+}
+
+func (d *Driver) GetStorage(_ context.Context, storageUUID, templateName string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetStorage"); err != nil {
+		return nil, err
+	}
+	return d.findStorage(storageUUID, templateName, upcloud.StorageTypeTemplate)
+}
+
+func (d *Driver) GetISOStorage(_ context.Context, storageUUID, storageName string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetISOStorage"); err != nil {
+		return nil, err
+	}
+	storage, err := d.findStorage(storageUUID, storageName, upcloud.StorageTypeCDROM)
+	if err != nil {
+		return nil, err
+	}
+	if storage.Type != upcloud.StorageTypeCDROM {
+		return nil, fmt.Errorf("storage %q is not a CD-ROM storage", storage.UUID)
+	}
+	return storage, nil
+}
+
+func (d *Driver) FindStorage(_ context.Context, filter *driver.StorageFilter) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("FindStorage"); err != nil {
+		return nil, err
+	}
+	matches, err := filter.Match(d.listStorages("", ""))
+	if err != nil {
+		return nil, err //nolint:wrapcheck // filter errors are descriptive
+	}
+	switch {
+	case len(matches) == 0:
+		return nil, fmt.Errorf("no storage matches the filter: %w", driver.ErrNotFound)
+	case len(matches) > 1 && !filter.MostRecent:
+		return nil, fmt.Errorf("filter matches %d storages", len(matches))
+	}
+	latest := matches[0]
+	for _, s := range matches[1:] {
+		if s.Created.After(latest.Created) {
+			latest = s
+		}
+	}
+	return &latest, nil
+}
+
+func (d *Driver) RenameStorage(ctx context.Context, storageUUID, name string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	err := d.call("RenameStorage")
+	storage, ok := d.storages[storageUUID]
+	if err == nil && !ok {
+		err = notFound("storage", storageUUID)
+	}
+	if err == nil {
+		storage.Title = name
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.waitStorageOnline(ctx, storageUUID)
+}
+
+func (d *Driver) CloneStorage(ctx context.Context, storageUUID, zone, title string, encrypted bool) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	err := d.call("CloneStorage")
+	source, ok := d.storages[storageUUID]
+	switch {
+	case err != nil:
+	case !ok:
+		err = notFound("storage", storageUUID)
+	case !slices.Contains(d.Zones, zone):
+		err = notFound("zone", zone)
+	}
+	var clone *upcloud.Storage
+	if err == nil {
+		clone = &upcloud.Storage{
+			UUID:      d.uuid(prefixStorage),
+			Title:     title,
+			Access:    upcloud.StorageAccessPrivate,
+			Type:      upcloud.StorageTypeNormal,
+			State:     upcloud.StorageStateOnline,
+			Size:      source.Size,
+			Tier:      source.Tier,
+			Zone:      zone,
+			Encrypted: upcloud.FromBool(encrypted || source.Encrypted.Bool()),
+			Created:   time.Now(),
+		}
+		d.storages[clone.UUID] = clone
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.waitStorageOnline(ctx, clone.UUID)
+}
+
+func (d *Driver) CreateTemplateStorage(ctx context.Context, title, zone string, size int, tier string, encrypted bool) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	err := d.call("CreateTemplateStorage")
+	if err == nil && !slices.Contains(d.Zones, zone) {
+		err = notFound("zone", zone)
+	}
+	var storage *upcloud.Storage
+	if err == nil {
+		storage = &upcloud.Storage{
+			UUID:      d.uuid(prefixStorage),
+			Title:     title,
+			Access:    upcloud.StorageAccessPrivate,
+			Type:      upcloud.StorageTypeNormal,
+			State:     upcloud.StorageStateOnline,
+			Size:      size,
+			Tier:      tier,
+			Zone:      zone,
+			Encrypted: upcloud.FromBool(encrypted),
+			Created:   time.Now(),
+		}
+		d.storages[storage.UUID] = storage
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.waitStorageOnline(ctx, storage.UUID)
+}
+
+// ImportStorage reads the whole image and returns its checksum.
+func (d *Driver) ImportStorage(ctx context.Context, storageUUID, contentType string, f io.Reader) (*upcloud.StorageImportDetails, error) {
+	d.mu.Lock()
+	err := d.call("ImportStorage")
+	if _, ok := d.storages[storageUUID]; err == nil && !ok {
+		err = notFound("storage", storageUUID)
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	n, err := io.Copy(hash, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if err := d.wait(ctx, d.Timeout); err != nil {
+		return nil, err
+	}
+	return &upcloud.StorageImportDetails{
+		ClientContentLength: int(n),
+		ClientContentType:   contentType,
+		ReadBytes:           int(n),
+		WrittenBytes:        int(n),
+		SHA256Sum:           hex.EncodeToString(hash.Sum(nil)),
+		Source:              upcloud.StorageImportSourceDirectUpload,
+		State:               upcloud.StorageImportStateCompleted,
+		UUID:                storageUUID,
+		Completed:           time.Now(),
+	}, nil
+}
+
+func (d *Driver) WaitStorageOnline(ctx context.Context, storageUUID string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	err := d.call("WaitStorageOnline")
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.waitStorageOnline(ctx, storageUUID)
+}
+
+func (d *Driver) waitStorageOnline(ctx context.Context, storageUUID string) (*upcloud.Storage, error) {
+	if err := d.wait(ctx, d.Timeout); err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	storage, ok := d.storages[storageUUID]
+	if !ok {
+		return nil, notFound("storage", storageUUID)
+	}
+	return copyStorage(storage), nil
+}
+
+func (d *Driver) DeleteStorage(_ context.Context, storageUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeleteStorage"); err != nil {
+		return err
+	}
+	return d.deleteStorage(storageUUID)
+}
+
+// deleteStorage deletes private storage that is not attached to a server. Caller must hold the lock.
+func (d *Driver) deleteStorage(storageUUID string) error {
+	storage, ok := d.storages[storageUUID]
+	if !ok {
+		return notFound("storage", storageUUID)
+	}
+	if storage.Access != upcloud.StorageAccessPrivate {
+		return fmt.Errorf("storage %s is public: %w", storageUUID, driver.ErrAuth)
+	}
+	if server := d.attachedServer(storageUUID); server != nil {
+		return fmt.Errorf("storage %s is attached to server %s: %w", storageUUID, server.UUID, driver.ErrConflict)
+	}
+	delete(d.storages, storageUUID)
+	return nil
+}
+
+func (d *Driver) SetStorageLabels(_ context.Context, storageUUID string, labels map[string]string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("SetStorageLabels"); err != nil {
+		return err
+	}
+	storage, ok := d.storages[storageUUID]
+	if !ok {
+		return notFound("storage", storageUUID)
+	}
+	storage.Labels = labelSlice(labels)
+	return nil
+}
+
+func (d *Driver) GetStorageDetails(_ context.Context, storageUUID string) (*upcloud.StorageDetails, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetStorageDetails"); err != nil {
+		return nil, err
+	}
+	storage, ok := d.storages[storageUUID]
+	if !ok {
+		return nil, notFound("storage", storageUUID)
+	}
+	details := &upcloud.StorageDetails{Storage: *copyStorage(storage)}
+	if server := d.attachedServer(storageUUID); server != nil {
+		details.ServerUUIDs = upcloud.ServerUUIDSlice{server.UUID}
+	}
+	return details, nil
+}
+
+func (d *Driver) ListStorages(_ context.Context, access, storageType string) ([]upcloud.Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ListStorages"); err != nil {
+		return nil, err
+	}
+	return d.listStorages(access, storageType), nil
+}
+
+func (d *Driver) GetTemplateByName(_ context.Context, name, zone string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetTemplateByName"); err != nil {
+		return nil, err
+	}
+	for _, s := range d.listStorages("", upcloud.StorageTypeTemplate) {
+		if strings.EqualFold(s.Title, name) && zone != "" && s.Zone == zone {
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to find template by name %q in zone %s: %w", name, zone, driver.ErrNotFound)
+}
+
+// CreateTemplate creates a template from the storage. Storage attached to a server can be templatized only when
+// the server is stopped.
+func (d *Driver) CreateTemplate(ctx context.Context, storageUUID, templateTitle string) (*upcloud.Storage, error) {
+	d.mu.Lock()
+	err := d.call("CreateTemplate")
+	source, ok := d.storages[storageUUID]
+	switch {
+	case err != nil:
+	case !ok:
+		err = notFound("storage", storageUUID)
+	default:
+		if server := d.attachedServer(storageUUID); server != nil && server.State != upcloud.ServerStateStopped {
+			err = fmt.Errorf("server %s is not stopped: %w", server.UUID, driver.ErrConflict)
+		}
+	}
+	var template *upcloud.Storage
+	if err == nil {
+		template = &upcloud.Storage{
+			UUID:         d.uuid(prefixStorage),
+			Title:        templateTitle,
+			Access:       upcloud.StorageAccessPrivate,
+			Type:         upcloud.StorageTypeTemplate,
+			TemplateType: upcloud.StorageTemplateTypeNative,
+			State:        upcloud.StorageStateOnline,
+			Size:         source.Size,
+			Tier:         source.Tier,
+			Zone:         source.Zone,
+			Encrypted:    source.Encrypted,
+			Created:      time.Now(),
+		}
+		d.storages[template.UUID] = template
+	}
+	d.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return d.waitStorageOnline(ctx, template.UUID)
+}
+
+func (d *Driver) DeleteTemplate(_ context.Context, templateUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeleteTemplate"); err != nil {
+		return err
+	}
+	return d.deleteStorage(templateUUID)
+}
+
+func (d *Driver) GetAvailableZones(_ context.Context) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetAvailableZones"); err != nil {
+		return []string{}
+	}
+	return slices.Clone(d.Zones)
+}
+
+func (d *Driver) GetPlanPrices(_ context.Context, zone string) ([]driver.PlanPrice, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetPlanPrices"); err != nil {
+		return nil, err
+	}
+	prices, ok := d.Prices[zone]
+	if !ok {
+		return nil, notFound("zone", zone)
+	}
+	plans := []driver.PlanPrice{}
+	for _, plan := range d.Plans {
+		if price, ok := prices["server_plan_"+plan.Name]; ok {
+			plans = append(plans, driver.PlanPrice{Plan: plan, HourlyPrice: price.Price / 100})
+		}
+	}
+	return plans, nil
+}
+
+func (d *Driver) GetZonePrices(_ context.Context, zone string) (map[string]upcloud.Price, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetZonePrices"); err != nil {
+		return nil, err
+	}
+	prices, ok := d.Prices[zone]
+	if !ok {
+		return nil, notFound("zone", zone)
+	}
+	return maps.Clone(prices), nil
+}
+
+func (d *Driver) GetAccount(_ context.Context) (*upcloud.Account, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetAccount"); err != nil {
+		return nil, err
+	}
+	account := d.Account
+	return &account, nil
+}
+
+func (d *Driver) CreateNetwork(_ context.Context, opts *driver.NetworkOpts) (*upcloud.Network, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreateNetwork"); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(d.Zones, opts.Zone) {
+		return nil, notFound("zone", opts.Zone)
+	}
+	family := upcloud.IPAddressFamilyIPv4
+	if ip, _, err := net.ParseCIDR(opts.Address); err != nil {
+		return nil, fmt.Errorf("invalid network address %q: %w", opts.Address, err)
+	} else if ip.To4() == nil {
+		family = upcloud.IPAddressFamilyIPv6
+	}
+	network := &upcloud.Network{
+		UUID:   d.uuid(prefixNetwork),
+		Type:   upcloud.NetworkTypePrivate,
+		Zone:   opts.Zone,
+		Router: opts.Router,
+		Labels: labelSlice(opts.Labels),
+		IPNetworks: upcloud.IPNetworkSlice{{
+			Address: opts.Address,
+			DHCP:    upcloud.FromBool(opts.DHCP),
+			Family:  family,
+		}},
+	}
+	network.Name = "packer-" + network.UUID
+	d.networks[network.UUID] = network
+	c := *network
+	return &c, nil
+}
+
+func (d *Driver) DeleteNetwork(_ context.Context, networkUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DeleteNetwork"); err != nil {
+		return err
+	}
+	if _, ok := d.networks[networkUUID]; !ok {
+		return notFound("network", networkUUID)
+	}
+	for _, server := range d.servers {
+		for _, iface := range server.Networking.Interfaces {
+			if iface.Network == networkUUID {
+				return fmt.Errorf("network %s is used by server %s: %w", networkUUID, server.UUID, driver.ErrConflict)
+			}
+		}
+	}
+	delete(d.networks, networkUUID)
+	return nil
+}
+
+func (d *Driver) GetNetwork(_ context.Context, networkUUID string) (*upcloud.Network, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("GetNetwork"); err != nil {
+		return nil, err
+	}
+	network, ok := d.networks[networkUUID]
+	if !ok {
+		return nil, notFound("network", networkUUID)
+	}
+	c := *network
+	return &c, nil
+}
+
+func (d *Driver) CreateFirewallRules(_ context.Context, serverUUID string, rules []upcloud.FirewallRule) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreateFirewallRules"); err != nil {
+		return err
+	}
+	if _, ok := d.servers[serverUUID]; !ok {
+		return notFound("server", serverUUID)
+	}
+	d.firewall[serverUUID] = append(d.firewall[serverUUID], rules...)
+	return nil
+}
+
+func (d *Driver) EnableFirewall(_ context.Context, serverUUID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("EnableFirewall"); err != nil {
+		return err
+	}
+	server, ok := d.servers[serverUUID]
+	if !ok {
+		return notFound("server", serverUUID)
+	}
+	server.Firewall = "on"
+	return nil
+}
+
+func (d *Driver) CreateFloatingIPAddress(_ context.Context, zone, mac string) (*upcloud.IPAddress, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("CreateFloatingIPAddress"); err != nil {
+		return nil, err
+	}
+	if !slices.Contains(d.Zones, zone) {
+		return nil, notFound("zone", zone)
+	}
+	d.seq++
+	addr := &upcloud.IPAddress{
+		Access:   upcloud.IPAddressAccessPublic,
+		Address:  fmt.Sprintf("203.0.113.%d", d.seq%254+1),
+		Family:   upcloud.IPAddressFamilyIPv4,
+		Floating: upcloud.True,
+		Zone:     zone,
+	}
+	d.ipAddresses[addr.Address] = addr
+	if err := d.attach(addr, mac); err != nil {
+		return nil, err
+	}
+	c := *addr
+	return &c, nil
+}
+
+// attach attaches the address to the server interface with the MAC address. Caller must hold the lock.
+func (d *Driver) attach(addr *upcloud.IPAddress, mac string) error {
+	for _, server := range d.servers {
+		for _, iface := range server.Networking.Interfaces {
+			if iface.MAC == mac {
+				if server.Zone != addr.Zone {
+					return fmt.Errorf("IP address %s is in zone %s instead of %s: %w", addr.Address, addr.Zone, server.Zone, driver.ErrConflict)
+				}
+				addr.MAC = mac
+				addr.ServerUUID = server.UUID
+				return nil
+			}
+		}
+	}
+	return notFound("network interface", mac)
+}
+
+func (d *Driver) AttachFloatingIPAddress(_ context.Context, address, mac string) (*upcloud.IPAddress, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("AttachFloatingIPAddress"); err != nil {
+		return nil, err
+	}
+	addr, ok := d.ipAddresses[address]
+	if !ok {
+		return nil, notFound("IP address", address)
+	}
+	if err := d.attach(addr, mac); err != nil {
+		return nil, err
+	}
+	c := *addr
+	return &c, nil
+}
+
+func (d *Driver) DetachFloatingIPAddress(_ context.Context, address string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("DetachFloatingIPAddress"); err != nil {
+		return err
+	}
+	addr, ok := d.ipAddresses[address]
+	if !ok {
+		return notFound("IP address", address)
+	}
+	addr.MAC = ""
+	addr.ServerUUID = ""
+	return nil
+}
+
+func (d *Driver) ReleaseIPAddress(_ context.Context, address string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if err := d.call("ReleaseIPAddress"); err != nil {
+		return err
+	}
+	if _, ok := d.ipAddresses[address]; !ok {
+		return notFound("IP address", address)
+	}
+	delete(d.ipAddresses, address)
+	return nil
+}
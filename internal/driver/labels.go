@@ -0,0 +1,84 @@
+package driver
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/version"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+const (
+	// LabelBuildName is the name of the Packer build that created the resource.
+	LabelBuildName string = "packer-build-name"
+	// LabelBuildUUID is the UUID of the Packer run that created the resource.
+	LabelBuildUUID string = "packer-build-uuid"
+	// LabelSourceUUID is the UUID of the storage the resource was created from.
+	LabelSourceUUID string = "packer-source-uuid"
+	// LabelPluginVersion is the version of the plugin that created the resource.
+	LabelPluginVersion string = "packer-plugin-version"
+
+	envPackerRunUUID    string = "PACKER_RUN_UUID"
+	labelValueMaxLength int    = 255
+)
+
+var labelKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9\-][a-zA-Z0-9_\-]{1,31}$`)
+
+// AutomaticLabels returns labels used to track the provenance of resources created by the plugin.
+// Empty values are omitted.
+func AutomaticLabels(buildName, sourceUUID string) map[string]string {
+	labels := map[string]string{
+		LabelPluginVersion: version.PluginVersion.FormattedVersion(),
+	}
+	if buildName != "" {
+		labels[LabelBuildName] = buildName
+	}
+	if runUUID := os.Getenv(envPackerRunUUID); runUUID != "" {
+		labels[LabelBuildUUID] = runUUID
+	}
+	if sourceUUID != "" {
+		labels[LabelSourceUUID] = sourceUUID
+	}
+	return labels
+}
+
+// MergeLabels returns a new map containing labels from all maps. Latter maps take precedence.
+func MergeLabels(labels ...map[string]string) map[string]string {
+	merged := make(map[string]string)
+	for _, l := range labels {
+		maps.Copy(merged, l)
+	}
+	return merged
+}
+
+// ValidateLabels checks that labels are accepted by the API and that reserved automatic labels are not overridden.
+func ValidateLabels(labels map[string]string) []error {
+	var errs []error
+	for _, k := range sortedKeys(labels) {
+		switch {
+		case strings.HasPrefix(k, "packer-"):
+			errs = append(errs, fmt.Errorf("label key %q is reserved, keys starting with 'packer-' are set automatically", k))
+		case !labelKeyRegexp.MatchString(k):
+			errs = append(errs, fmt.Errorf("invalid label key %q, key must be 2-32 characters long, contain only letters, numbers, '-' and '_' and not start with '_'", k))
+		case len(labels[k]) > labelValueMaxLength:
+			errs = append(errs, fmt.Errorf("value of label %q exceeds %d characters", k, labelValueMaxLength))
+		}
+	}
+	return errs
+}
+
+func labelSlice(labels map[string]string) []upcloud.Label {
+	s := make([]upcloud.Label, 0, len(labels))
+	for _, k := range sortedKeys(labels) {
+		s = append(s, upcloud.Label{Key: k, Value: labels[k]})
+	}
+	return s
+}
+
+func sortedKeys(labels map[string]string) []string {
+	return slices.Sorted(maps.Keys(labels))
+}
@@ -0,0 +1,168 @@
+package driver
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/service"
+)
+
+const (
+	DefaultRetryMaxAttempts int           = 5
+	DefaultRetryMinBackoff  time.Duration = time.Second
+	DefaultRetryMaxBackoff  time.Duration = 30 * time.Second
+	DefaultRetryJitter      float64       = 0.5
+)
+
+// transientErrorCodes are returned when the resource is temporarily in a state that does not allow the operation,
+// e.g. when the storage is being cloned or backed up.
+var transientErrorCodes = []string{ //nolint:gochecknoglobals // constant list
+	upcloud.ErrCodeStorageStateIllegal,
+	upcloud.ErrCodeServerStateIllegal,
+}
+
+type (
+	// RetryConfig configures retrying of failed API requests with exponential backoff.
+	RetryConfig struct {
+		// MaxAttempts is the maximum number of attempts including the first one. Value 1 disables retries.
+		MaxAttempts int
+		// MinBackoff is the delay before the first retry. Delay is doubled after each retry.
+		MinBackoff time.Duration
+		// MaxBackoff is the maximum delay between retries.
+		MaxBackoff time.Duration
+		// Jitter is the fraction of the delay, between 0 and 1, that is randomized to spread retries of concurrent builds.
+		Jitter float64
+	}
+
+	// retryClient retries failed API requests. Requests that may create resources are retried only when the API
+	// rejected the request or the connection was never established, so that retries never create duplicate resources.
+	// Retried DELETE requests succeed if the resource was deleted by a previous attempt.
+	retryClient struct {
+		service.Client
+
+		config RetryConfig
+	}
+)
+
+// setDefaults fills unset fields with default values.
+func (c RetryConfig) setDefaults() RetryConfig {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if c.MinBackoff <= 0 {
+		c.MinBackoff = DefaultRetryMinBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if c.Jitter <= 0 || c.Jitter > 1 {
+		c.Jitter = DefaultRetryJitter
+	}
+	return c
+}
+
+// backoff returns the delay before the retry following the attempt, starting from zero.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	delay := c.MinBackoff
+	for i := 0; i < attempt && delay < c.MaxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, c.MaxBackoff)
+	jitter := time.Duration(c.Jitter * float64(delay))
+	if jitter <= 0 {
+		return delay
+	}
+	return delay - jitter + rand.N(jitter+1) //nolint:gosec // jitter does not need to be cryptographically secure
+}
+
+func newRetryClient(c service.Client, config RetryConfig) *retryClient {
+	return &retryClient{Client: c, config: config.setDefaults()}
+}
+
+func (c *retryClient) Get(ctx context.Context, path string) ([]byte, error) {
+	return c.do(ctx, http.MethodGet, path, func() ([]byte, error) {
+		return c.Client.Get(ctx, path)
+	})
+}
+
+func (c *retryClient) Post(ctx context.Context, path string, body []byte) ([]byte, error) {
+	return c.do(ctx, http.MethodPost, path, func() ([]byte, error) {
+		return c.Client.Post(ctx, path, body)
+	})
+}
+
+func (c *retryClient) Put(ctx context.Context, path string, body []byte) ([]byte, error) {
+	return c.do(ctx, http.MethodPut, path, func() ([]byte, error) {
+		return c.Client.Put(ctx, path, body)
+	})
+}
+
+func (c *retryClient) Patch(ctx context.Context, path string, body []byte) ([]byte, error) {
+	return c.do(ctx, http.MethodPatch, path, func() ([]byte, error) {
+		return c.Client.Patch(ctx, path, body)
+	})
+}
+
+func (c *retryClient) Delete(ctx context.Context, path string) ([]byte, error) {
+	return c.do(ctx, http.MethodDelete, path, func() ([]byte, error) {
+		return c.Client.Delete(ctx, path)
+	})
+}
+
+// GetStream, Do and DoStream are not retried, because request bodies and response streams can be read only once.
+
+func (c *retryClient) do(ctx context.Context, method, path string, fn func() ([]byte, error)) ([]byte, error) {
+	for attempt := 0; ; attempt++ {
+		res, err := fn()
+		if attempt > 0 && method == http.MethodDelete && errors.Is(classifyError(err), ErrNotFound) {
+			// previous attempt deleted the resource before the connection or the server failed
+			log.Printf("[DEBUG] %s %s retry returned not found, resource was already deleted", method, path)
+			return nil, nil
+		}
+		if err == nil || attempt+1 >= c.config.MaxAttempts || !isRetryable(method, err) {
+			return res, err //nolint:wrapcheck // service parses client errors
+		}
+
+		delay := c.config.backoff(attempt)
+		log.Printf("[DEBUG] %s %s failed, retrying in %s: %s", method, path, delay, err)
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return res, err //nolint:wrapcheck // service parses client errors
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryable reports whether the request failed due to a temporary error and can be sent again. POST requests may
+// create resources, so they are retried only if the API rejected the request or the connection was never established.
+func isRetryable(method string, err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var clientErr *client.Error
+	if !errors.As(err, &clientErr) {
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		// connection failed after the request may have been sent
+		return method != http.MethodPost
+	}
+
+	if clientErr.ErrorCode == http.StatusTooManyRequests || slices.Contains(transientErrorCodes, parseProblem(clientErr).ErrorCode()) {
+		return true
+	}
+	// the request may have been processed before the server failed
+	return clientErr.ErrorCode >= http.StatusInternalServerError && method != http.MethodPost
+}
@@ -0,0 +1,150 @@
+//go:build !integration
+
+package driver //nolint:testpackage // retry client is not exported
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/service"
+)
+
+// failingClient returns the errors in order before succeeding. Calling methods other than Get, Post and Delete panics.
+type failingClient struct {
+	service.Client
+
+	errs  []error
+	calls int
+}
+
+func (c *failingClient) next() ([]byte, error) {
+	c.calls++
+	if len(c.errs) >= c.calls {
+		return nil, c.errs[c.calls-1]
+	}
+	return []byte("{}"), nil
+}
+
+func (c *failingClient) Get(_ context.Context, _ string) ([]byte, error) {
+	return c.next()
+}
+
+func (c *failingClient) Post(_ context.Context, _ string, _ []byte) ([]byte, error) {
+	return c.next()
+}
+
+func (c *failingClient) Delete(_ context.Context, _ string) ([]byte, error) {
+	return c.next()
+}
+
+func problemError(status int, code string) error {
+	return &client.Error{
+		ErrorCode:    status,
+		ResponseBody: []byte(`{"type":"https://developers.upcloud.com/1.3/errors#ERROR_` + code + `","status":0}`),
+		Type:         client.ErrorTypeProblem,
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	t.Parallel()
+	dialErr := &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+	legacyErr := &client.Error{
+		ErrorCode:    http.StatusConflict,
+		ResponseBody: []byte(`{"error":{"error_code":"STORAGE_STATE_ILLEGAL","error_message":"busy"}}`),
+		Type:         client.ErrorTypeError,
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		err    error
+		want   bool
+	}{
+		{"too many requests", http.MethodPost, problemError(http.StatusTooManyRequests, "TOO_MANY_REQUESTS"), true},
+		{"storage state", http.MethodPost, problemError(http.StatusConflict, "STORAGE_STATE_ILLEGAL"), true},
+		{"legacy storage state", http.MethodPost, legacyErr, true},
+		{"server error get", http.MethodGet, problemError(http.StatusBadGateway, "BAD_GATEWAY"), true},
+		{"server error post", http.MethodPost, problemError(http.StatusInternalServerError, "INTERNAL_ERROR"), false},
+		{"not found", http.MethodGet, problemError(http.StatusNotFound, "STORAGE_NOT_FOUND"), false},
+		{"capacity", http.MethodPost, problemError(http.StatusConflict, "SERVER_RESOURCES_UNAVAILABLE"), false},
+		{"dial post", http.MethodPost, dialErr, true},
+		{"read post", http.MethodPost, readErr, false},
+		{"read delete", http.MethodDelete, readErr, true},
+		{"canceled", http.MethodGet, context.Canceled, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, test.want, isRetryable(test.method, test.err))
+		})
+	}
+}
+
+func TestRetryClient(t *testing.T) {
+	t.Parallel()
+	config := RetryConfig{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	unavailable := problemError(http.StatusServiceUnavailable, "SERVICE_UNAVAILABLE")
+
+	c := &failingClient{errs: []error{unavailable, unavailable}}
+	_, err := newRetryClient(c, config).Get(t.Context(), "/storage")
+	require.NoError(t, err)
+	assert.Equal(t, 3, c.calls)
+
+	c = &failingClient{errs: []error{unavailable, unavailable, unavailable}}
+	_, err = newRetryClient(c, config).Get(t.Context(), "/storage")
+	require.ErrorIs(t, err, unavailable)
+	assert.Equal(t, 3, c.calls)
+
+	c = &failingClient{errs: []error{unavailable}}
+	_, err = newRetryClient(c, config).Post(t.Context(), "/server", nil)
+	require.ErrorIs(t, err, unavailable)
+	assert.Equal(t, 1, c.calls)
+}
+
+func TestRetryClient_deleteNotFound(t *testing.T) {
+	t.Parallel()
+	config := RetryConfig{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	notFound := problemError(http.StatusNotFound, "STORAGE_NOT_FOUND")
+	readErr := &net.OpError{Op: "read", Err: errors.New("connection reset by peer")}
+
+	// first attempt deleted the storage, but the connection failed before the response was read
+	c := &failingClient{errs: []error{readErr, notFound}}
+	_, err := newRetryClient(c, config).Delete(t.Context(), "/storage/01000000-0000-4000-8000-000000000000")
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls)
+
+	c = &failingClient{errs: []error{problemError(http.StatusBadGateway, "BAD_GATEWAY"), notFound}}
+	_, err = newRetryClient(c, config).Delete(t.Context(), "/storage/01000000-0000-4000-8000-000000000000")
+	require.NoError(t, err)
+	assert.Equal(t, 2, c.calls)
+
+	// storage did not exist in the first place
+	c = &failingClient{errs: []error{notFound}}
+	_, err = newRetryClient(c, config).Delete(t.Context(), "/storage/01000000-0000-4000-8000-000000000000")
+	require.ErrorIs(t, err, notFound)
+	assert.Equal(t, 1, c.calls)
+
+	c = &failingClient{errs: []error{readErr, notFound}}
+	_, err = newRetryClient(c, config).Get(t.Context(), "/storage/01000000-0000-4000-8000-000000000000")
+	require.ErrorIs(t, err, notFound)
+	assert.Equal(t, 2, c.calls)
+}
+
+func TestRetryConfig_backoff(t *testing.T) {
+	t.Parallel()
+	c := RetryConfig{MinBackoff: time.Second, MaxBackoff: 10 * time.Second, Jitter: 0.5}.setDefaults()
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second} {
+		got := c.backoff(attempt)
+		assert.LessOrEqual(t, got, want)
+		assert.GreaterOrEqual(t, got, want/2)
+	}
+}
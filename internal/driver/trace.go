@@ -0,0 +1,207 @@
+package driver
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	// EnvConfigAPIDebug enables tracing of API requests to Packer's log when set to 1.
+	EnvConfigAPIDebug string = "UPCLOUD_API_DEBUG"
+
+	// requestIDHeader identifies the request in support tickets.
+	requestIDHeader string = "X-Request-Id"
+
+	// traceBodyMaxLength limits the length of logged bodies, so that large listings do not flood the log.
+	traceBodyMaxLength int    = 16 * 1024
+	redacted           string = "[REDACTED]"
+	emptyBody          string = "<empty>"
+)
+
+var (
+	// redactedKeyRegexp matches JSON keys of credentials and SSH keys in request and response bodies, but not e.g.
+	// create_password which only tells whether to generate a password.
+	redactedKeyRegexp = regexp.MustCompile(`(?i)^(.*_)?(password|token|secret|ssh_keys?|private_key)$`) //nolint:gochecknoglobals // constant regexp
+
+	// redactedValueRegexps match credentials and SSH keys in string values, e.g. in user data.
+	redactedValueRegexps = []*regexp.Regexp{ //nolint:gochecknoglobals // constant list
+		regexp.MustCompile(`(?i)bearer\s+\S+`),
+		regexp.MustCompile(`ucat_[0-9A-Za-z_-]+`),
+		regexp.MustCompile(`(ssh-(rsa|dss|ed25519)|ecdsa-sha2-nistp\d+|sk-\S+@openssh\.com) AAAA[0-9A-Za-z+/=]+`),
+		regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?-----END [A-Z ]*PRIVATE KEY-----`),
+	}
+)
+
+type (
+	// traceTransport logs method, path, status, latency, request ID and JSON bodies of the requests with credentials
+	// and SSH keys redacted. Authorization header is never logged.
+	traceTransport struct {
+		next   http.RoundTripper
+		logger *log.Logger
+	}
+
+	// appendWriter appends each write to the file, so that the file does not need to be closed.
+	appendWriter string
+
+	// errorReader returns the error encountered when reading the original body.
+	errorReader struct {
+		err error
+	}
+)
+
+// apiDebugEnabled reports whether API requests are traced.
+func apiDebugEnabled(c *DriverConfig) bool {
+	return c.APIDebugLog != "" || os.Getenv(EnvConfigAPIDebug) == "1"
+}
+
+// newTraceTransport returns a transport tracing requests to Packer's log, and to the API debug log file if set.
+func newTraceTransport(next http.RoundTripper, debugLog string) *traceTransport {
+	w := log.Writer()
+	if debugLog != "" {
+		w = io.MultiWriter(w, appendWriter(debugLog))
+	}
+	return &traceTransport{next: next, logger: log.New(w, "", log.LstdFlags)}
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody := requestBody(req)
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		t.logger.Printf("[DEBUG] UpCloud API %s %s failed in %s: %s, request body: %s",
+			req.Method, req.URL.Path, latency, redactString(err.Error()), reqBody)
+		return res, err
+	}
+
+	var resBody string
+	res.Body, resBody = responseBody(res)
+	t.logger.Printf("[DEBUG] UpCloud API %s %s: %s in %s, request ID: %s, request body: %s, response body: %s",
+		req.Method, req.URL.Path, res.Status, latency, res.Header.Get(requestIDHeader), reqBody, resBody)
+	return res, nil
+}
+
+// requestBody returns the redacted JSON body of the request without consuming it. Other bodies, e.g. uploaded images,
+// are not read.
+func requestBody(req *http.Request) string {
+	if req.Body == nil || req.Body == http.NoBody {
+		return emptyBody
+	}
+	if req.GetBody == nil || !isJSON(req.Header.Get("Content-Type")) {
+		return bodySummary(req.ContentLength)
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return bodySummary(req.ContentLength)
+	}
+	defer body.Close()
+	b, err := io.ReadAll(body)
+	if err != nil {
+		return bodySummary(req.ContentLength)
+	}
+	return redactJSON(b)
+}
+
+// responseBody returns a replacement for the response body and the redacted JSON body.
+func responseBody(res *http.Response) (io.ReadCloser, string) {
+	if res.ContentLength == 0 {
+		return res.Body, emptyBody
+	}
+	if !isJSON(res.Header.Get("Content-Type")) {
+		return res.Body, bodySummary(res.ContentLength)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		// rest of the body is lost, so the caller gets the same error when reading it
+		return io.NopCloser(io.MultiReader(bytes.NewReader(b), &errorReader{err: err})), bodySummary(res.ContentLength)
+	}
+	return io.NopCloser(bytes.NewReader(b)), redactJSON(b)
+}
+
+// redactJSON returns the compacted JSON with credentials and SSH keys redacted.
+func redactJSON(b []byte) string {
+	if len(b) == 0 {
+		return emptyBody
+	}
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return "<invalid JSON>"
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return "<invalid JSON>"
+	}
+	if len(out) > traceBodyMaxLength {
+		return string(out[:traceBodyMaxLength]) + "...<truncated>"
+	}
+	return string(out)
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, value := range v {
+			if redactedKeyRegexp.MatchString(k) && !strings.HasPrefix(k, "create_") && value != nil {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(value)
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactValue(v[i])
+		}
+		return v
+	case string:
+		return redactString(v)
+	default:
+		return v
+	}
+}
+
+func redactString(s string) string {
+	for _, re := range redactedValueRegexps {
+		s = re.ReplaceAllString(s, redacted)
+	}
+	return s
+}
+
+// bodySummary describes a body which is not logged, e.g. an uploaded image.
+func bodySummary(length int64) string {
+	if length > 0 {
+		return fmt.Sprintf("<%d bytes not logged>", length)
+	}
+	return "<not logged>"
+}
+
+func isJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"))
+}
+
+func (r *errorReader) Read(_ []byte) (int, error) {
+	return 0, r.err
+}
+
+func (w appendWriter) Write(p []byte) (int, error) {
+	f, err := os.OpenFile(string(w), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err //nolint:wrapcheck // os errors include the path
+	}
+	n, err := f.Write(p)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err //nolint:wrapcheck // os errors include the path
+}
@@ -0,0 +1,80 @@
+//go:build integration
+
+package driver_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/upcloudtest"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// Creating servers waits for state changes polling the API every five seconds, so this test is run against the API
+// emulator with the integration tests.
+func TestNewDriver_APIDebugLog(t *testing.T) {
+	t.Parallel()
+	api := upcloudtest.NewServer()
+	api.StateDelay = 0
+	t.Cleanup(api.Close)
+
+	const (
+		sshKey   = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIEmulatorTestKey"
+		apiToken = "ucat_01emulatortesttoken"
+	)
+	debugLog := filepath.Join(t.TempDir(), "api.log")
+	drv := driver.NewDriver(&driver.DriverConfig{
+		Token:       upcloudtest.Token,
+		Timeout:     time.Minute,
+		APIURL:      api.URL,
+		APIDebugLog: debugLog,
+	})
+	ctx := t.Context()
+
+	server, err := drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID:    upcloudtest.Debian12UUID,
+		Zone:           "fi-hel1",
+		CreatePassword: true,
+		UserData:       "#cloud-config\nwrite_files:\n  - content: " + apiToken + "\n",
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, server.Password)
+	_, err = drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID:  upcloudtest.Debian12UUID,
+		Zone:         "fi-hel1",
+		SSHPublicKey: sshKey,
+		UserData:     "#cloud-config\nssh_authorized_keys:\n  - " + sshKey + "\n",
+	})
+	require.NoError(t, err)
+
+	storage, err := drv.CreateTemplateStorage(ctx, "trace", "fi-hel1", 1, upcloud.StorageTierMaxIOPS, false)
+	require.NoError(t, err)
+	_, err = drv.ImportStorage(ctx, storage.UUID, "application/octet-stream", bytes.NewReader([]byte("image content")))
+	require.NoError(t, err)
+
+	_, err = drv.GetServerDetails(ctx, "00000000-0000-4000-8000-000000000000")
+	require.ErrorIs(t, err, driver.ErrNotFound)
+
+	b, err := os.ReadFile(debugLog)
+	require.NoError(t, err)
+	trace := string(b)
+	assert.Contains(t, trace, "UpCloud API POST /1.3/server: 201 Created in")
+	assert.Contains(t, trace, `"create_password":"yes"`)
+	assert.Contains(t, trace, `"ssh_keys":"[REDACTED]"`)
+	assert.Contains(t, trace, "UpCloud API GET /1.3/server/00000000-0000-4000-8000-000000000000: 404 Not Found in")
+	assert.Contains(t, trace, "SERVER_NOT_FOUND")
+	assert.Contains(t, trace, "request body: <13 bytes not logged>", "uploaded image is not logged")
+	assert.NotContains(t, trace, "image content")
+
+	for _, secret := range []string{server.Password, sshKey, apiToken, upcloudtest.Token} {
+		assert.NotContains(t, trace, secret)
+	}
+	assert.Contains(t, trace, "[REDACTED]")
+}
@@ -0,0 +1,115 @@
+package driver
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/client"
+)
+
+// errorTransport fails every request with the error encountered when creating the HTTP transport.
+type errorTransport struct {
+	err error
+}
+
+func (t *errorTransport) RoundTrip(_ *http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// ValidateEndpoint checks the API URL, HTTP proxy URL and CA bundle file options. Empty values are valid.
+func ValidateEndpoint(apiURL, httpProxy, caBundleFile string) []error {
+	var errs []error
+	if apiURL != "" {
+		if _, err := parseHTTPURL(apiURL); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'api_url': %w", err))
+		}
+	}
+	if httpProxy != "" {
+		if _, err := parseHTTPURL(httpProxy); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'http_proxy': %w", err))
+		}
+	}
+	if caBundleFile != "" {
+		if _, err := loadCABundle(caBundleFile); err != nil {
+			errs = append(errs, fmt.Errorf("invalid 'ca_bundle_file': %w", err))
+		}
+	}
+	return errs
+}
+
+// newHTTPClient returns an HTTP client using the proxy and CA bundle of the configuration, or nil if the default client
+// should be used. Configuration is validated before the driver is created, so errors are returned by the requests.
+func newHTTPClient(c *DriverConfig) *http.Client {
+	if c.HTTPProxy == "" && c.CABundleFile == "" {
+		return nil
+	}
+	transport, err := newHTTPTransport(c.HTTPProxy, c.CABundleFile)
+	if err != nil {
+		log.Printf("[ERROR] failed to configure HTTP transport: %s", err)
+		return &http.Client{Transport: &errorTransport{err: err}}
+	}
+	return &http.Client{Transport: transport}
+}
+
+// newHTTPTransport returns the default transport of the UpCloud client using the proxy and trusting the certificates
+// of the CA bundle in addition to the system certificates.
+func newHTTPTransport(httpProxy, caBundleFile string) (*http.Transport, error) {
+	transport, ok := client.NewDefaultHTTPTransport().(*http.Transport)
+	if !ok {
+		return nil, errors.New("default HTTP transport is not *http.Transport")
+	}
+	transport = transport.Clone()
+
+	if httpProxy != "" {
+		proxyURL, err := parseHTTPURL(httpProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if caBundleFile != "" {
+		pool, err := loadCABundle(caBundleFile)
+		if err != nil {
+			return nil, err
+		}
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = client.NewDefaultTLSClientConfig()
+		}
+		transport.TLSClientConfig.RootCAs = pool
+	}
+	return transport, nil
+}
+
+// loadCABundle returns the system certificate pool extended with the PEM encoded certificates of the file.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no PEM encoded certificates found in CA bundle %s", path)
+	}
+	return pool, nil
+}
+
+// parseHTTPURL parses an absolute HTTP(S) URL. Errors redact the password, which the proxy URL may contain.
+func parseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.New("malformed URL")
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q must be an absolute http or https URL", u.Redacted())
+	}
+	return u, nil
+}
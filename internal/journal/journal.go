@@ -0,0 +1,254 @@
+// Package journal records resources created during a build to an on-disk file so that they can be removed
+// even if the build process is killed before its cleanup is run.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+)
+
+const (
+	KindServer   string = "server"
+	KindStorage  string = "storage"
+	KindTemplate string = "template"
+	KindNetwork  string = "network"
+	// KindIPAddress entries use IP address as UUID.
+	KindIPAddress string = "ip_address"
+
+	fileExtension string      = ".json"
+	dirPerm       os.FileMode = 0o700
+)
+
+// Entry is a resource created during the build.
+type Entry struct {
+	Kind    string    `json:"kind"`
+	UUID    string    `json:"uuid"`
+	Zone    string    `json:"zone,omitempty"`
+	Title   string    `json:"title,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+type content struct {
+	Hostname string    `json:"hostname"`
+	PID      int       `json:"pid"`
+	Build    string    `json:"build"`
+	Started  time.Time `json:"started"`
+	Entries  []Entry   `json:"entries"`
+}
+
+// Journal is the on-disk record of resources created during a build.
+// The file is written on every change and removed when the journal becomes empty.
+// Methods of a nil Journal are no-ops.
+type Journal struct {
+	mu      sync.Mutex
+	path    string
+	content content
+}
+
+// New returns a journal for a new build. The file is created in dir when the first entry is added.
+func New(dir, build string) (*Journal, error) {
+	if err := os.MkdirAll(dir, dirPerm); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+	hostname, _ := os.Hostname()
+	now := time.Now()
+	name := fmt.Sprintf("%s-%d%s", now.Format("20060102-150405"), os.Getpid(), fileExtension)
+	if build != "" {
+		name = sanitize(build) + "-" + name
+	}
+	return &Journal{
+		path: filepath.Join(dir, name),
+		content: content{
+			Hostname: hostname,
+			PID:      os.Getpid(),
+			Build:    build,
+			Started:  now,
+			Entries:  make([]Entry, 0),
+		},
+	}, nil
+}
+
+// Open reads an existing journal file.
+func Open(path string) (*Journal, error) {
+	b, err := os.ReadFile(path) // #nosec G304 -- journal files are read from journal directory
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+	j := &Journal{path: path}
+	if err := json.Unmarshal(b, &j.content); err != nil {
+		return nil, fmt.Errorf("failed to parse journal %s: %w", path, err)
+	}
+	return j, nil
+}
+
+// List returns paths of journal files in dir.
+func List(dir string) ([]string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*"+fileExtension))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list journals: %w", err)
+	}
+	slices.Sort(files)
+	return files, nil
+}
+
+// Add records a created resource.
+func (j *Journal) Add(e Entry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if e.Created.IsZero() {
+		e.Created = time.Now()
+	}
+	j.content.Entries = append(j.content.Entries, e)
+	return j.write()
+}
+
+// Remove forgets resources which have been deleted or handed over as an artifact.
+func (j *Journal) Remove(uuids ...string) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	n := len(j.content.Entries)
+	j.content.Entries = slices.DeleteFunc(j.content.Entries, func(e Entry) bool {
+		return slices.Contains(uuids, e.UUID)
+	})
+	if n == len(j.content.Entries) {
+		return nil
+	}
+	return j.write()
+}
+
+// Entries returns recorded resources, the most recently created first.
+func (j *Journal) Entries() []Entry {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := slices.Clone(j.content.Entries)
+	slices.Reverse(entries)
+	return entries
+}
+
+// Path returns the journal file path.
+func (j *Journal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+// Build returns the name of the build that created the journal.
+func (j *Journal) Build() string {
+	if j == nil {
+		return ""
+	}
+	return j.content.Build
+}
+
+// Abandoned returns true if the process that created the journal is no longer running.
+// Process of journals created on other hosts cannot be checked, so they are considered abandoned when the journal
+// was last written before cutoff.
+func (j *Journal) Abandoned(cutoff time.Time) bool {
+	if j == nil {
+		return false
+	}
+	hostname, _ := os.Hostname()
+	if j.content.Hostname != hostname {
+		return j.lastWritten().Before(cutoff)
+	}
+	return j.content.PID != os.Getpid() && !processRunning(j.content.PID)
+}
+
+// lastWritten returns the time the build started or the last entry was added, whichever is later.
+func (j *Journal) lastWritten() time.Time {
+	last := j.content.Started
+	for _, e := range j.content.Entries {
+		if e.Created.After(last) {
+			last = e.Created
+		}
+	}
+	return last
+}
+
+func (j *Journal) write() error {
+	if len(j.content.Entries) == 0 {
+		if err := os.Remove(j.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+		return nil
+	}
+
+	b, err := json.MarshalIndent(j.content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+
+	// write to temporary file (created with 0600 permissions) and rename it so that the journal is never left partially written
+	f, err := os.CreateTemp(filepath.Dir(j.path), ".journal-*")
+	if err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck // file does not exist after successful rename
+
+	if _, err := f.Write(b); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if err := os.Rename(f.Name(), j.path); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+func sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// DefaultDir returns the default journal directory in Packer cache directory.
+func DefaultDir() (string, error) {
+	dir, err := packer.CachePath("upcloud", "journal")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve journal directory: %w", err)
+	}
+	return dir, nil
+}
+
+// OpenDir returns a journal for a new build in dir, or in the default directory if dir is empty.
+func OpenDir(dir, build string) (*Journal, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	return New(dir, build)
+}
@@ -0,0 +1,89 @@
+//go:build !integration
+
+package journal_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
+)
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+
+	j, err := journal.New(dir, "upcloud.example")
+	require.NoError(t, err)
+	assert.Equal(t, "upcloud.example", j.Build())
+	assert.NoFileExists(t, j.Path())
+
+	require.NoError(t, j.Add(journal.Entry{Kind: journal.KindServer, UUID: "server", Zone: "fi-hel1"}))
+	require.NoError(t, j.Add(journal.Entry{Kind: journal.KindTemplate, UUID: "template", Zone: "fi-hel1"}))
+	assert.FileExists(t, j.Path())
+
+	paths, err := journal.List(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{j.Path()}, paths)
+
+	opened, err := journal.Open(j.Path())
+	require.NoError(t, err)
+	entries := opened.Entries()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "template", entries[0].UUID)
+	assert.Equal(t, "server", entries[1].UUID)
+	assert.False(t, entries[0].Created.IsZero())
+	// journal of the current process is never abandoned
+	assert.False(t, opened.Abandoned(time.Now().Add(time.Hour)))
+
+	require.NoError(t, j.Remove("template", "unknown"))
+	assert.Len(t, j.Entries(), 1)
+	require.NoError(t, j.Remove("server"))
+	assert.NoFileExists(t, j.Path())
+}
+
+func TestJournal_Nil(t *testing.T) {
+	t.Parallel()
+	var j *journal.Journal
+	require.NoError(t, j.Add(journal.Entry{UUID: "server"}))
+	require.NoError(t, j.Remove("server"))
+	assert.Empty(t, j.Entries())
+	assert.False(t, j.Abandoned(time.Now()))
+}
+
+func TestJournal_Abandoned(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+	now := time.Now()
+
+	write := func(name, host string, created time.Time) string {
+		b, err := json.Marshal(map[string]any{
+			"hostname": host,
+			"pid":      1 << 30, // not a valid PID on any supported platform
+			"started":  created.Add(-time.Minute),
+			"entries":  []journal.Entry{{Kind: journal.KindStorage, UUID: "storage", Created: created}},
+		})
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, b, 0o600))
+		return path
+	}
+
+	j, err := journal.Open(write("local.json", hostname, now))
+	require.NoError(t, err)
+	assert.True(t, j.Abandoned(now.Add(-time.Hour)))
+
+	// process running on other host cannot be checked, so journals written after cutoff are not abandoned
+	j, err = journal.Open(write("remote.json", hostname+"-other", now.Add(-30*time.Minute)))
+	require.NoError(t, err)
+	assert.False(t, j.Abandoned(now.Add(-time.Hour)))
+	assert.True(t, j.Abandoned(now.Add(-10*time.Minute)))
+}
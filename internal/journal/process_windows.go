@@ -0,0 +1,17 @@
+//go:build windows
+
+package journal
+
+import (
+	"os"
+)
+
+func processRunning(pid int) bool {
+	// FindProcess fails on Windows if the process does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
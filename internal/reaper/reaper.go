@@ -0,0 +1,346 @@
+// Package reaper finds and deletes servers and storages left behind by interrupted Packer builds.
+package reaper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+const (
+	KindServer    string = journal.KindServer
+	KindStorage   string = journal.KindStorage
+	KindTemplate  string = journal.KindTemplate
+	KindNetwork   string = journal.KindNetwork
+	KindIPAddress string = journal.KindIPAddress
+
+	reasonTitle   string = "title"
+	reasonLabels  string = "labels"
+	reasonJournal string = "journal"
+)
+
+// titlePatterns match titles of resources created by the plugin. Timestamps in the titles are in the local time of the
+// build host, so creation times are read from the API instead.
+var titlePatterns = []*regexp.Regexp{ //nolint:gochecknoglobals // compiled once
+	// build server, e.g. packer-custom-20260101-120000
+	regexp.MustCompile(`^packer-` + driver.DefaultHostname + `-\d{8}-\d{6}$`),
+	// storage cloned to clone_zones by the builder, e.g. packer-20260101-120000-cloned-custom-disk1
+	regexp.MustCompile(`^packer-\d{8}-\d{6}-cloned-.+$`),
+	// storage created by the import post-processor, e.g. packer.post-processor.upcloud-import-20260101-120000
+	regexp.MustCompile(`^packer\.post-processor\.upcloud-import-\d{8}-\d{6}$`),
+}
+
+// Options define which resources are considered orphaned.
+type Options struct {
+	// MinAge is the minimum age of resource to be reaped.
+	MinAge time.Duration
+	// Zone limits search to a single zone. Empty value matches all zones.
+	Zone string
+	// Labels which resource must have in addition to being created by the plugin.
+	Labels map[string]string
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Resource is a server, storage, template, network or floating IP address found by the reaper.
+type Resource struct {
+	Kind    string
+	UUID    string
+	Title   string
+	Zone    string
+	Created time.Time
+	// Reason tells whether the resource was matched by title, labels or journal.
+	Reason string
+
+	journal *journal.Journal
+}
+
+// Reaper finds and deletes orphaned resources.
+type Reaper struct {
+	driver driver.Driver
+	opts   Options
+}
+
+func New(drv driver.Driver, opts Options) *Reaper {
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Reaper{driver: drv, opts: opts}
+}
+
+// Find returns servers and storages created by the plugin that are older than MinAge.
+func (r *Reaper) Find(ctx context.Context) ([]Resource, error) {
+	servers, err := r.findServers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	storages, err := r.findStorages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(servers, storages...), nil
+}
+
+func (r *Reaper) findServers(ctx context.Context) ([]Resource, error) {
+	all, err := r.driver.ListServers(ctx)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // driver errors are already wrapped
+	}
+	labelled, err := r.driver.ListServers(ctx, request.FilterLabelKey{Key: driver.LabelPluginVersion})
+	if err != nil {
+		return nil, err //nolint:wrapcheck // driver errors are already wrapped
+	}
+	labelledUUIDs := make(map[string]bool, len(labelled))
+	for _, s := range labelled {
+		labelledUUIDs[s.UUID] = true
+	}
+
+	resources := make([]Resource, 0)
+	for _, s := range all {
+		titleMatch := titleMatches(s.Title)
+		if !titleMatch && !labelledUUIDs[s.UUID] {
+			continue
+		}
+		if !r.matchZone(s.Zone) {
+			continue
+		}
+		details, err := r.driver.GetServerDetails(ctx, s.UUID)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // driver errors are already wrapped
+		}
+		if !hasLabels(details.Labels, r.opts.Labels) {
+			continue
+		}
+		// API does not tell when the server was created, and timestamps in titles are in the local time of the build
+		// host, so creation time of the oldest disk of the server is used instead
+		created, err := r.disksCreated(ctx, details)
+		if err != nil {
+			return nil, err
+		}
+		if !r.oldEnough(created) {
+			continue
+		}
+		resources = append(resources, Resource{
+			Kind:    KindServer,
+			UUID:    s.UUID,
+			Title:   s.Title,
+			Zone:    s.Zone,
+			Created: created,
+			Reason:  reason(titleMatch),
+		})
+	}
+	return resources, nil
+}
+
+func (r *Reaper) findStorages(ctx context.Context) ([]Resource, error) {
+	storages, err := r.driver.ListStorages(ctx, upcloud.StorageAccessPrivate, upcloud.StorageTypeNormal)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // driver errors are already wrapped
+	}
+
+	resources := make([]Resource, 0)
+	for _, s := range storages {
+		titleMatch := titleMatches(s.Title)
+		labelMatch := labelValue(s.Labels, driver.LabelPluginVersion) != ""
+		if !titleMatch && !labelMatch {
+			continue
+		}
+		if !r.matchZone(s.Zone) || !hasLabels(s.Labels, r.opts.Labels) {
+			continue
+		}
+
+		// storages attached to servers are deleted together with the server
+		details, err := r.driver.GetStorageDetails(ctx, s.UUID)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // driver errors are already wrapped
+		}
+		if len(details.ServerUUIDs) > 0 {
+			continue
+		}
+		created := details.Created
+		if !r.oldEnough(created) {
+			continue
+		}
+		resources = append(resources, Resource{
+			Kind:    KindStorage,
+			UUID:    s.UUID,
+			Title:   s.Title,
+			Zone:    s.Zone,
+			Created: created,
+			Reason:  reason(titleMatch),
+		})
+	}
+	return resources, nil
+}
+
+// disksCreated returns creation time of the oldest disk of the server, or zero time if the server has no disks.
+func (r *Reaper) disksCreated(ctx context.Context, details *upcloud.ServerDetails) (time.Time, error) {
+	var created time.Time
+	for _, d := range details.StorageDevices {
+		if d.Type != upcloud.StorageTypeDisk {
+			continue
+		}
+		storage, err := r.driver.GetStorageDetails(ctx, d.UUID)
+		if err != nil {
+			return time.Time{}, err //nolint:wrapcheck // driver errors are already wrapped
+		}
+		if !storage.Created.IsZero() && (created.IsZero() || storage.Created.Before(created)) {
+			created = storage.Created
+		}
+	}
+	return created, nil
+}
+
+// FromJournals returns resources recorded in journals left behind by builds that are no longer running.
+// Journals of running builds are skipped, and journals created on other hosts unless last written MinAge ago.
+func (r *Reaper) FromJournals(dir string) ([]Resource, error) {
+	paths, err := journal.List(dir)
+	if err != nil {
+		return nil, err //nolint:wrapcheck // journal errors are descriptive
+	}
+
+	resources := make([]Resource, 0)
+	for _, path := range paths {
+		j, err := journal.Open(path)
+		if err != nil {
+			return nil, err //nolint:wrapcheck // journal errors are descriptive
+		}
+		if !j.Abandoned(r.opts.Now().Add(-r.opts.MinAge)) {
+			continue
+		}
+		for _, e := range j.Entries() {
+			if !r.matchZone(e.Zone) {
+				continue
+			}
+			resources = append(resources, Resource{
+				Kind:    e.Kind,
+				UUID:    e.UUID,
+				Title:   e.Title,
+				Zone:    e.Zone,
+				Created: e.Created,
+				Reason:  reasonJournal,
+				journal: j,
+			})
+		}
+	}
+	return resources, nil
+}
+
+// Delete deletes the resources. Servers are stopped and their CD-ROMs ejected before the server and
+// its disks are deleted. Deletion continues on error and all errors are returned.
+func (r *Reaper) Delete(ctx context.Context, w io.Writer, resources []Resource) error {
+	var errs []error
+	for _, res := range resources {
+		fmt.Fprintf(w, "Deleting %s %q (%s)...\n", res.Kind, res.Title, res.UUID)
+		var err error
+		switch res.Kind {
+		case KindServer:
+			err = r.deleteServer(ctx, res.UUID)
+		case KindStorage:
+			err = r.driver.DeleteStorage(ctx, res.UUID)
+		case KindTemplate:
+			err = r.driver.DeleteTemplate(ctx, res.UUID)
+		case KindNetwork:
+			err = r.driver.DeleteNetwork(ctx, res.UUID)
+		case KindIPAddress:
+			err = r.driver.ReleaseIPAddress(ctx, res.UUID)
+		default:
+			err = fmt.Errorf("unknown resource kind %q", res.Kind)
+		}
+		if errors.Is(err, driver.ErrNotFound) {
+			fmt.Fprintf(w, "%s %q has already been deleted\n", res.Kind, res.UUID)
+			err = nil
+		}
+		if err != nil {
+			fmt.Fprintf(w, "Failed to delete %s %q: %s\n", res.Kind, res.UUID, err)
+			errs = append(errs, err)
+			continue
+		}
+		if err := res.journal.Remove(res.UUID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (r *Reaper) deleteServer(ctx context.Context, serverUUID string) error {
+	if err := r.driver.StopServer(ctx, serverUUID); err != nil {
+		return err //nolint:wrapcheck // driver errors are already wrapped
+	}
+
+	// eject ISO image so that it's not deleted together with the server storages
+	details, err := r.driver.GetServerDetails(ctx, serverUUID)
+	if err != nil {
+		return err //nolint:wrapcheck // driver errors are already wrapped
+	}
+	if slices.ContainsFunc(details.StorageDevices, func(d upcloud.ServerStorageDevice) bool {
+		return d.Type == upcloud.StorageTypeCDROM && d.UUID != ""
+	}) {
+		if err := r.driver.EjectCDROM(ctx, serverUUID); err != nil {
+			return err //nolint:wrapcheck // driver errors are already wrapped
+		}
+	}
+	return r.driver.DeleteServer(ctx, serverUUID) //nolint:wrapcheck // driver errors are already wrapped
+}
+
+// WriteReport writes resources as a table.
+func (r *Reaper) WriteReport(w io.Writer, resources []Resource) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tUUID\tZONE\tAGE\tMATCHED BY\tTITLE")
+	for _, res := range resources {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			res.Kind, res.UUID, res.Zone, r.opts.Now().Sub(res.Created).Truncate(time.Minute), res.Reason, res.Title)
+	}
+	return tw.Flush() //nolint:wrapcheck // writer errors are returned as is
+}
+
+func (r *Reaper) matchZone(zone string) bool {
+	return r.opts.Zone == "" || r.opts.Zone == zone
+}
+
+// oldEnough returns false also when creation time is not known.
+func (r *Reaper) oldEnough(created time.Time) bool {
+	return !created.IsZero() && r.opts.Now().Sub(created) >= r.opts.MinAge
+}
+
+// titleMatches returns true if the title matches any of the titles of resources created by the plugin.
+func titleMatches(title string) bool {
+	return slices.ContainsFunc(titlePatterns, func(re *regexp.Regexp) bool {
+		return re.MatchString(title)
+	})
+}
+
+func hasLabels(labels []upcloud.Label, want map[string]string) bool {
+	for k, v := range want {
+		if !slices.Contains(labels, upcloud.Label{Key: k, Value: v}) {
+			return false
+		}
+	}
+	return true
+}
+
+func labelValue(labels []upcloud.Label, key string) string {
+	for _, l := range labels {
+		if l.Key == key {
+			return l.Value
+		}
+	}
+	return ""
+}
+
+func reason(titleMatch bool) string {
+	if titleMatch {
+		return reasonTitle
+	}
+	return reasonLabels
+}
@@ -0,0 +1,262 @@
+//go:build !integration
+
+package reaper_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/reaper"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+// stubDriver implements methods used by the reaper. Calling other methods panics.
+type stubDriver struct {
+	driver.Driver
+
+	servers         []upcloud.Server
+	labelledServers []upcloud.Server
+	serverDetails   map[string]*upcloud.ServerDetails
+	storages        []upcloud.Storage
+	storageDetails  map[string]*upcloud.StorageDetails
+	failDelete      map[string]bool
+	deleted         map[string]bool
+	calls           []string
+}
+
+func (d *stubDriver) ListServers(_ context.Context, filters ...request.QueryFilter) ([]upcloud.Server, error) {
+	if len(filters) > 0 {
+		return d.labelledServers, nil
+	}
+	return d.servers, nil
+}
+
+func (d *stubDriver) GetServerDetails(_ context.Context, serverUUID string) (*upcloud.ServerDetails, error) {
+	return d.serverDetails[serverUUID], nil
+}
+
+func (d *stubDriver) ListStorages(_ context.Context, _, _ string) ([]upcloud.Storage, error) {
+	return d.storages, nil
+}
+
+func (d *stubDriver) GetStorageDetails(_ context.Context, storageUUID string) (*upcloud.StorageDetails, error) {
+	return d.storageDetails[storageUUID], nil
+}
+
+func (d *stubDriver) StopServer(_ context.Context, serverUUID string) error {
+	d.calls = append(d.calls, "stop "+serverUUID)
+	return nil
+}
+
+func (d *stubDriver) EjectCDROM(_ context.Context, serverUUID string) error {
+	d.calls = append(d.calls, "eject "+serverUUID)
+	return nil
+}
+
+func (d *stubDriver) DeleteServer(_ context.Context, serverUUID string) error {
+	d.calls = append(d.calls, "delete server "+serverUUID)
+	return nil
+}
+
+func (d *stubDriver) DeleteStorage(_ context.Context, storageUUID string) error {
+	if d.failDelete[storageUUID] {
+		return errors.New("storage is busy")
+	}
+	d.calls = append(d.calls, "delete storage "+storageUUID)
+	return nil
+}
+
+func (d *stubDriver) DeleteTemplate(_ context.Context, templateUUID string) error {
+	if d.deleted[templateUUID] {
+		return fmt.Errorf("failed to delete storage %s: %w", templateUUID, driver.ErrNotFound)
+	}
+	d.calls = append(d.calls, "delete template "+templateUUID)
+	return nil
+}
+
+func newStubDriver(now time.Time) *stubDriver {
+	old := now.Add(-24 * time.Hour)
+	labels := []upcloud.Label{{Key: driver.LabelPluginVersion, Value: "1.10.0"}, {Key: "team", Value: "platform"}}
+	return &stubDriver{
+		servers: []upcloud.Server{
+			{UUID: "s-old", Title: "packer-custom-" + old.Format("20060102-150405"), Zone: "fi-hel1"},
+			{UUID: "s-new", Title: "packer-custom-" + now.Add(-time.Minute).Format("20060102-150405"), Zone: "fi-hel1"},
+			{UUID: "s-other", Title: "production-web-1", Zone: "fi-hel1"},
+			{UUID: "s-zone", Title: "packer-custom-" + old.Format("20060102-150405"), Zone: "de-fra1"},
+			// build host is ten hours ahead of the reaper, so the title looks older than it is
+			{UUID: "s-timezone", Title: "packer-custom-" + now.In(time.FixedZone("UTC+10", 10*3600)).Format("20060102-150405"), Zone: "fi-hel1"},
+			{UUID: "s-labelled", Title: "custom-build", Zone: "fi-hel1"},
+			{UUID: "s-labelled-new", Title: "custom-build", Zone: "fi-hel1"},
+		},
+		labelledServers: []upcloud.Server{
+			{UUID: "s-labelled", Title: "custom-build", Zone: "fi-hel1"},
+			{UUID: "s-labelled-new", Title: "custom-build", Zone: "fi-hel1"},
+		},
+		serverDetails: map[string]*upcloud.ServerDetails{
+			"s-labelled": {Labels: labels, StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-labelled-server-disk", Type: upcloud.StorageTypeDisk},
+			}},
+			"s-labelled-new": {Labels: labels, StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-labelled-server-new-disk", Type: upcloud.StorageTypeDisk},
+			}},
+			"s-old": {StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-old-disk", Type: upcloud.StorageTypeDisk},
+				{UUID: "iso", Type: upcloud.StorageTypeCDROM},
+			}},
+			"s-new": {StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-new-disk", Type: upcloud.StorageTypeDisk},
+			}},
+			"s-zone": {StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-zone-disk", Type: upcloud.StorageTypeDisk},
+			}},
+			"s-timezone": {StorageDevices: []upcloud.ServerStorageDevice{
+				{UUID: "st-timezone-disk", Type: upcloud.StorageTypeDisk},
+			}},
+		},
+		storages: []upcloud.Storage{
+			{UUID: "st-clone", Title: "packer-" + old.Format("20060102-150405") + "-cloned-disk1", Zone: "de-fra1"},
+			{UUID: "st-clone-extra", Title: "packer-" + old.Format("20060102-150405") + "-cloned-" + driver.DefaultHostname + "-disk2", Zone: "de-fra1"},
+			{UUID: "st-import", Title: "packer.post-processor.upcloud-import-" + old.Format("20060102-150405"), Zone: "fi-hel1", Labels: labels},
+			{UUID: "st-labelled", Title: "custom-disk1", Zone: "fi-hel1", Labels: labels},
+			{UUID: "st-attached", Title: "custom-disk1", Zone: "fi-hel1", Labels: labels},
+			{UUID: "st-other", Title: "database", Zone: "fi-hel1"},
+		},
+		storageDetails: map[string]*upcloud.StorageDetails{
+			"st-clone":       {Storage: upcloud.Storage{Created: old}},
+			"st-clone-extra": {Storage: upcloud.Storage{Created: old}},
+			"st-import":      {Storage: upcloud.Storage{Created: old}},
+			"st-labelled":    {Storage: upcloud.Storage{Created: old}},
+			"st-attached":    {Storage: upcloud.Storage{Created: old}, ServerUUIDs: upcloud.ServerUUIDSlice{"s-old"}},
+
+			"st-old-disk":                 {Storage: upcloud.Storage{Created: old}},
+			"st-new-disk":                 {Storage: upcloud.Storage{Created: now.Add(-time.Minute)}},
+			"st-zone-disk":                {Storage: upcloud.Storage{Created: old}},
+			"st-timezone-disk":            {Storage: upcloud.Storage{Created: now.Add(-time.Minute)}},
+			"st-labelled-server-disk":     {Storage: upcloud.Storage{Created: old}},
+			"st-labelled-server-new-disk": {Storage: upcloud.Storage{Created: now.Add(-time.Minute)}},
+		},
+	}
+}
+
+func uuids(resources []reaper.Resource) []string {
+	u := make([]string, 0, len(resources))
+	for _, r := range resources {
+		u = append(u, r.UUID)
+	}
+	return u
+}
+
+func TestReaper_Find(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	drv := newStubDriver(now)
+
+	r := reaper.New(drv, reaper.Options{MinAge: time.Hour, Now: func() time.Time { return now }})
+	resources, err := r.Find(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s-old", "s-zone", "s-labelled", "st-clone", "st-clone-extra", "st-import", "st-labelled"}, uuids(resources))
+
+	r = reaper.New(drv, reaper.Options{MinAge: time.Hour, Zone: "fi-hel1", Labels: map[string]string{"team": "platform"}})
+	resources, err = r.Find(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"s-labelled", "st-import", "st-labelled"}, uuids(resources))
+}
+
+func TestReaper_Delete(t *testing.T) {
+	t.Parallel()
+	now := time.Now()
+	drv := newStubDriver(now)
+	drv.failDelete = map[string]bool{"st-import": true}
+
+	r := reaper.New(drv, reaper.Options{MinAge: time.Hour})
+	resources, err := r.Find(t.Context())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, r.WriteReport(&out, resources))
+	assert.Contains(t, out.String(), "st-clone")
+
+	err = r.Delete(t.Context(), &out, resources)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "storage is busy")
+	assert.Equal(t, []string{
+		"stop s-old",
+		"eject s-old",
+		"delete server s-old",
+		"stop s-zone",
+		"delete server s-zone",
+		"stop s-labelled",
+		"delete server s-labelled",
+		"delete storage st-clone",
+		"delete storage st-clone-extra",
+		"delete storage st-labelled",
+	}, drv.calls)
+}
+
+func TestReaper_FromJournals(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	hostname, err := os.Hostname()
+	require.NoError(t, err)
+
+	// journal of a crashed build
+	b, err := json.Marshal(map[string]any{
+		"hostname": hostname,
+		"pid":      1 << 30,
+		"entries": []journal.Entry{
+			{Kind: journal.KindServer, UUID: "s-crashed", Zone: "fi-hel1"},
+			{Kind: journal.KindTemplate, UUID: "t-crashed", Zone: "fi-hel1"},
+			{Kind: journal.KindStorage, UUID: "st-crashed", Zone: "de-fra1"},
+		},
+	})
+	require.NoError(t, err)
+	path := filepath.Join(dir, "crashed.json")
+	require.NoError(t, os.WriteFile(path, b, 0o600))
+
+	// journal of a running build
+	running, err := journal.New(dir, "running")
+	require.NoError(t, err)
+	require.NoError(t, running.Add(journal.Entry{Kind: journal.KindServer, UUID: "s-running"}))
+
+	drv := &stubDriver{
+		serverDetails: map[string]*upcloud.ServerDetails{"s-crashed": {}},
+		failDelete:    map[string]bool{"st-crashed": true},
+		deleted:       map[string]bool{"t-crashed": true},
+	}
+	r := reaper.New(drv, reaper.Options{Zone: "fi-hel1"})
+	resources, err := r.FromJournals(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"t-crashed", "s-crashed"}, uuids(resources))
+
+	var out bytes.Buffer
+	require.NoError(t, r.Delete(t.Context(), &out, resources))
+	assert.Contains(t, out.String(), "already been deleted")
+	assert.Equal(t, []string{"stop s-crashed", "delete server s-crashed"}, drv.calls)
+
+	// resource in other zone is left to journal
+	j, err := journal.Open(path)
+	require.NoError(t, err)
+	entries := j.Entries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, "st-crashed", entries[0].UUID)
+
+	// failed deletion keeps the entry
+	r = reaper.New(drv, reaper.Options{})
+	resources, err = r.FromJournals(dir)
+	require.NoError(t, err)
+	require.Error(t, r.Delete(t.Context(), &out, resources))
+	assert.FileExists(t, path)
+}
@@ -0,0 +1,90 @@
+package upcloudtest
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+)
+
+// EnvEmulator runs acceptance tests against the emulator instead of the UpCloud API when set to 1.
+const EnvEmulator string = "UPCLOUD_EMULATOR"
+
+var (
+	hclSourceRegexp       = regexp.MustCompile(`(?m)^(source "upcloud" "[^"]+" \{)\n`)        //nolint:gochecknoglobals // constant regexp
+	hclCommunicatorRegexp = regexp.MustCompile(`(?m)^[ \t]*communicator\s*=.*\n`)             //nolint:gochecknoglobals // constant regexp
+	hclProvisionerRegexp  = regexp.MustCompile(`(?ms)^\n?  provisioner "[^"]+" \{.*?^  \}\n`) //nolint:gochecknoglobals // constant regexp
+)
+
+// Emulated reports whether acceptance tests are run against the emulator.
+func Emulated() bool {
+	return os.Getenv(EnvEmulator) == "1"
+}
+
+// RunAcceptanceTests runs the tests and returns the exit code. When EnvEmulator is set, the emulator is started and
+// passed to the tests, and to the Packer processes started by them, using the environment variables of `api_url` option
+// and credentials.
+func RunAcceptanceTests(m *testing.M) int {
+	if !Emulated() {
+		return m.Run()
+	}
+
+	srv := NewServer()
+	defer srv.Close()
+
+	for _, name := range []string{driver.EnvConfigAPIToken, driver.EnvConfigUsernameLegacy, driver.EnvConfigPasswordLegacy} {
+		if err := os.Unsetenv(name); err != nil {
+			log.Fatalf("failed to unset %s: %s", name, err)
+		}
+	}
+	for name, value := range map[string]string{
+		driver.EnvConfigAPIURL:   srv.URL,
+		driver.EnvConfigUsername: Username,
+		driver.EnvConfigPassword: Password,
+	} {
+		if err := os.Setenv(name, value); err != nil {
+			log.Fatalf("failed to set %s: %s", name, err)
+		}
+	}
+
+	log.Printf("Running acceptance tests against UpCloud API emulator at %s", srv.URL)
+	return m.Run()
+}
+
+// Template returns the Packer template with communicator disabled and provisioners removed when acceptance tests are
+// run against the emulator, so that all build steps are run without connecting to the emulated server. Both JSON and
+// HCL templates formatted using `packer fmt` are supported.
+func Template(template string) string {
+	if !Emulated() {
+		return template
+	}
+	if strings.HasPrefix(strings.TrimSpace(template), "{") {
+		return jsonTemplate(template)
+	}
+	template = hclCommunicatorRegexp.ReplaceAllString(template, "")
+	template = hclProvisionerRegexp.ReplaceAllString(template, "")
+	return hclSourceRegexp.ReplaceAllString(template, "$1\n  communicator = \"none\"\n")
+}
+
+func jsonTemplate(template string) string {
+	var t map[string]any
+	if err := json.Unmarshal([]byte(template), &t); err != nil {
+		log.Fatalf("failed to parse JSON template: %s", err)
+	}
+	builders, _ := t["builders"].([]any)
+	for _, b := range builders {
+		if builder, ok := b.(map[string]any); ok {
+			builder["communicator"] = "none"
+		}
+	}
+	delete(t, "provisioners")
+	b, err := json.MarshalIndent(t, "", "    ")
+	if err != nil {
+		log.Fatalf("failed to encode JSON template: %s", err)
+	}
+	return string(b)
+}
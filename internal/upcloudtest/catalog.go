@@ -0,0 +1,127 @@
+package upcloudtest
+
+import (
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// Public storages available in all zones. Titles match the storages used by the acceptance tests.
+const (
+	RockyLinux9UUID       string = "01000000-0000-4000-8000-000150020100"
+	UbuntuServer2404UUID  string = "01000000-0000-4000-8000-000030240200"
+	Debian12UUID          string = "01000000-0000-4000-8000-000020070100"
+	Debian11UUID          string = "01000000-0000-4000-8000-000020060100"
+	WindowsServer2022UUID string = "01000000-0000-4000-8000-000010080300"
+	Debian12ISOUUID       string = "01000000-0000-4000-8000-000020070101"
+	templateTitleDebian   string = "Debian GNU/Linux"
+)
+
+// zoneDescriptions are the zones of the emulator.
+var zoneDescriptions = map[string]string{ //nolint:gochecknoglobals // constant map
+	"de-fra1": "Frankfurt #1",
+	"fi-hel1": "Helsinki #1",
+	"fi-hel2": "Helsinki #2",
+	"nl-ams1": "Amsterdam #1",
+	"pl-waw1": "Warsaw #1",
+	"uk-lon1": "London #1",
+	"us-nyc1": "New York #1",
+}
+
+// seed adds zones, plans, prices, account and public storages.
+func (s *Server) seed() {
+	for zone := range zoneDescriptions {
+		s.zones = append(s.zones, zone)
+	}
+	slices.Sort(s.zones)
+
+	s.plans = []upcloud.Plan{
+		{Name: "1xCPU-1GB", CoreNumber: 1, MemoryAmount: 1024, StorageSize: 25, StorageTier: upcloud.StorageTierMaxIOPS, PublicTrafficOut: 1024},
+		{Name: "1xCPU-2GB", CoreNumber: 1, MemoryAmount: 2048, StorageSize: 50, StorageTier: upcloud.StorageTierMaxIOPS, PublicTrafficOut: 2048},
+		{Name: "2xCPU-4GB", CoreNumber: 2, MemoryAmount: 4096, StorageSize: 80, StorageTier: upcloud.StorageTierMaxIOPS, PublicTrafficOut: 4096},
+		{Name: "4xCPU-8GB", CoreNumber: 4, MemoryAmount: 8192, StorageSize: 160, StorageTier: upcloud.StorageTierMaxIOPS, PublicTrafficOut: 5120},
+	}
+
+	s.prices = make(map[string]map[string]upcloud.Price, len(s.zones))
+	for _, zone := range s.zones {
+		s.prices[zone] = map[string]upcloud.Price{
+			"server_plan_1xCPU-1GB": {Amount: 1, Price: 0.744},
+			"server_plan_1xCPU-2GB": {Amount: 1, Price: 1.042},
+			"server_plan_2xCPU-4GB": {Amount: 1, Price: 2.083},
+			"server_plan_4xCPU-8GB": {Amount: 1, Price: 4.167},
+			"server_core":           {Amount: 1, Price: 0.6},
+			"server_memory":         {Amount: 256, Price: 0.1},
+			"storage_maxiops":       {Amount: 1, Price: 0.031},
+			"storage_standard":      {Amount: 1, Price: 0.006},
+			"storage_hdd":           {Amount: 1, Price: 0.006},
+			"storage_template":      {Amount: 1, Price: 0.031},
+		}
+	}
+
+	s.account = upcloud.Account{Credits: 100000, UserName: Username}
+
+	for _, st := range []upcloud.Storage{
+		{UUID: RockyLinux9UUID, Title: "Rocky Linux 9", Size: 4, TemplateType: upcloud.StorageTemplateTypeCloudInit},
+		{UUID: UbuntuServer2404UUID, Title: "Ubuntu Server 24.04 LTS (Noble Numbat)", Size: 4, TemplateType: upcloud.StorageTemplateTypeCloudInit},
+		{UUID: Debian12UUID, Title: templateTitleDebian + " 12 (Bookworm)", Size: 4, TemplateType: upcloud.StorageTemplateTypeCloudInit},
+		{UUID: Debian11UUID, Title: templateTitleDebian + " 11 (Bullseye)", Size: 4, TemplateType: upcloud.StorageTemplateTypeCloudInit},
+		{UUID: WindowsServer2022UUID, Title: "Windows Server 2022 Standard", Size: 25, TemplateType: upcloud.StorageTemplateTypeNative, License: 1.3},
+		{UUID: Debian12ISOUUID, Title: templateTitleDebian + " 12 (Bookworm) Installation CD", Size: 1, Type: upcloud.StorageTypeCDROM},
+	} {
+		if st.Type == "" {
+			st.Type = upcloud.StorageTypeTemplate
+		}
+		st.Access = upcloud.StorageAccessPublic
+		st.State = upcloud.StorageStateOnline
+		st.Tier = upcloud.StorageTierMaxIOPS
+		st.Zone = s.zones[0]
+		st.Created = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+		s.storages[st.UUID] = &storage{Storage: st}
+	}
+}
+
+func (s *Server) routeCatalog(mux *http.ServeMux) {
+	s.handle(mux, "GET /zone", s.getZones)
+	s.handle(mux, "GET /plan", s.getPlans)
+	s.handle(mux, "GET /price", s.getPrices)
+	s.handle(mux, "GET /account", s.getAccount)
+}
+
+func (s *Server) getZones(*http.Request) (any, error) {
+	zones := make([]map[string]any, 0, len(s.zones))
+	for _, zone := range s.zones {
+		zones = append(zones, map[string]any{"id": zone, "description": zoneDescriptions[zone], "public": yesNo(true)})
+	}
+	return map[string]any{"zones": map[string]any{"zone": zones}}, nil
+}
+
+func (s *Server) getPlans(*http.Request) (any, error) {
+	return map[string]any{"plans": map[string]any{"plan": s.plans}}, nil
+}
+
+func (s *Server) getPrices(*http.Request) (any, error) {
+	zones := make([]map[string]any, 0, len(s.zones))
+	for _, zone := range s.zones {
+		items := map[string]any{"name": zone}
+		for item, price := range s.prices[zone] {
+			items[item] = map[string]any{"amount": price.Amount, "price": price.Price}
+		}
+		zones = append(zones, items)
+	}
+	return map[string]any{"prices": map[string]any{"zone": zones}}, nil
+}
+
+func (s *Server) getAccount(*http.Request) (any, error) {
+	return map[string]any{"account": map[string]any{
+		"credits":         s.account.Credits,
+		"username":        s.account.UserName,
+		"resource_limits": map[string]any{},
+	}}, nil
+}
+
+// hasZone reports whether the zone exists. Caller must hold the lock.
+func (s *Server) hasZone(zone string) bool {
+	return slices.Contains(s.zones, zone)
+}
@@ -0,0 +1,296 @@
+package upcloudtest
+
+import (
+	"net"
+	"net/http"
+	"slices"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+type (
+	network struct {
+		uuid       string
+		name       string
+		zone       string
+		router     string
+		ipNetworks upcloud.IPNetworkSlice
+		labels     []upcloud.Label
+	}
+
+	// ipAddress is a floating IP address. Addresses of server interfaces are stored in the interfaces.
+	ipAddress struct {
+		address string
+		family  string
+		zone    string
+		mac     string
+	}
+)
+
+func (s *Server) routeNetworks(mux *http.ServeMux) {
+	s.handle(mux, "POST /network", s.createNetwork)
+	s.handle(mux, "POST /network/{$}", s.createNetwork)
+	s.handle(mux, "GET /network/{uuid}", s.getNetwork)
+	s.handle(mux, "DELETE /network/{uuid}", s.deleteNetwork)
+	s.handle(mux, "POST /ip_address", s.createIPAddress)
+	s.handle(mux, "GET /ip_address/{address}", s.getIPAddress)
+	s.handle(mux, "PATCH /ip_address/{address}", s.modifyIPAddress)
+	s.handle(mux, "DELETE /ip_address/{address}", s.deleteIPAddress)
+}
+
+func (s *Server) createNetwork(r *http.Request) (any, error) {
+	var body struct {
+		Network struct {
+			Name       string                 `json:"name"`
+			Zone       string                 `json:"zone"`
+			Router     string                 `json:"router"`
+			IPNetworks upcloud.IPNetworkSlice `json:"ip_networks"`
+			Labels     []upcloud.Label        `json:"labels"`
+		} `json:"network"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.Network
+	switch {
+	case !s.hasZone(req.Zone):
+		return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Zone %q does not exist.", req.Zone)
+	case req.Name == "":
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Network name is missing.")
+	case len(req.IPNetworks) != 1:
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Network must have exactly one IP network.")
+	}
+	ip, ipNet, err := net.ParseCIDR(req.IPNetworks[0].Address)
+	if err != nil || ip.To4() == nil {
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Emulator supports only IPv4 networks, got %q.", req.IPNetworks[0].Address)
+	}
+
+	n := &network{
+		uuid:       s.uuid(prefixNetwork),
+		name:       req.Name,
+		zone:       req.Zone,
+		router:     req.Router,
+		ipNetworks: req.IPNetworks,
+		labels:     req.Labels,
+	}
+	n.ipNetworks[0].Address = ipNet.String()
+	n.ipNetworks[0].Family = upcloud.IPAddressFamilyIPv4
+	s.networks[n.uuid] = n
+	return map[string]any{"network": s.networkJSON(n)}, nil
+}
+
+func (s *Server) getNetwork(r *http.Request) (any, error) {
+	n, err := s.network(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"network": s.networkJSON(n)}, nil
+}
+
+func (s *Server) deleteNetwork(r *http.Request) (any, error) {
+	n, err := s.network(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if servers := s.networkServers(n.uuid); len(servers) > 0 {
+		return nil, conflict(upcloud.ErrCodeNetworkForbidden, "Network %s has servers attached.", n.uuid)
+	}
+	delete(s.networks, n.uuid)
+	return nil, nil //nolint:nilnil // no content
+}
+
+func (s *Server) createIPAddress(r *http.Request) (any, error) {
+	var body struct {
+		IPAddress struct {
+			Access   string          `json:"access"`
+			Family   string          `json:"family"`
+			Floating upcloud.Boolean `json:"floating"`
+			MAC      string          `json:"mac"`
+			Zone     string          `json:"zone"`
+		} `json:"ip_address"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.IPAddress
+	switch {
+	case !s.hasZone(req.Zone):
+		return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Zone %q does not exist.", req.Zone)
+	case !req.Floating.Bool() || req.Access != upcloud.IPAddressAccessPublic || req.Family != upcloud.IPAddressFamilyIPv4:
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Emulator supports only floating public IPv4 addresses.")
+	}
+	if err := s.checkMAC(req.MAC, req.Zone); err != nil {
+		return nil, err
+	}
+
+	ip := &ipAddress{
+		address: s.address(upcloud.NetworkTypePublic, req.Family, ""),
+		family:  req.Family,
+		zone:    req.Zone,
+		mac:     req.MAC,
+	}
+	s.ipAddresses[ip.address] = ip
+	return map[string]any{"ip_address": s.ipAddressJSON(ip)}, nil
+}
+
+func (s *Server) getIPAddress(r *http.Request) (any, error) {
+	ip, err := s.ipAddress(r.PathValue("address"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"ip_address": s.ipAddressJSON(ip)}, nil
+}
+
+// modifyIPAddress attaches the floating IP address to the interface with the MAC address, or detaches it if MAC is null.
+func (s *Server) modifyIPAddress(r *http.Request) (any, error) {
+	ip, err := s.ipAddress(r.PathValue("address"))
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		IPAddress struct {
+			MAC *string `json:"mac"`
+		} `json:"ip_address"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	mac := ""
+	if body.IPAddress.MAC != nil {
+		mac = *body.IPAddress.MAC
+	}
+	if err := s.checkMAC(mac, ip.zone); err != nil {
+		return nil, err
+	}
+	ip.mac = mac
+	return map[string]any{"ip_address": s.ipAddressJSON(ip)}, nil
+}
+
+func (s *Server) deleteIPAddress(r *http.Request) (any, error) {
+	ip, err := s.ipAddress(r.PathValue("address"))
+	if err != nil {
+		return nil, err
+	}
+	delete(s.ipAddresses, ip.address)
+	return nil, nil //nolint:nilnil // no content
+}
+
+// checkMAC returns an error unless the MAC address is empty or belongs to a public interface in the zone. Caller must
+// hold the lock.
+func (s *Server) checkMAC(mac, zone string) error {
+	if mac == "" {
+		return nil
+	}
+	if srv, iface := s.serverInterfaceByMAC(mac); srv != nil && srv.zone == zone && iface.ifaceType == upcloud.NetworkTypePublic {
+		return nil
+	}
+	return badRequest(upcloud.ErrCodeInvalidRequest, "MAC address %s does not belong to a public interface in zone %s.", mac, zone)
+}
+
+// serverInterfaceByMAC returns the server and its interface with the MAC address. Caller must hold the lock.
+func (s *Server) serverInterfaceByMAC(mac string) (*server, *serverInterface) {
+	for _, srv := range s.servers {
+		for i := range srv.interfaces {
+			if srv.interfaces[i].mac == mac {
+				return srv, &srv.interfaces[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
+// network returns the network or not found error. Caller must hold the lock.
+func (s *Server) network(uuid string) (*network, error) {
+	n, ok := s.networks[uuid]
+	if !ok {
+		return nil, notFound(upcloud.ErrCodeNetworkNotFound, "Network %s does not exist.", uuid)
+	}
+	return n, nil
+}
+
+// ipAddress returns the floating IP address or not found error. Caller must hold the lock.
+func (s *Server) ipAddress(address string) (*ipAddress, error) {
+	ip, ok := s.ipAddresses[address]
+	if !ok {
+		return nil, notFound(upcloud.ErrCodeIpAddressNotFound, "IP address %s does not exist.", address)
+	}
+	return ip, nil
+}
+
+// sortedIPAddresses returns floating IP addresses in a stable order. Caller must hold the lock.
+func (s *Server) sortedIPAddresses() []string {
+	addresses := make([]string, 0, len(s.ipAddresses))
+	for address := range s.ipAddresses {
+		addresses = append(addresses, address)
+	}
+	slices.Sort(addresses)
+	return addresses
+}
+
+// networkServers returns the servers attached to the network. Caller must hold the lock.
+func (s *Server) networkServers(networkUUID string) []map[string]any {
+	servers := make([]map[string]any, 0)
+	for _, uuid := range s.sortedServers() {
+		srv := s.servers[uuid]
+		if slices.ContainsFunc(srv.interfaces, func(iface serverInterface) bool { return iface.network == networkUUID }) {
+			servers = append(servers, map[string]any{"uuid": srv.uuid, "title": srv.title})
+		}
+	}
+	return servers
+}
+
+// networkJSON returns the network with its servers. Caller must hold the lock.
+func (s *Server) networkJSON(n *network) map[string]any {
+	ipNetworks := make([]map[string]any, 0, len(n.ipNetworks))
+	for _, ipNet := range n.ipNetworks {
+		ipNetworks = append(ipNetworks, map[string]any{
+			"address": ipNet.Address,
+			"dhcp":    yesNo(ipNet.DHCP.Bool()),
+			"family":  ipNet.Family,
+			"gateway": ipNet.Gateway,
+		})
+	}
+	labels := n.labels
+	if labels == nil {
+		labels = []upcloud.Label{}
+	}
+	return map[string]any{
+		"ip_networks": map[string]any{"ip_network": ipNetworks},
+		"labels":      labels,
+		"name":        n.name,
+		"router":      n.router,
+		"servers":     map[string]any{"server": s.networkServers(n.uuid)},
+		"type":        upcloud.NetworkTypePrivate,
+		"uuid":        n.uuid,
+		"zone":        n.zone,
+	}
+}
+
+// ipAddressJSON returns the floating IP address with the server it is attached to. Caller must hold the lock.
+func (s *Server) ipAddressJSON(ip *ipAddress) map[string]any {
+	serverUUID := ""
+	if srv, _ := s.serverInterfaceByMAC(ip.mac); srv != nil {
+		serverUUID = srv.uuid
+	}
+	return map[string]any{
+		"access":         upcloud.IPAddressAccessPublic,
+		"address":        ip.address,
+		"family":         ip.family,
+		"floating":       yesNo(true),
+		"mac":            ip.mac,
+		"part_of_plan":   yesNo(false),
+		"release_policy": "keep",
+		"server":         serverUUID,
+		"zone":           ip.zone,
+	}
+}
+
+// address returns the nth host address of the network.
+func (n *network) address(host int) string {
+	_, ipNet, _ := net.ParseCIDR(n.ipNetworks[0].Address)
+	ip := ipNet.IP.To4()
+	ones, bits := ipNet.Mask.Size()
+	size := 1 << (bits - ones)
+	offset := host%max(size-2, 1) + 1
+	return net.IPv4(ip[0], ip[1], ip[2]+byte(offset>>8), ip[3]+byte(offset)).String() //nolint:gosec // offset is within the network
+}
@@ -0,0 +1,556 @@
+package upcloudtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+const (
+	customPlan      string = "custom"
+	defaultUsername string = "root"
+
+	// errCodeMetadataDisabled is returned when a server is created from a cloud-init template without metadata service.
+	errCodeMetadataDisabled string = "METADATA_DISABLED_ON_CLOUD-INIT"
+	errCodePlanInvalid      string = "PLAN_INVALID"
+
+	// powerStateModule is the cloud-init module which powers off the server when cloud-init is done.
+	powerStateModule string = "power_state"
+)
+
+type (
+	server struct {
+		uuid         string
+		title        string
+		hostname     string
+		zone         string
+		plan         string
+		coreNumber   int
+		memoryAmount int
+		state        string
+		pending      transitions
+		labels       []upcloud.Label
+		metadata     bool
+		firewall     string
+		rules        json.RawMessage
+		bootOrder    string
+		remoteAccess bool
+		devices      []serverDevice
+		interfaces   []serverInterface
+	}
+
+	serverDevice struct {
+		address    string
+		storage    string
+		deviceType string
+	}
+
+	serverInterface struct {
+		index     int
+		mac       string
+		network   string
+		ifaceType string
+		addresses []request.CreateServerIPAddress
+	}
+
+	// createServerBody is the create server request. Slices wrapped into objects by the API are unwrapped here.
+	createServerBody struct {
+		Server struct {
+			Zone                string              `json:"zone"`
+			Title               string              `json:"title"`
+			Hostname            string              `json:"hostname"`
+			Plan                string              `json:"plan"`
+			CoreNumber          int                 `json:"core_number"`
+			MemoryAmount        int                 `json:"memory_amount"`
+			BootOrder           string              `json:"boot_order"`
+			Metadata            upcloud.Boolean     `json:"metadata"`
+			UserData            string              `json:"user_data"`
+			RemoteAccessEnabled upcloud.Boolean     `json:"remote_access_enabled"`
+			Labels              *upcloud.LabelSlice `json:"labels"`
+			LoginUser           *struct {
+				CreatePassword string              `json:"create_password"`
+				Username       string              `json:"username"`
+				SSHKeys        request.SSHKeySlice `json:"ssh_keys"`
+			} `json:"login_user"`
+			StorageDevices struct {
+				StorageDevice []request.CreateServerStorageDevice `json:"storage_device"`
+			} `json:"storage_devices"`
+			Networking *struct {
+				Interfaces struct {
+					Interface []struct {
+						Index       int    `json:"index"`
+						Type        string `json:"type"`
+						Network     string `json:"network"`
+						IPAddresses struct {
+							IPAddress []request.CreateServerIPAddress `json:"ip_address"`
+						} `json:"ip_addresses"`
+					} `json:"interface"`
+				} `json:"interfaces"`
+			} `json:"networking"`
+		} `json:"server"`
+	}
+)
+
+func (s *Server) routeServers(mux *http.ServeMux) {
+	s.handle(mux, "GET /server", s.listServers)
+	s.handle(mux, "GET /server/{$}", s.listServers)
+	s.handle(mux, "GET /server/{uuid}", s.getServer)
+	s.handle(mux, "POST /server", s.createServer)
+	s.handle(mux, "PUT /server/{uuid}", s.modifyServer)
+	s.handle(mux, "DELETE /server/{uuid}", s.deleteServer)
+	s.handle(mux, "DELETE /server/{uuid}/{$}", s.deleteServer)
+	s.handle(mux, "POST /server/{uuid}/start", s.startServer)
+	s.handle(mux, "POST /server/{uuid}/stop", s.stopServer)
+	s.handle(mux, "POST /server/{uuid}/cdrom/eject", s.ejectCDROM)
+	s.handle(mux, "PUT /server/{uuid}/firewall_rule", s.replaceFirewallRules)
+}
+
+// listServers lists servers having all labels given in the label query parameters, e.g. label=key=value or label=key.
+func (s *Server) listServers(r *http.Request) (any, error) {
+	servers := make([]map[string]any, 0, len(s.servers))
+	for _, uuid := range s.sortedServers() {
+		srv := s.servers[uuid]
+		if srv.hasLabels(r.URL.Query()["label"]) {
+			servers = append(servers, srv.json())
+		}
+	}
+	return map[string]any{"servers": map[string]any{"server": servers}}, nil
+}
+
+func (s *Server) getServer(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	return map[string]any{"server": s.serverDetails(srv)}, nil
+}
+
+//nolint:funlen // validation follows the order of the request fields
+func (s *Server) createServer(r *http.Request) (any, error) {
+	var body createServerBody
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.Server
+
+	srv := &server{
+		title:        req.Title,
+		hostname:     req.Hostname,
+		zone:         req.Zone,
+		plan:         req.Plan,
+		coreNumber:   req.CoreNumber,
+		memoryAmount: req.MemoryAmount,
+		metadata:     req.Metadata.Bool(),
+		firewall:     "off",
+		bootOrder:    req.BootOrder,
+		remoteAccess: req.RemoteAccessEnabled.Bool(),
+	}
+	if req.Labels != nil {
+		srv.labels = *req.Labels
+	}
+	switch {
+	case !s.hasZone(req.Zone):
+		return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Zone %q does not exist.", req.Zone)
+	case req.Title == "" || req.Hostname == "":
+		return nil, badRequest(upcloud.ErrCodeTitleInvalid, "Server title and hostname are required.")
+	case len(req.StorageDevices.StorageDevice) == 0:
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Server has no storage devices.")
+	}
+	if srv.plan == "" {
+		srv.plan = s.plans[0].Name
+	}
+	if srv.plan == customPlan {
+		if srv.coreNumber <= 0 || srv.memoryAmount <= 0 {
+			return nil, badRequest(errCodePlanInvalid, "Custom plan requires core number and memory amount.")
+		}
+	} else {
+		i := slices.IndexFunc(s.plans, func(p upcloud.Plan) bool { return p.Name == srv.plan })
+		if i < 0 {
+			return nil, badRequest(errCodePlanInvalid, "Plan %q does not exist.", srv.plan)
+		}
+		srv.coreNumber, srv.memoryAmount = s.plans[i].CoreNumber, s.plans[i].MemoryAmount
+	}
+	if srv.bootOrder == "" {
+		srv.bootOrder = "disk"
+	}
+
+	// devices are validated before any storage is created so that failed requests do not leave storages behind
+	disks := make([]upcloud.Storage, 0, len(req.StorageDevices.StorageDevice))
+	for _, device := range req.StorageDevices.StorageDevice {
+		switch device.Action {
+		case request.CreateServerStorageDeviceActionClone:
+			source, err := s.storage(device.Storage)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case source.Access == upcloud.StorageAccessPrivate && source.Zone != req.Zone:
+				return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Storage %s is in zone %s.", source.UUID, source.Zone)
+			case device.Size != 0 && device.Size < source.Size:
+				return nil, badRequest(upcloud.ErrCodeSizeInvalid, "Storage size must be at least %d.", source.Size)
+			case source.TemplateType == upcloud.StorageTemplateTypeCloudInit && !srv.metadata:
+				return nil, badRequest(errCodeMetadataDisabled, "Metadata service must be enabled for cloud-init templates.")
+			}
+			disk := upcloud.Storage{Title: device.Title, Zone: req.Zone, Size: max(device.Size, source.Size), Tier: device.Tier,
+				Encrypted: device.Encrypted, TemplateType: source.TemplateType}
+			disks = append(disks, disk)
+		case request.CreateServerStorageDeviceActionCreate:
+			if device.Size < storageMinSize || device.Size > storageMaxSize {
+				return nil, badRequest(upcloud.ErrCodeSizeInvalid, "Storage size must be between %d and %d.", storageMinSize, storageMaxSize)
+			}
+			disks = append(disks, upcloud.Storage{Title: device.Title, Zone: req.Zone, Size: device.Size, Tier: device.Tier, Encrypted: device.Encrypted})
+		case request.CreateServerStorageDeviceActionAttach:
+			st, err := s.storage(device.Storage)
+			if err != nil {
+				return nil, err
+			}
+			if st.Type != upcloud.StorageTypeCDROM {
+				return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Emulator supports attaching only CD-ROM storages.")
+			}
+			disks = append(disks, st.Storage)
+		default:
+			return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Storage device action %q is not supported.", device.Action)
+		}
+	}
+
+	type ifaceRequest = struct {
+		Type        string
+		Network     string
+		IPAddresses []request.CreateServerIPAddress
+	}
+	ifaces := []ifaceRequest{
+		{Type: upcloud.NetworkTypePublic, IPAddresses: []request.CreateServerIPAddress{{Family: upcloud.IPAddressFamilyIPv4}}},
+		{Type: upcloud.NetworkTypeUtility, IPAddresses: []request.CreateServerIPAddress{{Family: upcloud.IPAddressFamilyIPv4}}},
+	}
+	if req.Networking != nil && len(req.Networking.Interfaces.Interface) > 0 {
+		ifaces = ifaces[:0]
+		for _, iface := range req.Networking.Interfaces.Interface {
+			ifaces = append(ifaces, ifaceRequest{Type: iface.Type, Network: iface.Network, IPAddresses: iface.IPAddresses.IPAddress})
+		}
+	}
+	for _, iface := range ifaces {
+		if iface.Type != upcloud.NetworkTypePrivate {
+			continue
+		}
+		n, ok := s.networks[iface.Network]
+		if !ok {
+			return nil, notFound(upcloud.ErrCodeNetworkNotFound, "Network %s does not exist.", iface.Network)
+		}
+		if n.zone != req.Zone {
+			return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Network %s is in zone %s.", n.uuid, n.zone)
+		}
+	}
+
+	srv.uuid = s.uuid(prefixServer)
+	for i, disk := range disks {
+		var device serverDevice
+		if disk.Type == upcloud.StorageTypeCDROM {
+			device = serverDevice{address: "ide:0:0", storage: disk.UUID, deviceType: upcloud.StorageTypeCDROM}
+		} else {
+			device = serverDevice{address: fmt.Sprintf("virtio:%d", i), storage: s.addStorage(disk).UUID, deviceType: upcloud.StorageTypeDisk}
+		}
+		srv.devices = append(srv.devices, device)
+	}
+	for i, iface := range ifaces {
+		srv.interfaces = append(srv.interfaces, s.serverInterface(i+1, iface.Type, iface.Network, iface.IPAddresses))
+	}
+
+	srv.state = upcloud.ServerStateMaintenance
+	s.schedule(&srv.pending, upcloud.ServerStateStarted)
+	// servers configured to power off after cloud-init stop by themselves
+	if strings.Contains(req.UserData, powerStateModule) {
+		srv.pending = append(srv.pending, transition{at: srv.pending[0].at.Add(s.CloudInitDuration), state: upcloud.ServerStateStopped})
+	}
+	s.servers[srv.uuid] = srv
+
+	details := s.serverDetails(srv)
+	details["username"] = defaultUsername
+	if req.LoginUser != nil {
+		if req.LoginUser.Username != "" {
+			details["username"] = req.LoginUser.Username
+		}
+		if req.LoginUser.CreatePassword == "yes" {
+			details["password"] = "emulator-" + srv.uuid[:8]
+		}
+	}
+	return map[string]any{"server": details}, nil
+}
+
+func (s *Server) modifyServer(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Server struct {
+			Title    string              `json:"title"`
+			Firewall string              `json:"firewall"`
+			Labels   *upcloud.LabelSlice `json:"labels"`
+		} `json:"server"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	if body.Server.Title != "" {
+		srv.title = body.Server.Title
+	}
+	if body.Server.Firewall != "" {
+		srv.firewall = body.Server.Firewall
+	}
+	if body.Server.Labels != nil {
+		srv.labels = *body.Server.Labels
+	}
+	return map[string]any{"server": s.serverDetails(srv)}, nil
+}
+
+// deleteServer deletes a stopped server. Disks are deleted too if the storages query parameter is set.
+func (s *Server) deleteServer(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if srv.state != upcloud.ServerStateStopped {
+		return nil, conflict(upcloud.ErrCodeServerStateIllegal, "Server %s must be stopped.", srv.uuid)
+	}
+	if r.URL.Query().Get("storages") == "1" {
+		for _, device := range srv.devices {
+			if device.deviceType == upcloud.StorageTypeDisk {
+				delete(s.storages, device.storage)
+			}
+		}
+	}
+	for _, iface := range srv.interfaces {
+		for _, ip := range s.ipAddresses {
+			if ip.mac == iface.mac {
+				ip.mac = ""
+			}
+		}
+	}
+	delete(s.servers, srv.uuid)
+	return nil, nil //nolint:nilnil // no content
+}
+
+func (s *Server) startServer(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if srv.state != upcloud.ServerStateStopped {
+		return nil, conflict(upcloud.ErrCodeServerStateIllegal, "Server %s is in state %s.", srv.uuid, srv.state)
+	}
+	srv.state = upcloud.ServerStateMaintenance
+	s.schedule(&srv.pending, upcloud.ServerStateStarted)
+	return map[string]any{"server": s.serverDetails(srv)}, nil
+}
+
+// stopServer stops the server after StateDelay. Server remains started until then.
+func (s *Server) stopServer(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if srv.state != upcloud.ServerStateStarted {
+		return nil, conflict(upcloud.ErrCodeServerStateIllegal, "Server %s is in state %s.", srv.uuid, srv.state)
+	}
+	s.schedule(&srv.pending, upcloud.ServerStateStopped)
+	return map[string]any{"server": s.serverDetails(srv)}, nil
+}
+
+func (s *Server) ejectCDROM(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	srv.devices = slices.DeleteFunc(srv.devices, func(d serverDevice) bool {
+		return d.deviceType == upcloud.StorageTypeCDROM
+	})
+	return map[string]any{"server": s.serverDetails(srv)}, nil
+}
+
+// replaceFirewallRules stores the rules as is. Emulator does not filter traffic.
+func (s *Server) replaceFirewallRules(r *http.Request) (any, error) {
+	srv, err := s.server(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		FirewallRules json.RawMessage `json:"firewall_rules"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	srv.rules = body.FirewallRules
+	return nil, nil //nolint:nilnil // no content
+}
+
+// server returns the server or not found error. Caller must hold the lock.
+func (s *Server) server(uuid string) (*server, error) {
+	srv, ok := s.servers[uuid]
+	if !ok {
+		return nil, notFound(upcloud.ErrCodeServerNotFound, "Server %s does not exist.", uuid)
+	}
+	return srv, nil
+}
+
+// attachedServer returns the server the storage is attached to, or nil. Caller must hold the lock.
+func (s *Server) attachedServer(storageUUID string) *server {
+	for _, srv := range s.servers {
+		for _, device := range srv.devices {
+			if device.storage == storageUUID && device.deviceType == upcloud.StorageTypeDisk {
+				return srv
+			}
+		}
+	}
+	return nil
+}
+
+// sortedServers returns server UUIDs in creation order. Caller must hold the lock.
+func (s *Server) sortedServers() []string {
+	uuids := make([]string, 0, len(s.servers))
+	for uuid := range s.servers {
+		uuids = append(uuids, uuid)
+	}
+	slices.Sort(uuids)
+	return uuids
+}
+
+// serverInterface assigns MAC and IP addresses to the network interface. Caller must hold the lock.
+func (s *Server) serverInterface(index int, ifaceType, networkUUID string, addresses []request.CreateServerIPAddress) serverInterface {
+	s.seq++
+	iface := serverInterface{
+		index:     index,
+		mac:       fmt.Sprintf("ee:1b:db:ca:%02x:%02x", (s.seq>>8)%256, s.seq%256),
+		network:   networkUUID,
+		ifaceType: ifaceType,
+	}
+	for _, addr := range addresses {
+		if addr.Address == "" {
+			addr.Address = s.address(ifaceType, addr.Family, networkUUID)
+		}
+		iface.addresses = append(iface.addresses, addr)
+	}
+	return iface
+}
+
+// address returns a new address for the interface. Caller must hold the lock.
+func (s *Server) address(ifaceType, family, networkUUID string) string {
+	s.seq++
+	host := s.seq%254 + 1
+	switch {
+	case family == upcloud.IPAddressFamilyIPv6:
+		return fmt.Sprintf("2001:db8::%x", s.seq)
+	case ifaceType == upcloud.NetworkTypePublic:
+		return fmt.Sprintf("198.51.100.%d", host)
+	case ifaceType == upcloud.NetworkTypePrivate:
+		return s.networks[networkUUID].address(host)
+	default:
+		return fmt.Sprintf("10.%d.%d.%d", s.seq/65536%256, s.seq/256%256, host)
+	}
+}
+
+// hasLabels reports whether the server has all labels given as key=value or key.
+func (srv *server) hasLabels(filters []string) bool {
+	for _, filter := range filters {
+		key, value, hasValue := strings.Cut(filter, "=")
+		if !slices.ContainsFunc(srv.labels, func(l upcloud.Label) bool {
+			return l.Key == key && (!hasValue || l.Value == value)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// json returns the server as it is listed by the API.
+func (srv *server) json() map[string]any {
+	labels := srv.labels
+	if labels == nil {
+		labels = []upcloud.Label{}
+	}
+	return map[string]any{
+		"core_number":   fmt.Sprint(srv.coreNumber),
+		"hostname":      srv.hostname,
+		"labels":        map[string]any{"label": labels},
+		"license":       0,
+		"memory_amount": fmt.Sprint(srv.memoryAmount),
+		"plan":          srv.plan,
+		"progress":      "0",
+		"state":         srv.state,
+		"tags":          map[string]any{"tag": []string{}},
+		"title":         srv.title,
+		"uuid":          srv.uuid,
+		"zone":          srv.zone,
+	}
+}
+
+// serverDetails returns the server with its storage devices and network interfaces. Caller must hold the lock.
+func (s *Server) serverDetails(srv *server) map[string]any {
+	devices := make([]map[string]any, 0, len(srv.devices))
+	for i, device := range srv.devices {
+		d := map[string]any{
+			"address":           device.address,
+			"boot_disk":         "0",
+			"part_of_plan":      "no",
+			"storage":           device.storage,
+			"storage_encrypted": yesNo(false),
+			"type":              device.deviceType,
+		}
+		if i == 0 {
+			d["boot_disk"] = "1"
+		}
+		if st, ok := s.storages[device.storage]; ok {
+			d["storage_encrypted"] = yesNo(st.Encrypted.Bool())
+			d["storage_size"] = st.Size
+			d["storage_tier"] = st.Tier
+			d["storage_title"] = st.Title
+		}
+		devices = append(devices, d)
+	}
+
+	interfaces := make([]map[string]any, 0, len(srv.interfaces))
+	addresses := make([]map[string]any, 0)
+	for _, iface := range srv.interfaces {
+		ifaceAddresses := make([]map[string]any, 0, len(iface.addresses))
+		for _, addr := range iface.addresses {
+			ifaceAddresses = append(ifaceAddresses, map[string]any{"address": addr.Address, "family": addr.Family, "floating": yesNo(false)})
+			addresses = append(addresses, map[string]any{"access": iface.ifaceType, "address": addr.Address, "family": addr.Family})
+		}
+		for _, ip := range s.sortedIPAddresses() {
+			if addr := s.ipAddresses[ip]; addr.mac == iface.mac {
+				ifaceAddresses = append(ifaceAddresses, map[string]any{"address": addr.address, "family": addr.family, "floating": yesNo(true)})
+				addresses = append(addresses, map[string]any{"access": iface.ifaceType, "address": addr.address, "family": addr.family})
+			}
+		}
+		interfaces = append(interfaces, map[string]any{
+			"index":               iface.index,
+			"ip_addresses":        map[string]any{"ip_address": ifaceAddresses},
+			"mac":                 iface.mac,
+			"network":             iface.network,
+			"type":                iface.ifaceType,
+			"bootable":            yesNo(false),
+			"source_ip_filtering": yesNo(true),
+		})
+	}
+
+	details := srv.json()
+	details["boot_order"] = srv.bootOrder
+	details["firewall"] = srv.firewall
+	details["host"] = 1
+	details["ip_addresses"] = map[string]any{"ip_address": addresses}
+	details["metadata"] = yesNo(srv.metadata)
+	details["networking"] = map[string]any{"interfaces": map[string]any{"interface": interfaces}}
+	details["remote_access_enabled"] = yesNo(srv.remoteAccess)
+	details["storage_devices"] = map[string]any{"storage_device": devices}
+	details["timezone"] = "UTC"
+	if srv.remoteAccess {
+		details["remote_access_type"] = upcloud.RemoteAccessTypeVNC
+		details["remote_access_host"] = "127.0.0.1"
+		details["remote_access_port"] = "5900"
+	}
+	return details
+}
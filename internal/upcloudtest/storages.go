@@ -0,0 +1,482 @@
+package upcloudtest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/md5" //nolint:gosec // API reports MD5 checksum of the import
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+const (
+	storageMinSize int = 1
+	storageMaxSize int = 4096
+	gigabyte       int = 1 << 30
+
+	importSourceDirectUpload string = "direct_upload"
+	contentTypeRaw           string = "application/octet-stream"
+	contentTypeGzip          string = "application/gzip"
+)
+
+type (
+	storage struct {
+		upcloud.Storage
+
+		pending transitions
+		imp     *storageImport
+	}
+
+	storageImport struct {
+		upcloud.StorageImportDetails
+
+		pending transitions
+	}
+
+	// storageBody is the storage object of create, modify, clone and templatize requests.
+	storageBody struct {
+		Storage struct {
+			Title     string           `json:"title"`
+			Zone      string           `json:"zone"`
+			Size      int              `json:"size,string"`
+			Tier      string           `json:"tier"`
+			Encrypted upcloud.Boolean  `json:"encrypted"`
+			Labels    *[]upcloud.Label `json:"labels"`
+		} `json:"storage"`
+	}
+)
+
+func (s *Server) routeStorages(mux *http.ServeMux) {
+	s.handle(mux, "GET /storage", s.listStorages)
+	s.handle(mux, "GET /storage/{uuid}", s.getStorage)
+	s.handle(mux, "GET /storage/{access}/{type}", s.listStorages)
+	s.handle(mux, "POST /storage", s.createStorage)
+	s.handle(mux, "PUT /storage/{uuid}", s.modifyStorage)
+	s.handle(mux, "DELETE /storage/{uuid}", s.deleteStorage)
+	s.handle(mux, "POST /storage/{uuid}/clone", s.cloneStorage)
+	s.handle(mux, "POST /storage/{uuid}/templatize", s.templatizeStorage)
+	s.handle(mux, "POST /storage/{uuid}/import", s.createStorageImport)
+	s.handle(mux, "GET /storage/{uuid}/import", s.getStorageImport)
+	mux.HandleFunc("PUT /uploader/session/{uuid}", s.upload)
+}
+
+// listStorages lists storages filtered by access and type. Both filters are optional path segments.
+func (s *Server) listStorages(r *http.Request) (any, error) {
+	var access, storageType string
+	for _, filter := range []string{r.PathValue("access"), r.PathValue("type"), r.PathValue("uuid")} {
+		switch filter {
+		case "":
+		case upcloud.StorageAccessPublic, upcloud.StorageAccessPrivate:
+			access = filter
+		default:
+			storageType = filter
+		}
+	}
+
+	storages := make([]map[string]any, 0, len(s.storages))
+	for _, uuid := range s.sortedStorages() {
+		st := s.storages[uuid]
+		if (access == "" || st.Access == access) && (storageType == "" || st.Type == storageType) {
+			storages = append(storages, st.json())
+		}
+	}
+	return map[string]any{"storages": map[string]any{"storage": storages}}, nil
+}
+
+// getStorage returns storage details, or lists storages if the path segment is an access or type filter.
+func (s *Server) getStorage(r *http.Request) (any, error) {
+	switch r.PathValue("uuid") {
+	case upcloud.StorageAccessPublic, upcloud.StorageAccessPrivate,
+		upcloud.StorageTypeNormal, upcloud.StorageTypeBackup, upcloud.StorageTypeCDROM, upcloud.StorageTypeTemplate, "favorite":
+		return s.listStorages(r)
+	}
+	st, err := s.storage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	return s.storageDetails(st), nil
+}
+
+func (s *Server) createStorage(r *http.Request) (any, error) {
+	var body storageBody
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.Storage
+	switch {
+	case !s.hasZone(req.Zone):
+		return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Zone %q does not exist.", req.Zone)
+	case req.Title == "":
+		return nil, badRequest(upcloud.ErrCodeTitleInvalid, "Storage title is missing.")
+	case req.Size < storageMinSize || req.Size > storageMaxSize:
+		return nil, badRequest(upcloud.ErrCodeSizeInvalid, "Storage size must be between %d and %d.", storageMinSize, storageMaxSize)
+	}
+
+	st := s.addStorage(upcloud.Storage{
+		Title:     req.Title,
+		Zone:      req.Zone,
+		Size:      req.Size,
+		Tier:      req.Tier,
+		Encrypted: req.Encrypted,
+	})
+	if req.Labels != nil {
+		st.Labels = *req.Labels
+	}
+	return s.storageDetails(st), nil
+}
+
+func (s *Server) modifyStorage(r *http.Request) (any, error) {
+	st, err := s.privateStorage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body storageBody
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.Storage
+	if req.Size != 0 {
+		if req.Size < st.Size || req.Size > storageMaxSize {
+			return nil, badRequest(upcloud.ErrCodeSizeInvalid, "Storage size must be between %d and %d.", st.Size, storageMaxSize)
+		}
+		st.Size = req.Size
+	}
+	if req.Title != "" {
+		st.Title = req.Title
+	}
+	if req.Labels != nil {
+		st.Labels = *req.Labels
+	}
+	return s.storageDetails(st), nil
+}
+
+func (s *Server) deleteStorage(r *http.Request) (any, error) {
+	st, err := s.privateStorage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if srv := s.attachedServer(st.UUID); srv != nil {
+		return nil, conflict(upcloud.ErrCodeStorageAttached, "Storage %s is attached to server %s.", st.UUID, srv.uuid)
+	}
+	if st.State != upcloud.StorageStateOnline {
+		return nil, conflict(upcloud.ErrCodeStorageStateIllegal, "Storage %s is in state %s.", st.UUID, st.State)
+	}
+	delete(s.storages, st.UUID)
+	return nil, nil //nolint:nilnil // no content
+}
+
+func (s *Server) cloneStorage(r *http.Request) (any, error) {
+	source, err := s.storage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body storageBody
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	req := body.Storage
+	switch {
+	case !s.hasZone(req.Zone):
+		return nil, badRequest(upcloud.ErrCodeZoneInvalid, "Zone %q does not exist.", req.Zone)
+	case req.Title == "":
+		return nil, badRequest(upcloud.ErrCodeTitleInvalid, "Storage title is missing.")
+	case source.State != upcloud.StorageStateOnline && source.State != upcloud.StorageStateCloning:
+		return nil, conflict(upcloud.ErrCodeStorageStateIllegal, "Storage %s is in state %s.", source.UUID, source.State)
+	}
+
+	tier := req.Tier
+	if tier == "" {
+		tier = source.Tier
+	}
+	clone := s.addStorage(upcloud.Storage{
+		Title:        req.Title,
+		Zone:         req.Zone,
+		Size:         source.Size,
+		Tier:         tier,
+		Encrypted:    req.Encrypted,
+		TemplateType: source.TemplateType,
+	})
+	if source.Access == upcloud.StorageAccessPrivate {
+		source.State = upcloud.StorageStateCloning
+		s.schedule(&source.pending, upcloud.StorageStateOnline)
+	}
+	return s.storageDetails(clone), nil
+}
+
+func (s *Server) templatizeStorage(r *http.Request) (any, error) {
+	source, err := s.privateStorage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body storageBody
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	switch srv := s.attachedServer(source.UUID); {
+	case body.Storage.Title == "":
+		return nil, badRequest(upcloud.ErrCodeTitleInvalid, "Template title is missing.")
+	case source.Type != upcloud.StorageTypeNormal:
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Storage %s is not a normal storage.", source.UUID)
+	case srv != nil && srv.state != upcloud.ServerStateStopped:
+		return nil, conflict(upcloud.ErrCodeServerStateIllegal, "Server %s must be stopped.", srv.uuid)
+	case source.State != upcloud.StorageStateOnline:
+		return nil, conflict(upcloud.ErrCodeStorageStateIllegal, "Storage %s is in state %s.", source.UUID, source.State)
+	}
+
+	template := s.addStorage(upcloud.Storage{
+		Title:        body.Storage.Title,
+		Type:         upcloud.StorageTypeTemplate,
+		Zone:         source.Zone,
+		Size:         source.Size,
+		Tier:         source.Tier,
+		Encrypted:    source.Encrypted,
+		TemplateType: source.TemplateType,
+		Origin:       source.UUID,
+	})
+	if template.TemplateType == "" {
+		template.TemplateType = upcloud.StorageTemplateTypeNative
+	}
+	source.State = upcloud.StorageStateCloning
+	s.schedule(&source.pending, upcloud.StorageStateOnline)
+	return s.storageDetails(template), nil
+}
+
+func (s *Server) createStorageImport(r *http.Request) (any, error) {
+	st, err := s.privateStorage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		StorageImport struct {
+			Source string `json:"source"`
+		} `json:"storage_import"`
+	}
+	if err := decode(r, &body); err != nil {
+		return nil, err
+	}
+	switch {
+	case body.StorageImport.Source != importSourceDirectUpload:
+		return nil, badRequest(upcloud.ErrCodeInvalidRequest, "Emulator supports only %s imports.", importSourceDirectUpload)
+	case s.attachedServer(st.UUID) != nil:
+		return nil, conflict(upcloud.ErrCodeStorageAttached, "Storage %s is attached to a server.", st.UUID)
+	case st.State != upcloud.StorageStateOnline:
+		return nil, conflict(upcloud.ErrCodeStorageStateIllegal, "Storage %s is in state %s.", st.UUID, st.State)
+	}
+
+	uuid := s.uuid(prefixStorage)
+	st.imp = &storageImport{StorageImportDetails: upcloud.StorageImportDetails{
+		UUID:            uuid,
+		State:           upcloud.StorageImportStatePrepared,
+		Source:          importSourceDirectUpload,
+		Created:         time.Now(),
+		DirectUploadURL: s.URL + "/uploader/session/" + uuid,
+	}}
+	st.State = upcloud.StorageStateMaintenance
+	return map[string]any{"storage_import": st.imp.json()}, nil
+}
+
+func (s *Server) getStorageImport(r *http.Request) (any, error) {
+	st, err := s.storage(r.PathValue("uuid"))
+	if err != nil {
+		return nil, err
+	}
+	if st.imp == nil {
+		return nil, notFound(upcloud.ErrCodeStorageImportNotFound, "Storage %s has no import.", st.UUID)
+	}
+	return map[string]any{"storage_import": st.imp.json()}, nil
+}
+
+// upload receives the image of a direct upload import. Image is checksummed and discarded.
+func (s *Server) upload(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, badRequest(upcloud.ErrCodeInvalidRequest, "Failed to read image: %s", err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advance(time.Now())
+
+	var st *storage
+	for _, candidate := range s.storages {
+		if candidate.imp != nil && candidate.imp.UUID == r.PathValue("uuid") {
+			st = candidate
+		}
+	}
+	if st == nil || st.imp.State != upcloud.StorageImportStatePrepared {
+		writeError(w, notFound(upcloud.ErrCodeStorageImportNotFound, "Upload session %s does not exist.", r.PathValue("uuid")))
+		return
+	}
+
+	imp := st.imp
+	imp.ClientContentType = r.Header.Get("Content-Type")
+	imp.ClientContentLength = len(data)
+	imp.ReadBytes = len(data)
+	if err := imp.write(data, st.Size); err != nil {
+		imp.State = upcloud.StorageImportStateFailed
+		imp.ErrorCode = "IMPORT_FAILED"
+		imp.ErrorMessage = err.Error()
+		st.State = upcloud.StorageStateOnline
+	} else {
+		imp.State = upcloud.StorageImportStateImporting
+		s.schedule(&imp.pending, upcloud.StorageImportStateCompleted)
+		s.schedule(&st.pending, upcloud.StorageStateOnline)
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"written_bytes": imp.WrittenBytes,
+		"md5sum":        imp.MD5Sum,
+		"sha256sum":     imp.SHA256Sum,
+	})
+}
+
+// write decompresses the image and calculates checksums of the data written to the storage.
+func (i *storageImport) write(data []byte, sizeGB int) error {
+	switch i.ClientContentType {
+	case contentTypeRaw:
+	case contentTypeGzip:
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("failed to decompress image: %w", err)
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			return fmt.Errorf("failed to decompress image: %w", err)
+		}
+	default:
+		return fmt.Errorf("content type %q is not supported by the emulator", i.ClientContentType)
+	}
+	if len(data) > sizeGB*gigabyte {
+		return fmt.Errorf("image size %d bytes exceeds storage size %d GB", len(data), sizeGB)
+	}
+
+	sha := sha256.Sum256(data)
+	md := md5.Sum(data) //nolint:gosec // API reports MD5 checksum of the import
+	i.WrittenBytes = len(data)
+	i.SHA256Sum = hex.EncodeToString(sha[:])
+	i.MD5Sum = hex.EncodeToString(md[:])
+	return nil
+}
+
+// addStorage adds a private storage which becomes online after StateDelay. Caller must hold the lock.
+func (s *Server) addStorage(st upcloud.Storage) *storage {
+	st.UUID = s.uuid(prefixStorage)
+	st.Access = upcloud.StorageAccessPrivate
+	st.State = upcloud.StorageStateMaintenance
+	st.Created = time.Now().UTC().Truncate(time.Second)
+	if st.Type == "" {
+		st.Type = upcloud.StorageTypeNormal
+	}
+	if st.Tier == "" {
+		st.Tier = upcloud.StorageTierMaxIOPS
+	}
+	added := &storage{Storage: st}
+	s.schedule(&added.pending, upcloud.StorageStateOnline)
+	s.storages[st.UUID] = added
+	return added
+}
+
+// storage returns the storage or not found error. Caller must hold the lock.
+func (s *Server) storage(uuid string) (*storage, error) {
+	st, ok := s.storages[uuid]
+	if !ok {
+		return nil, notFound(upcloud.ErrCodeStorageNotFound, "Storage %s does not exist.", uuid)
+	}
+	return st, nil
+}
+
+// privateStorage returns the storage if it can be modified by the account. Caller must hold the lock.
+func (s *Server) privateStorage(uuid string) (*storage, error) {
+	st, err := s.storage(uuid)
+	if err != nil {
+		return nil, err
+	}
+	if st.Access != upcloud.StorageAccessPrivate {
+		return nil, forbidden(upcloud.ErrCodeStorageForbidden, "Storage %s is public.", uuid)
+	}
+	return st, nil
+}
+
+// sortedStorages returns storage UUIDs in creation order. Caller must hold the lock.
+func (s *Server) sortedStorages() []string {
+	uuids := make([]string, 0, len(s.storages))
+	for uuid := range s.storages {
+		uuids = append(uuids, uuid)
+	}
+	slices.SortFunc(uuids, func(a, b string) int {
+		return strings.Compare(a[2:], b[2:])
+	})
+	return uuids
+}
+
+// storageDetails returns storage details with the servers the storage is attached to. Caller must hold the lock.
+func (s *Server) storageDetails(st *storage) map[string]any {
+	servers := make([]string, 0)
+	if srv := s.attachedServer(st.UUID); srv != nil {
+		servers = append(servers, srv.uuid)
+	}
+	details := st.json()
+	details["created"] = st.Created.Format(time.RFC3339)
+	details["servers"] = map[string]any{"server": servers}
+	details["backups"] = map[string]any{"backup": []string{}}
+	return map[string]any{"storage": details}
+}
+
+// json returns the storage as it is listed by the API. Creation time is available only in storage details.
+func (st *storage) json() map[string]any {
+	labels := st.Labels
+	if labels == nil {
+		labels = []upcloud.Label{}
+	}
+	v := map[string]any{
+		"access":       st.Access,
+		"encrypted":    yesNo(st.Encrypted.Bool()),
+		"labels":       labels,
+		"license":      st.License,
+		"part_of_plan": "no",
+		"size":         st.Size,
+		"state":        st.State,
+		"tier":         st.Tier,
+		"title":        st.Title,
+		"type":         st.Type,
+		"uuid":         st.UUID,
+		"zone":         st.Zone,
+	}
+	if st.Type == upcloud.StorageTypeTemplate {
+		v["template_type"] = st.TemplateType
+	}
+	if st.Origin != "" {
+		v["origin"] = st.Origin
+	}
+	return v
+}
+
+func (i *storageImport) json() map[string]any {
+	completed := ""
+	if !i.Completed.IsZero() {
+		completed = i.Completed.Format(time.RFC3339)
+	}
+	return map[string]any{
+		"client_content_length": i.ClientContentLength,
+		"client_content_type":   i.ClientContentType,
+		"completed":             completed,
+		"created":               i.Created.Format(time.RFC3339),
+		"direct_upload_url":     i.DirectUploadURL,
+		"error_code":            i.ErrorCode,
+		"error_message":         i.ErrorMessage,
+		"md5sum":                i.MD5Sum,
+		"read_bytes":            i.ReadBytes,
+		"sha256sum":             i.SHA256Sum,
+		"source":                i.Source,
+		"source_location":       "",
+		"state":                 i.State,
+		"uuid":                  i.UUID,
+		"written_bytes":         i.WrittenBytes,
+	}
+}
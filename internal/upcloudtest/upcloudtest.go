@@ -0,0 +1,243 @@
+// Package upcloudtest implements an HTTP server which emulates the subset of the UpCloud API used by the driver. It keeps
+// servers, storages, networks and IP addresses in memory and moves them through the same state transitions as the API,
+// so that builds and imports can be run end to end without an UpCloud account.
+package upcloudtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+const (
+	// Username, Password and Token are the credentials accepted by the emulator.
+	Username string = "emulator"
+	Password string = "emulator"
+	Token    string = "ucat_emulator"
+
+	// DefaultStateDelay is the time that state transitions take by default.
+	DefaultStateDelay time.Duration = time.Second
+
+	// DefaultCloudInitDuration is the time that cloud-init runs by default before powering off the server. It exceeds
+	// the interval the API client polls server state at, so that the server is seen started before it stops.
+	DefaultCloudInitDuration time.Duration = 10 * time.Second
+
+	apiPrefix       string = "/1.3"
+	errorTypePrefix string = "https://developers.upcloud.com/1.3/errors#ERROR_"
+
+	prefixServer  string = "00"
+	prefixStorage string = "01"
+	prefixNetwork string = "03"
+)
+
+type (
+	// Server is the API emulator. It is safe for concurrent use. Use NewServer to create one.
+	Server struct {
+		*httptest.Server
+
+		// StateDelay is the time that each state transition takes, e.g. starting a server or cloning a storage.
+		StateDelay time.Duration
+
+		// CloudInitDuration is the time that cloud-init runs before powering off a server started with user data
+		// using the power_state module.
+		CloudInitDuration time.Duration
+
+		mu          sync.Mutex
+		seq         int
+		zones       []string
+		plans       []upcloud.Plan
+		prices      map[string]map[string]upcloud.Price
+		account     upcloud.Account
+		servers     map[string]*server
+		storages    map[string]*storage
+		networks    map[string]*network
+		ipAddresses map[string]*ipAddress
+	}
+
+	// transition is a state change which takes place when the clock passes at.
+	transition struct {
+		at    time.Time
+		state string
+	}
+
+	// transitions are pending state changes of a resource in the order they take place.
+	transitions []transition
+
+	// apiError is an error response of the API.
+	apiError struct {
+		status int
+		code   string
+		title  string
+	}
+)
+
+// NewServer starts an emulator seeded with zones, plans, prices and public templates. Caller must call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		StateDelay:        DefaultStateDelay,
+		CloudInitDuration: DefaultCloudInitDuration,
+		servers:           make(map[string]*server),
+		storages:          make(map[string]*storage),
+		networks:          make(map[string]*network),
+		ipAddresses:       make(map[string]*ipAddress),
+	}
+	s.seed()
+
+	mux := http.NewServeMux()
+	s.routeCatalog(mux)
+	s.routeServers(mux)
+	s.routeStorages(mux)
+	s.routeNetworks(mux)
+	s.Server = httptest.NewServer(s.authenticate(mux))
+	return s
+}
+
+// authenticate rejects API requests with invalid credentials. Direct uploads are authenticated by the upload URL.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, apiPrefix+"/") && !authorized(r) {
+			writeError(w, &apiError{http.StatusUnauthorized, upcloud.ErrCodeAuthenticationFailed, "Authentication failed using the given username and password."})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func authorized(r *http.Request) bool {
+	if username, password, ok := r.BasicAuth(); ok {
+		return username == Username && password == Password
+	}
+	return r.Header.Get("Authorization") == "Bearer "+Token
+}
+
+// handle registers the handler to the API path. Handler is called holding the lock after pending state transitions
+// have taken place. Response is written as JSON unless the handler returns an error.
+func (s *Server) handle(mux *http.ServeMux, pattern string, handler func(r *http.Request) (any, error)) {
+	method, path, _ := strings.Cut(pattern, " ")
+	mux.HandleFunc(method+" "+apiPrefix+path, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.advance(time.Now())
+		res, err := handler(r)
+		s.mu.Unlock()
+
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if res == nil {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			status = http.StatusCreated
+		}
+		writeJSON(w, status, res)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("[WARN] failed to write emulator response: %s", err)
+	}
+}
+
+// writeError writes the error as problem details like the API does.
+func writeError(w http.ResponseWriter, err error) {
+	e, ok := err.(*apiError) //nolint:errorlint // handlers return API errors as is
+	if !ok {
+		e = &apiError{http.StatusInternalServerError, "INTERNAL_ERROR", err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(e.status)
+	_ = json.NewEncoder(w).Encode(upcloud.Problem{
+		Type:   errorTypePrefix + e.code,
+		Title:  e.title,
+		Status: e.status,
+	})
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s: %s", e.code, e.title)
+}
+
+func badRequest(code, format string, a ...any) error {
+	return &apiError{http.StatusBadRequest, code, fmt.Sprintf(format, a...)}
+}
+
+func notFound(code, format string, a ...any) error {
+	return &apiError{http.StatusNotFound, code, fmt.Sprintf(format, a...)}
+}
+
+func conflict(code, format string, a ...any) error {
+	return &apiError{http.StatusConflict, code, fmt.Sprintf(format, a...)}
+}
+
+func forbidden(code, format string, a ...any) error {
+	return &apiError{http.StatusForbidden, code, fmt.Sprintf(format, a...)}
+}
+
+// decode reads the request body into v.
+func decode(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return badRequest(upcloud.ErrCodeInvalidRequest, "Request body is malformed: %s", err)
+	}
+	return nil
+}
+
+// uuid returns a new UUID with the prefix used by the API for the resource type. Caller must hold the lock.
+func (s *Server) uuid(prefix string) string {
+	s.seq++
+	return fmt.Sprintf("%s%06x-0000-4000-8000-%012x", prefix, s.seq, s.seq)
+}
+
+// schedule replaces pending transitions of a resource with the states, each taking StateDelay. Caller must hold the lock.
+func (s *Server) schedule(t *transitions, states ...string) {
+	*t = (*t)[:0]
+	at := time.Now()
+	for _, state := range states {
+		at = at.Add(s.StateDelay)
+		*t = append(*t, transition{at: at, state: state})
+	}
+}
+
+// advance applies the transitions which have taken place by now. Caller must hold the lock.
+func (s *Server) advance(now time.Time) {
+	for _, srv := range s.servers {
+		srv.pending.apply(&srv.state, now)
+	}
+	for _, st := range s.storages {
+		st.pending.apply(&st.State, now)
+		if st.imp != nil {
+			st.imp.pending.apply(&st.imp.State, now)
+			if st.imp.State == upcloud.StorageImportStateCompleted && st.imp.Completed.IsZero() {
+				st.imp.Completed = now
+			}
+		}
+	}
+}
+
+// apply sets the state of the latest transition which has taken place by now.
+func (t *transitions) apply(state *string, now time.Time) {
+	for len(*t) > 0 && !now.Before((*t)[0].at) {
+		*state = (*t)[0].state
+		*t = (*t)[1:]
+	}
+}
+
+// yesNo formats the boolean like the API does.
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,286 @@
+//go:build integration
+
+package upcloudtest_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/upcloudtest"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud/request"
+)
+
+// Waiting for a state change polls the API every five seconds, so these tests take a few seconds each and are run
+// with the integration tests. They do not need UpCloud credentials.
+
+func newDriver(t *testing.T) (*upcloudtest.Server, driver.Driver) {
+	t.Helper()
+	srv := upcloudtest.NewServer()
+	srv.StateDelay = 10 * time.Millisecond
+	srv.CloudInitDuration = 6 * time.Second
+	t.Cleanup(srv.Close)
+	return srv, driver.NewDriver(&driver.DriverConfig{
+		APIURL:   srv.URL,
+		Username: upcloudtest.Username,
+		Password: upcloudtest.Password,
+		Timeout:  time.Minute,
+		Retry:    driver.RetryConfig{MaxAttempts: 1},
+	})
+}
+
+func TestServer_catalog(t *testing.T) {
+	t.Parallel()
+	_, drv := newDriver(t)
+	ctx := t.Context()
+
+	assert.Contains(t, drv.GetAvailableZones(ctx), "pl-waw1")
+
+	plans, err := drv.GetPlanPrices(ctx, "pl-waw1")
+	require.NoError(t, err)
+	assert.True(t, func() bool {
+		for _, p := range plans {
+			if p.Name == driver.DefaultPlan {
+				return true
+			}
+		}
+		return false
+	}(), "default plan is available")
+
+	account, err := drv.GetAccount(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, upcloudtest.Username, account.UserName)
+
+	storage, err := drv.GetStorage(ctx, "", "ubuntu server 24.04")
+	require.NoError(t, err)
+	assert.Equal(t, upcloudtest.UbuntuServer2404UUID, storage.UUID)
+
+	iso, err := drv.GetISOStorage(ctx, upcloudtest.Debian12ISOUUID, "")
+	require.NoError(t, err)
+	assert.Equal(t, upcloud.StorageTypeCDROM, iso.Type)
+}
+
+func TestServer_build(t *testing.T) {
+	t.Parallel()
+	_, drv := newDriver(t)
+	ctx := t.Context()
+
+	metadata := false
+	_, err := drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID: upcloudtest.RockyLinux9UUID,
+		Zone:        "pl-waw1",
+		Metadata:    &metadata,
+	})
+	require.ErrorContains(t, err, "METADATA_DISABLED_ON_CLOUD-INIT", "cloud-init templates require metadata service")
+
+	// metadata service is enabled by the driver when the API requires it
+	server, err := drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID: upcloudtest.RockyLinux9UUID,
+		StorageSize: 10,
+		Zone:        "pl-waw1",
+		Labels:      map[string]string{"build": "emulator"},
+		Networking: []request.CreateServerInterface{
+			{Type: upcloud.NetworkTypePublic, IPAddresses: request.CreateServerIPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+		},
+		SSHPublicKey: "ssh-ed25519 AAAA",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "root", server.Username)
+	assert.True(t, server.Metadata.Bool())
+	require.Len(t, server.IPAddresses, 1)
+	assert.Equal(t, upcloud.NetworkTypePublic, server.IPAddresses[0].Access)
+
+	servers, err := drv.ListServers(ctx, request.FilterLabel{Label: upcloud.Label{Key: "build", Value: "emulator"}})
+	require.NoError(t, err)
+	require.Len(t, servers, 1)
+	assert.Equal(t, server.UUID, servers[0].UUID)
+	assert.Equal(t, upcloud.ServerStateStarted, servers[0].State)
+
+	storages, err := drv.GetServerStorages(ctx, server.UUID)
+	require.NoError(t, err)
+	require.Len(t, storages, 1)
+	assert.Equal(t, 10, storages[0].Size)
+
+	_, err = drv.CreateTemplate(ctx, storages[0].UUID, "running")
+	require.ErrorIs(t, err, driver.ErrConflict, "server must be stopped before templatizing")
+
+	require.NoError(t, drv.StopServer(ctx, server.UUID))
+	template, err := drv.CreateTemplate(ctx, storages[0].UUID, "emulator-template")
+	require.NoError(t, err)
+	assert.Equal(t, upcloud.StorageTypeTemplate, template.Type)
+	assert.Equal(t, upcloud.StorageStateOnline, template.State)
+	assert.Equal(t, upcloud.StorageTemplateTypeCloudInit, template.TemplateType)
+
+	clone, err := drv.CloneStorage(ctx, template.UUID, "fi-hel2", "emulator-template", false)
+	require.NoError(t, err)
+	assert.Equal(t, "fi-hel2", clone.Zone)
+
+	require.NoError(t, drv.DeleteServer(ctx, server.UUID))
+	_, err = drv.GetStorageDetails(ctx, storages[0].UUID)
+	require.ErrorIs(t, err, driver.ErrNotFound, "disks are deleted together with the server")
+
+	found, err := drv.GetTemplateByName(ctx, "emulator-template", "pl-waw1")
+	require.NoError(t, err)
+	assert.Equal(t, template.UUID, found.UUID)
+	require.NoError(t, drv.DeleteTemplate(ctx, template.UUID))
+	require.NoError(t, drv.DeleteTemplate(ctx, clone.UUID))
+}
+
+func TestServer_import(t *testing.T) {
+	t.Parallel()
+	_, drv := newDriver(t)
+	ctx := t.Context()
+
+	image := bytes.Repeat([]byte("emulator"), 1024)
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	_, err := w.Write(image)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	storage, err := drv.CreateTemplateStorage(ctx, "emulator-import", "fi-hel1", 1, upcloud.StorageTierMaxIOPS, true)
+	require.NoError(t, err)
+
+	details, err := drv.ImportStorage(ctx, storage.UUID, "application/gzip", &compressed)
+	require.NoError(t, err)
+	sum := sha256.Sum256(image)
+	assert.Equal(t, hex.EncodeToString(sum[:]), details.SHA256Sum)
+	assert.Equal(t, len(image), details.WrittenBytes)
+	assert.Equal(t, upcloud.StorageImportStateCompleted, details.State)
+
+	storage, err = drv.WaitStorageOnline(ctx, storage.UUID)
+	require.NoError(t, err)
+	assert.True(t, storage.Encrypted.Bool())
+
+	_, err = drv.ImportStorage(ctx, storage.UUID, "application/x-xz", bytes.NewReader(image))
+	require.ErrorContains(t, err, "not supported by the emulator")
+
+	require.NoError(t, drv.SetStorageLabels(ctx, storage.UUID, map[string]string{"os": "emulator"}))
+	found, err := drv.FindStorage(ctx, &driver.StorageFilter{Labels: map[string]string{"os": "emulator"}})
+	require.NoError(t, err)
+	assert.Equal(t, storage.UUID, found.UUID)
+}
+
+func TestServer_networking(t *testing.T) {
+	t.Parallel()
+	_, drv := newDriver(t)
+	ctx := t.Context()
+
+	network, err := drv.CreateNetwork(ctx, &driver.NetworkOpts{Zone: "de-fra1", Address: "172.31.250.0/24", DHCP: true})
+	require.NoError(t, err)
+
+	server, err := drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID:    upcloudtest.Debian12UUID,
+		StorageSize:    10,
+		Zone:           "de-fra1",
+		CreatePassword: true,
+		UserData:       "#cloud-config",
+		Networking: []request.CreateServerInterface{
+			{Type: upcloud.NetworkTypePublic, IPAddresses: request.CreateServerIPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+			{Type: upcloud.NetworkTypeUtility, IPAddresses: request.CreateServerIPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+			{Type: upcloud.NetworkTypePrivate, Network: network.UUID, IPAddresses: request.CreateServerIPAddressSlice{{Family: upcloud.IPAddressFamilyIPv4}}},
+		},
+	})
+	require.NoError(t, err)
+	assert.NotEmpty(t, server.Password)
+	require.Len(t, server.Networking.Interfaces, 3)
+	assert.Regexp(t, `^10\.`, server.Networking.Interfaces[1].IPAddresses[0].Address)
+	assert.Regexp(t, `^172\.31\.250\.`, server.Networking.Interfaces[2].IPAddresses[0].Address)
+
+	network, err = drv.GetNetwork(ctx, network.UUID)
+	require.NoError(t, err)
+	require.Len(t, network.Servers, 1)
+	assert.Equal(t, server.UUID, network.Servers[0].ServerUUID)
+	require.ErrorIs(t, drv.DeleteNetwork(ctx, network.UUID), driver.ErrConflict)
+
+	ip, err := drv.CreateFloatingIPAddress(ctx, "de-fra1", server.Networking.Interfaces[0].MAC)
+	require.NoError(t, err)
+	assert.Equal(t, server.UUID, ip.ServerUUID)
+	details, err := drv.GetServerDetails(ctx, server.UUID)
+	require.NoError(t, err)
+	assert.Len(t, details.Networking.Interfaces[0].IPAddresses, 2)
+
+	require.NoError(t, drv.DetachFloatingIPAddress(ctx, ip.Address))
+	_, err = drv.AttachFloatingIPAddress(ctx, ip.Address, server.Networking.Interfaces[1].MAC)
+	require.Error(t, err, "floating IP address can be attached only to public interfaces")
+	require.NoError(t, drv.ReleaseIPAddress(ctx, ip.Address))
+}
+
+func TestServer_powerState(t *testing.T) {
+	t.Parallel()
+	_, drv := newDriver(t)
+	ctx := t.Context()
+
+	server, err := drv.CreateServer(ctx, &driver.ServerOpts{
+		StorageUUID: upcloudtest.UbuntuServer2404UUID,
+		Zone:        "fi-hel1",
+		UserData:    "#cloud-config\npower_state:\n  mode: poweroff\n",
+	})
+	require.NoError(t, err)
+	require.NoError(t, drv.WaitServerStopped(ctx, server.UUID, time.Minute))
+	require.NoError(t, drv.DeleteServer(ctx, server.UUID))
+}
+
+func TestServer_errors(t *testing.T) {
+	t.Parallel()
+	srv, drv := newDriver(t)
+	ctx := t.Context()
+
+	_, err := drv.GetServerDetails(ctx, "00000000-0000-4000-8000-000000000000")
+	require.ErrorIs(t, err, driver.ErrNotFound)
+
+	require.ErrorIs(t, drv.DeleteStorage(ctx, upcloudtest.UbuntuServer2404UUID), driver.ErrAuth, "public storages cannot be deleted")
+
+	_, err = drv.CreateTemplateStorage(ctx, "emulator", "xx-xxx1", 1, "", false)
+	require.ErrorContains(t, err, "ZONE_INVALID")
+
+	unauthorized := driver.NewDriver(&driver.DriverConfig{APIURL: srv.URL, Token: "ucat_invalid", Timeout: time.Minute})
+	_, err = unauthorized.GetAccount(ctx)
+	require.ErrorIs(t, err, driver.ErrAuth)
+}
+
+func TestTemplate(t *testing.T) {
+	const hcl = `source "upcloud" "winrm" {
+  storage_name = "Windows Server 2022 Standard"
+  zone         = "pl-waw1"
+
+  communicator   = "winrm"
+  winrm_insecure = true
+}
+
+build {
+  sources = ["source.upcloud.winrm"]
+
+  provisioner "powershell" {
+    inline = ["Get-ComputerInfo -Property WindowsProductName"]
+  }
+}
+`
+	const json = `{"builders": [{"type": "upcloud", "zone": "nl-ams1"}], "provisioners": [{"type": "shell"}]}`
+
+	t.Setenv(upcloudtest.EnvEmulator, "")
+	assert.Equal(t, hcl, upcloudtest.Template(hcl))
+
+	t.Setenv(upcloudtest.EnvEmulator, "1")
+	assert.Equal(t, `source "upcloud" "winrm" {
+  communicator = "none"
+  storage_name = "Windows Server 2022 Standard"
+  zone         = "pl-waw1"
+
+  winrm_insecure = true
+}
+
+build {
+  sources = ["source.upcloud.winrm"]
+}
+`, upcloudtest.Template(hcl))
+	assert.JSONEq(t, `{"builders": [{"type": "upcloud", "zone": "nl-ams1", "communicator": "none"}]}`, upcloudtest.Template(json))
+}
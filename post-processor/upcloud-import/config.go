@@ -27,6 +27,23 @@ type Config struct {
 	// The API token to use when interfacing with the UpCloud API. This is mutually exclusive with username and password.
 	Token string `mapstructure:"token"`
 
+	// The base URL of the UpCloud API. Defaults to `UPCLOUD_API_URL` environment variable or `https://api.upcloud.com`.
+	// Can be used to send requests through an API gateway or to a local API emulator.
+	APIURL string `mapstructure:"api_url"`
+
+	// The URL of the HTTP proxy used for API requests and image uploads, e.g. `http://proxy.example.com:3128`.
+	// Defaults to the proxy set in `HTTPS_PROXY` and `NO_PROXY` environment variables.
+	HTTPProxy string `mapstructure:"http_proxy"`
+
+	// Path to a file of PEM encoded CA certificates trusted in addition to the system certificates when connecting to
+	// the API, e.g. when `http_proxy` intercepts TLS connections.
+	CABundleFile string `mapstructure:"ca_bundle_file"`
+
+	// Path to a file where API requests are traced in addition to Packer's log, e.g. for attaching to support tickets.
+	// Method, path, status, latency, request ID and bodies of the requests are logged with credentials and SSH keys redacted.
+	// Tracing to Packer's log can be enabled also by setting `UPCLOUD_API_DEBUG` environment variable to `1`.
+	APIDebugLog string `mapstructure:"api_debug_log"`
+
 	// The list of zones in which the template should be imported
 	Zones []string `mapstructure:"zones" required:"true"`
 
@@ -40,11 +57,24 @@ type Config struct {
 	// The storage tier to use. Available options are `maxiops`, `archive`, and `standard`. Defaults to `maxiops`.
 	StorageTier string `mapstructure:"storage_tier"`
 
+	// Encrypt the imported storage, its copies in other zones and the created templates at rest. Defaults to `false`.
+	// Encryption is not supported by `archive` storage tier.
+	StorageEncryption bool `mapstructure:"storage_encryption"`
+
 	// The storage size in gigabytes. If not specified, defaults to the image size
 	// (minimum 10GB). When importing compressed images that expand significantly, specify
 	// a larger value to ensure adequate space for the uncompressed content.
 	StorageSize int `mapstructure:"storage_size"`
 
+	// Labels to set to the created storages and templates, e.g. `{ team = "platform" }`.
+	// Labels `packer-build-name`, `packer-build-uuid` and `packer-plugin-version` are set automatically
+	// and keys starting with `packer-` are reserved.
+	Labels map[string]string `mapstructure:"labels"`
+
+	// Directory where resources created during the import are recorded, so that they can be removed using
+	// `upcloud-packer-reaper -recover` if Packer is killed before cleaning up. Defaults to `upcloud/journal` in the Packer cache directory.
+	JournalDir string `mapstructure:"journal_dir"`
+
 	// The amount of time to wait for resource state changes. Defaults to `60m`.
 	Timeout time.Duration `mapstructure:"state_timeout_duration"`
 
@@ -108,9 +138,28 @@ func (c *Config) validate() *packer.MultiError {
 		}
 	}
 
+	if c.StorageEncryption && !driver.StorageTierSupportsEncryption(c.StorageTier) {
+		errs = packer.MultiErrorAppend(
+			errs, fmt.Errorf("'storage_encryption' is not supported by 'storage_tier' %q", c.StorageTier),
+		)
+	}
+
+	for _, err := range driver.ValidateLabels(c.Labels) {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid 'labels': %w", err))
+	}
+
+	if es := driver.ValidateEndpoint(c.APIURL, c.HTTPProxy, c.CABundleFile); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
 	return errs
 }
 
+// storageLabels returns labels for the created storages and templates.
+func (c *Config) storageLabels() map[string]string {
+	return driver.MergeLabels(c.Labels, driver.AutomaticLabels(c.PackerBuildName, ""))
+}
+
 // setDefaults sets default values for configuration fields.
 func (c *Config) setDefaults() {
 	if c.Timeout < 1 {
@@ -132,5 +181,6 @@ func (c *Config) fromEnv() error {
 	c.Username = creds.Username
 	c.Password = creds.Password
 	c.Token = creds.Token
+	c.APIURL = driver.APIURLFromEnv(c.APIURL)
 	return nil
 }
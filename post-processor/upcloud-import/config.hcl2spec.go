@@ -13,11 +13,18 @@ type FlatConfig struct {
 	Username            *string           `mapstructure:"username" cty:"username" hcl:"username"`
 	Password            *string           `mapstructure:"password" cty:"password" hcl:"password"`
 	Token               *string           `mapstructure:"token" cty:"token" hcl:"token"`
+	APIURL              *string           `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	HTTPProxy           *string           `mapstructure:"http_proxy" cty:"http_proxy" hcl:"http_proxy"`
+	CABundleFile        *string           `mapstructure:"ca_bundle_file" cty:"ca_bundle_file" hcl:"ca_bundle_file"`
+	APIDebugLog         *string           `mapstructure:"api_debug_log" cty:"api_debug_log" hcl:"api_debug_log"`
 	Zones               []string          `mapstructure:"zones" required:"true" cty:"zones" hcl:"zones"`
 	TemplateName        *string           `mapstructure:"template_name" required:"true" cty:"template_name" hcl:"template_name"`
 	ReplaceExisting     *bool             `mapstructure:"replace_existing" cty:"replace_existing" hcl:"replace_existing"`
 	StorageTier         *string           `mapstructure:"storage_tier" cty:"storage_tier" hcl:"storage_tier"`
+	StorageEncryption   *bool             `mapstructure:"storage_encryption" cty:"storage_encryption" hcl:"storage_encryption"`
 	StorageSize         *int              `mapstructure:"storage_size" cty:"storage_size" hcl:"storage_size"`
+	Labels              map[string]string `mapstructure:"labels" cty:"labels" hcl:"labels"`
+	JournalDir          *string           `mapstructure:"journal_dir" cty:"journal_dir" hcl:"journal_dir"`
 	Timeout             *string           `mapstructure:"state_timeout_duration" cty:"state_timeout_duration" hcl:"state_timeout_duration"`
 	PackerBuildName     *string           `mapstructure:"packer_build_name" cty:"packer_build_name" hcl:"packer_build_name"`
 	PackerBuilderType   *string           `mapstructure:"packer_builder_type" cty:"packer_builder_type" hcl:"packer_builder_type"`
@@ -44,11 +51,18 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"username":                   &hcldec.AttrSpec{Name: "username", Type: cty.String, Required: false},
 		"password":                   &hcldec.AttrSpec{Name: "password", Type: cty.String, Required: false},
 		"token":                      &hcldec.AttrSpec{Name: "token", Type: cty.String, Required: false},
+		"api_url":                    &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"http_proxy":                 &hcldec.AttrSpec{Name: "http_proxy", Type: cty.String, Required: false},
+		"ca_bundle_file":             &hcldec.AttrSpec{Name: "ca_bundle_file", Type: cty.String, Required: false},
+		"api_debug_log":              &hcldec.AttrSpec{Name: "api_debug_log", Type: cty.String, Required: false},
 		"zones":                      &hcldec.AttrSpec{Name: "zones", Type: cty.List(cty.String), Required: false},
 		"template_name":              &hcldec.AttrSpec{Name: "template_name", Type: cty.String, Required: false},
 		"replace_existing":           &hcldec.AttrSpec{Name: "replace_existing", Type: cty.Bool, Required: false},
 		"storage_tier":               &hcldec.AttrSpec{Name: "storage_tier", Type: cty.String, Required: false},
+		"storage_encryption":         &hcldec.AttrSpec{Name: "storage_encryption", Type: cty.Bool, Required: false},
 		"storage_size":               &hcldec.AttrSpec{Name: "storage_size", Type: cty.Number, Required: false},
+		"labels":                     &hcldec.AttrSpec{Name: "labels", Type: cty.Map(cty.String), Required: false},
+		"journal_dir":                &hcldec.AttrSpec{Name: "journal_dir", Type: cty.String, Required: false},
 		"state_timeout_duration":     &hcldec.AttrSpec{Name: "state_timeout_duration", Type: cty.String, Required: false},
 		"packer_build_name":          &hcldec.AttrSpec{Name: "packer_build_name", Type: cty.String, Required: false},
 		"packer_builder_type":        &hcldec.AttrSpec{Name: "packer_builder_type", Type: cty.String, Required: false},
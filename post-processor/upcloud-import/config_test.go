@@ -195,6 +195,20 @@ func TestConfig_fromEnv_APIToken(t *testing.T) {
 	assert.Equal(t, "test-token", c.Token)
 }
 
+func TestConfig_fromEnv_APIURL(t *testing.T) {
+	t.Setenv(driver.EnvConfigAPIToken, "test-token")
+	t.Setenv(driver.EnvConfigAPIURL, "http://127.0.0.1:8080")
+
+	c, err := upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"zones":         []string{"fi-hel1"},
+		"template_name": "my-template",
+	}}...)
+
+	assert.NoError(t, err)
+	require.NotNil(t, c)
+	assert.Equal(t, "http://127.0.0.1:8080", c.APIURL)
+}
+
 func TestConfig_fromEnv_Username(t *testing.T) {
 	t.Setenv(driver.EnvConfigUsername, "env-user")
 	t.Setenv(driver.EnvConfigPassword, "env-pass")
@@ -379,3 +393,78 @@ func TestNewConfig_StorageSize(t *testing.T) {
 		})
 	}
 }
+
+func TestNewConfig_StorageEncryption(t *testing.T) {
+	t.Parallel()
+	c, err := upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"username":           "testuser",
+		"password":           "testpass",
+		"zones":              []string{"fi-hel1"},
+		"template_name":      "my-template",
+		"storage_encryption": true,
+	}}...)
+	require.NoError(t, err)
+	assert.True(t, c.StorageEncryption)
+
+	_, err = upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"username":           "testuser",
+		"password":           "testpass",
+		"zones":              []string{"fi-hel1"},
+		"template_name":      "my-template",
+		"storage_tier":       "archive",
+		"storage_encryption": true,
+	}}...)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `'storage_encryption' is not supported by 'storage_tier' "archive"`)
+}
+
+func TestNewConfig_Labels(t *testing.T) {
+	t.Parallel()
+	c, err := upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"token":         "test-api-token",
+		"zones":         []string{"fi-hel1"},
+		"template_name": "my-template",
+		"labels":        map[string]string{"team": "platform"},
+	}}...)
+
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "platform"}, c.Labels)
+
+	_, err = upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"token":         "test-api-token",
+		"zones":         []string{"fi-hel1"},
+		"template_name": "my-template",
+		"labels":        map[string]string{"packer-build-name": "custom", "_team": "platform"},
+	}}...)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `label key "packer-build-name" is reserved`)
+	assert.Contains(t, err.Error(), `invalid label key "_team"`)
+}
+
+func TestNewConfig_Endpoint(t *testing.T) {
+	t.Parallel()
+	c, err := upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"token":         "test-api-token",
+		"zones":         []string{"fi-hel1"},
+		"template_name": "my-template",
+		"api_url":       "http://127.0.0.1:8080",
+		"http_proxy":    "http://proxy.example.com:3128",
+	}}...)
+
+	require.NoError(t, err)
+	assert.Equal(t, "http://127.0.0.1:8080", c.APIURL)
+	assert.Equal(t, "http://proxy.example.com:3128", c.HTTPProxy)
+
+	_, err = upcloudimport.NewConfig([]interface{}{map[string]interface{}{
+		"token":          "test-api-token",
+		"zones":          []string{"fi-hel1"},
+		"template_name":  "my-template",
+		"api_url":        "api.example.com",
+		"ca_bundle_file": "testdata/missing.pem",
+	}}...)
+
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid 'api_url'")
+	assert.Contains(t, err.Error(), "invalid 'ca_bundle_file'")
+}
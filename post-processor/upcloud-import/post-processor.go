@@ -11,6 +11,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 )
 
@@ -39,6 +40,9 @@ type PostProcessor struct {
 	config *Config
 	runner multistep.Runner
 	driver driver.Driver
+
+	// newDriverFn creates the driver, defaults to driver.NewDriver. Overridden in tests.
+	newDriverFn func(c *driver.DriverConfig) driver.Driver
 }
 
 func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec {
@@ -50,15 +54,28 @@ func (p *PostProcessor) Configure(raws ...interface{}) error {
 	if p.config, err = NewConfig(raws...); err != nil {
 		return err
 	}
-	p.driver = driver.NewDriver(&driver.DriverConfig{
-		Username: p.config.Username,
-		Password: p.config.Password,
-		Token:    p.config.Token,
-		Timeout:  p.config.Timeout,
-	})
+	p.driver = p.newDriver(nil)
 	return p.validate()
 }
 
+func (p *PostProcessor) newDriver(j *journal.Journal) driver.Driver {
+	newDriver := driver.NewDriver
+	if p.newDriverFn != nil {
+		newDriver = p.newDriverFn
+	}
+	return newDriver(&driver.DriverConfig{
+		Username:     p.config.Username,
+		Password:     p.config.Password,
+		Token:        p.config.Token,
+		Timeout:      p.config.Timeout,
+		Journal:      j,
+		APIURL:       p.config.APIURL,
+		HTTPProxy:    p.config.HTTPProxy,
+		CABundleFile: p.config.CABundleFile,
+		APIDebugLog:  p.config.APIDebugLog,
+	})
+}
+
 // PostProcess takes a previously created Artifact and produces another
 // Artifact. If an error occurs, it should return that error. If `keep` is
 // true, then the previous artifact defaults to being kept if user has not
@@ -83,6 +100,15 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, a packer.
 	if err != nil {
 		return nil, false, false, err
 	}
+
+	// Created resources are recorded so that they can be removed even if the import is killed before cleanup
+	jrnl, err := journal.OpenDir(p.config.JournalDir, p.config.PackerBuildName)
+	if err != nil {
+		return nil, false, false, err //nolint:wrapcheck // journal errors are descriptive
+	}
+	defer reportJournal(ui, jrnl)
+	p.driver = p.newDriver(jrnl)
+
 	state := new(multistep.BasicStateBag)
 	state.Put(stateUI, ui)
 	state.Put(stateArtifact, a)
@@ -115,6 +141,13 @@ func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, a packer.
 		return nil, false, false, fmt.Errorf("templates is not of expected type []*upcloud.Storage, got %T", templatesRaw)
 	}
 
+	// templates are handed over as an artifact
+	for _, t := range templates {
+		if err := jrnl.Remove(t.UUID); err != nil {
+			ui.Error(err.Error())
+		}
+	}
+
 	return &Artifact{
 		postProcessor: p,
 		templates:     templates,
@@ -131,7 +164,13 @@ func (p *PostProcessor) validate() error {
 	if !p.config.ReplaceExisting {
 		for _, zone := range p.config.Zones {
 			s, err := p.driver.GetTemplateByName(ctx, p.config.TemplateName, zone)
-			if err == nil && s.UUID != "" {
+			if err != nil {
+				if errors.Is(err, driver.ErrNotFound) {
+					continue
+				}
+				return fmt.Errorf("failed to check existing templates: %w", err)
+			}
+			if s.UUID != "" {
 				return fmt.Errorf("template with the name '%s' already exists at %s zone. Change the name or set replace_existing to true", s.Title, zone)
 			}
 		}
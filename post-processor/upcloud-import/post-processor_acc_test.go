@@ -13,6 +13,7 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/upcloudtest"
 
 	_ "embed"
 )
@@ -28,6 +29,10 @@ func (a *testArtifact) String() string                { return "" }
 func (a *testArtifact) State(name string) interface{} { return nil }
 func (a *testArtifact) Destroy() error                { return nil }
 
+func TestMain(m *testing.M) {
+	os.Exit(upcloudtest.RunAcceptanceTests(m))
+}
+
 func TestPostProcessorAcc_raw(t *testing.T) {
 	t.Parallel()
 	if os.Getenv("PACKER_ACC") != "1" {
@@ -73,6 +78,7 @@ func TestPostProcessorAcc_raw(t *testing.T) {
 		Password: creds.Password,
 		Token:    creds.Token,
 		Timeout:  time.Minute * 30,
+		APIURL:   driver.APIURLFromEnv(""),
 	})
 	t1, err := driver.GetTemplateByName(ctx, testName, "pl-waw1")
 	require.NoError(t, err)
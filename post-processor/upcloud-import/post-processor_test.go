@@ -0,0 +1,133 @@
+//go:build !integration
+
+package upcloudimport //nolint:testpackage // driver is injected to unexported field
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver/fake"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// runFakeImport runs the full post-processor step sequence against the fake driver.
+func runFakeImport(t *testing.T, drv *fake.Driver, fields map[string]interface{}) (packersdk.Artifact, error) {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "image.raw")
+	require.NoError(t, os.WriteFile(path, []byte("disk image"), 0o600))
+
+	raw := map[string]interface{}{
+		"username":      "testuser",
+		"password":      "testpass",
+		"zones":         []string{"fi-hel1", "fi-hel2", "de-fra1"},
+		"template_name": "packer-import-test",
+		"journal_dir":   dir,
+	}
+	for k, v := range fields {
+		raw[k] = v
+	}
+
+	p := &PostProcessor{newDriverFn: func(_ *driver.DriverConfig) driver.Driver { return drv }}
+	require.NoError(t, p.Configure(raw))
+	artifact, _, _, err := p.PostProcess(t.Context(), packersdk.TestUi(t), &packersdk.MockArtifact{
+		BuilderIdValue: fileBuilderID,
+		FilesValue:     []string{path},
+	})
+	return artifact, err
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	t.Parallel()
+	drv := fake.New()
+	artifact, err := runFakeImport(t, drv, nil)
+	require.NoError(t, err)
+	assert.Empty(t, drv.Storages(upcloud.StorageTypeNormal))
+
+	templates := drv.Storages(upcloud.StorageTypeTemplate)
+	zones := make([]string, 0, len(templates))
+	for _, template := range templates {
+		assert.Equal(t, "packer-import-test", template.Title)
+		zones = append(zones, template.Zone)
+	}
+	assert.ElementsMatch(t, []string{"fi-hel1", "fi-hel2", "de-fra1"}, zones)
+
+	require.NoError(t, artifact.Destroy())
+	assert.Empty(t, drv.Storages(upcloud.StorageTypeTemplate))
+}
+
+func TestPostProcessor_PostProcess_replaceExisting(t *testing.T) {
+	t.Parallel()
+	drv := fake.New()
+	_, err := runFakeImport(t, drv, nil)
+	require.NoError(t, err)
+
+	_, err = runFakeImport(t, drv, map[string]interface{}{"replace_existing": true})
+	require.NoError(t, err)
+	assert.Empty(t, drv.Storages(upcloud.StorageTypeNormal))
+	assert.Len(t, drv.Storages(upcloud.StorageTypeTemplate), 3)
+}
+
+func TestPostProcessor_PostProcess_cleanup(t *testing.T) {
+	t.Parallel()
+	errFake := errors.New("fake error")
+	tests := []struct {
+		name   string
+		method string
+		call   int
+		err    error
+	}{
+		{name: "labels", method: "SetStorageLabels", call: 1, err: errFake},
+		{name: "upload", method: "ImportStorage", call: 1, err: errFake},
+		{name: "upload timeout", method: "WaitStorageOnline", call: 1, err: driver.ErrTimeout},
+		{name: "cloning", method: "CloneStorage", call: 2, err: driver.ErrCapacity},
+		{name: "template", method: "CreateTemplate", call: 3, err: errFake},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			drv := fake.New()
+			drv.Fail(test.method, test.call, test.err)
+			_, err := runFakeImport(t, drv, nil)
+			require.ErrorIs(t, err, test.err)
+			assert.Empty(t, drv.Storages(upcloud.StorageTypeNormal))
+			assert.Empty(t, drv.Storages(upcloud.StorageTypeTemplate))
+		})
+	}
+}
+
+func TestPostProcessor_PostProcess_concurrentFailures(t *testing.T) {
+	t.Parallel()
+	errFirst := errors.New("first error")
+	errSecond := errors.New("second error")
+	tests := []struct {
+		name   string
+		method string
+		calls  []int
+	}{
+		{name: "cloning", method: "CloneStorage", calls: []int{1, 2}},
+		{name: "clone labels", method: "SetStorageLabels", calls: []int{2, 3}},
+		{name: "template", method: "CreateTemplate", calls: []int{1, 3}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+			drv := fake.New()
+			drv.Fail(test.method, test.calls[0], errFirst)
+			drv.Fail(test.method, test.calls[1], errSecond)
+			_, err := runFakeImport(t, drv, nil)
+			// errors of all zones are returned, not only the first one
+			require.ErrorIs(t, err, errFirst)
+			require.ErrorIs(t, err, errSecond)
+			assert.Empty(t, drv.Storages(upcloud.StorageTypeNormal))
+			assert.Empty(t, drv.Storages(upcloud.StorageTypeTemplate))
+		})
+	}
+}
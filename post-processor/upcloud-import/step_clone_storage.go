@@ -39,28 +39,37 @@ func (s *stepCloneStorage) Run(ctx context.Context, state multistep.StateBag) mu
 		return multistep.ActionHalt
 	}
 
+	source := storages[0]
 	zones := s.postProcessor.config.Zones[1:]
-	var halt bool
+	var errs []error
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 	wg.Add(len(zones))
 	for _, z := range zones {
 		go func(zone string) {
 			defer wg.Done()
-			ui.Say(fmt.Sprintf("Cloning storage '%s' from %s to %s", storages[0].Title, storages[0].Zone, zone))
-			t, err := s.postProcessor.driver.CloneStorage(ctx, storages[0].UUID, zone, storages[0].Title)
+			ui.Say(fmt.Sprintf("Cloning storage '%s' from %s to %s", source.Title, source.Zone, zone))
+			t, err := s.postProcessor.driver.CloneStorage(ctx, source.UUID, zone, source.Title, s.postProcessor.config.StorageEncryption)
+			if err == nil {
+				err = s.postProcessor.driver.SetStorageLabels(ctx, t.UUID, s.postProcessor.config.storageLabels())
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if t != nil {
+				// cloned storage is cleaned up on failure
+				storages = append(storages, t)
+			}
 			if err != nil {
 				ui.Error(err.Error())
-				halt = true
-				return
+				errs = append(errs, err)
 			}
-			storages = append(storages, t)
 		}(z)
 	}
 	wg.Wait()
-	if halt {
-		return multistep.ActionHalt
-	}
 	state.Put(stateStorages, storages)
+	if len(errs) > 0 {
+		return haltOnError(nil, state, errors.Join(errs...))
+	}
 	return multistep.ActionContinue
 }
 
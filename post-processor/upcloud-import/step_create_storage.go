@@ -45,12 +45,16 @@ func (s *stepCreateStorage) Run(ctx context.Context, state multistep.StateBag) m
 		fmt.Sprintf("%s-%s", BuilderID, time.Now().Format(timestampSuffixLayout)),
 		s.postProcessor.config.Zones[0],
 		size,
-		s.postProcessor.config.StorageTier)
+		s.postProcessor.config.StorageTier,
+		s.postProcessor.config.StorageEncryption)
 	if err != nil {
 		return haltOnError(ui, state, err)
 	}
 	storages = append(storages, storage)
 	state.Put(stateStorages, storages)
+	if err := s.postProcessor.driver.SetStorageLabels(ctx, storage.UUID, s.postProcessor.config.storageLabels()); err != nil {
+		return haltOnError(ui, state, err)
+	}
 	ui.Say(fmt.Sprintf("Storage '%s' (%s) created", storage.Title, storage.UUID))
 	return multistep.ActionContinue
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 )
 
@@ -39,7 +40,8 @@ func (s *stepCreateTemplate) Run(ctx context.Context, state multistep.StateBag)
 		return haltOnError(ui, state, err)
 	}
 
-	var halt bool
+	var errs []error
+	var mu sync.Mutex
 	var wg sync.WaitGroup
 	wg.Add(len(storages))
 	for _, storage := range storages {
@@ -47,11 +49,15 @@ func (s *stepCreateTemplate) Run(ctx context.Context, state multistep.StateBag)
 		go func(sto *upcloud.Storage) {
 			defer wg.Done()
 			template, err := s.createTemplateBasedOnStorage(ctx, ui, sto)
+			mu.Lock()
+			defer mu.Unlock()
+			if template != nil {
+				// created template is cleaned up on failure
+				templates = append(templates, template)
+			}
 			if err != nil {
-				halt = true
-				return
+				errs = append(errs, err)
 			}
-			templates = append(templates, template)
 		}(storage)
 	}
 
@@ -63,11 +69,11 @@ func (s *stepCreateTemplate) Run(ctx context.Context, state multistep.StateBag)
 
 	state.Put(stateTemplates, templates)
 
-	if halt {
+	if len(errs) > 0 {
 		if err := cleanupTemplates(ctx, ui, s.postProcessor.driver, state); err != nil {
 			ui.Error(err.Error())
 		}
-		return multistep.ActionHalt
+		return haltOnError(nil, state, errors.Join(errs...))
 	}
 	return multistep.ActionContinue
 }
@@ -92,6 +98,10 @@ func (s *stepCreateTemplate) createTemplateBasedOnStorage(ctx context.Context, u
 	name := s.postProcessor.config.TemplateName
 	if s.postProcessor.config.ReplaceExisting {
 		existingTemplate, err = s.postProcessor.driver.GetTemplateByName(ctx, s.postProcessor.config.TemplateName, storage.Zone)
+		if err != nil && !errors.Is(err, driver.ErrNotFound) {
+			ui.Error(err.Error())
+			return nil, fmt.Errorf("failed to check existing template %s: %w", name, err)
+		}
 		if err == nil && existingTemplate.UUID != "" {
 			name = fmt.Sprintf("%s-%s-tmp", name, time.Now().Format(timestampSuffixLayout))
 			ui.Say(fmt.Sprintf("Replacing previously created (%s) template '%s' [%s]",
@@ -105,6 +115,11 @@ func (s *stepCreateTemplate) createTemplateBasedOnStorage(ctx context.Context, u
 		ui.Error(err.Error())
 		return nil, fmt.Errorf("failed to create template %s: %w", name, err)
 	}
+	if s.postProcessor.config.StorageEncryption && !template.Encrypted.Bool() {
+		err := fmt.Errorf("template %s was created without encryption", template.UUID)
+		ui.Error(err.Error())
+		return template, err
+	}
 	if existingTemplate != nil {
 		ui.Say(fmt.Sprintf("Deleting existing template '%s' (%s) [%s]", existingTemplate.Title, existingTemplate.UUID, existingTemplate.Zone))
 		if err := s.postProcessor.driver.DeleteStorage(ctx, existingTemplate.UUID); err != nil {
@@ -119,6 +134,11 @@ func (s *stepCreateTemplate) createTemplateBasedOnStorage(ctx context.Context, u
 		}
 	}
 
+	if err := s.postProcessor.driver.SetStorageLabels(ctx, template.UUID, s.postProcessor.config.storageLabels()); err != nil {
+		ui.Error(err.Error())
+		return template, fmt.Errorf("failed to set labels to template %s: %w", template.Title, err)
+	}
+
 	ui.Say(fmt.Sprintf("Template '%s' created in %s [%s]", name, time.Since(t1), storage.Zone))
 	return template, nil
 }
@@ -2,15 +2,25 @@ package upcloudimport
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
 
 	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/journal"
 	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
 )
 
+// reportJournal tells user about resources which were not cleaned up.
+func reportJournal(ui packer.Ui, j *journal.Journal) {
+	if entries := j.Entries(); len(entries) > 0 {
+		ui.Error(fmt.Sprintf("%d resources were not cleaned up and are recorded in %s. Use 'upcloud-packer-reaper -recover' to remove them.",
+			len(entries), j.Path()))
+	}
+}
+
 func cleanupDevices(ctx context.Context, ui packer.Ui, driver driver.Driver, state multistep.StateBag) error {
 	storages, err := getStorages(state)
 	if err != nil {
@@ -39,13 +49,18 @@ func cleanupTemplates(ctx context.Context, ui packer.Ui, driver driver.Driver, s
 	return nil
 }
 
-func deleteStorageIfExists(ctx context.Context, ui packer.Ui, driver driver.Driver, storage *upcloud.Storage) error {
-	if _, err := driver.GetStorage(ctx, storage.UUID, ""); err == nil {
-		ui.Say(fmt.Sprintf("Cleanup storage '%s' (%s)", storage.Title, storage.UUID))
-		if err := driver.DeleteStorage(ctx, storage.UUID); err != nil {
-			ui.Error(err.Error())
-			return fmt.Errorf("failed to delete storage %s: %w", storage.UUID, err)
+func deleteStorageIfExists(ctx context.Context, ui packer.Ui, drv driver.Driver, storage *upcloud.Storage) error {
+	if _, err := drv.GetStorage(ctx, storage.UUID, ""); err != nil {
+		if errors.Is(err, driver.ErrNotFound) {
+			return nil
 		}
+		ui.Error(err.Error())
+		return fmt.Errorf("failed to check storage %s: %w", storage.UUID, err)
+	}
+	ui.Say(fmt.Sprintf("Cleanup storage '%s' (%s)", storage.Title, storage.UUID))
+	if err := drv.DeleteStorage(ctx, storage.UUID); err != nil && !errors.Is(err, driver.ErrNotFound) {
+		ui.Error(err.Error())
+		return fmt.Errorf("failed to delete storage %s: %w", storage.UUID, err)
 	}
 	return nil
 }
@@ -0,0 +1,51 @@
+//go:build !integration
+
+package upcloudimport //nolint:testpackage // cleanup helpers are not exported
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	packersdk "github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/UpCloudLtd/packer-plugin-upcloud/internal/driver"
+	"github.com/UpCloudLtd/upcloud-go-api/v8/upcloud"
+)
+
+// storageDriver returns errors for the listed storages and records deleted storages. Calling other methods panics.
+type storageDriver struct {
+	driver.Driver
+
+	errs    map[string]error
+	deleted []string
+}
+
+func (d *storageDriver) GetStorage(_ context.Context, storageUUID, _ string) (*upcloud.Storage, error) {
+	if err, ok := d.errs[storageUUID]; ok {
+		return nil, fmt.Errorf("error retrieving storage by uuid %q: %w", storageUUID, err)
+	}
+	return &upcloud.Storage{UUID: storageUUID}, nil
+}
+
+func (d *storageDriver) DeleteStorage(_ context.Context, storageUUID string) error {
+	d.deleted = append(d.deleted, storageUUID)
+	return nil
+}
+
+func TestDeleteStorageIfExists(t *testing.T) {
+	t.Parallel()
+	drv := &storageDriver{errs: map[string]error{
+		"deleted":   driver.ErrNotFound,
+		"forbidden": driver.ErrAuth,
+	}}
+	ui := packersdk.TestUi(t)
+
+	require.NoError(t, deleteStorageIfExists(t.Context(), ui, drv, &upcloud.Storage{UUID: "exists"}))
+	require.NoError(t, deleteStorageIfExists(t.Context(), ui, drv, &upcloud.Storage{UUID: "deleted"}))
+	err := deleteStorageIfExists(t.Context(), ui, drv, &upcloud.Storage{UUID: "forbidden"})
+	require.ErrorIs(t, err, driver.ErrAuth)
+	assert.Equal(t, []string{"exists"}, drv.deleted)
+}